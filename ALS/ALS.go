@@ -2,26 +2,18 @@
 package ALS
 
 import (
-	"errors"
-	"fmt"
 	"math"
 	"math/rand"
-	"sort"
 	"strconv"
 
 	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/topk"
 )
 
 var (
 	NA = math.NaN()
 )
 
-func errcheck(err error) {
-	if err != nil {
-		fmt.Printf("Error occured: %v", err)
-	}
-}
-
 // Create the W matrix for the ALS algorithm..
 // Returns binary matrix indicating presence of values.
 func makeWeightMatrix(mat *DenseMatrix) *DenseMatrix {
@@ -42,32 +34,20 @@ func MakeRatingMatrix(ratings []float64, rows, cols int) *DenseMatrix {
 	return MakeDenseMatrix(ratings, rows, cols)
 }
 
-// creates the confidence matrix for the implicit ALS algorithm
-func makeCMatrix(mat *DenseMatrix) *DenseMatrix {
-	values := mat.Array()
-	newvalues := make([]float64, len(values))
-	for i := 0; i < len(values); i++ {
-		if values[i] == 0.0 || math.IsNaN(values[i]) {
-			newvalues[i] = 1
-		} else {
-			newvalues[i] = 1 + 40*values[i] // the value of 20 for confidence was
-		} // recommended by the aforementioned paper regarding implicit ALS
-	}
-	return MakeDenseMatrix(newvalues, mat.Rows(), mat.Cols())
+// create X and Y matrices for the ALS algorithm, drawing the initial factors from rng.
+func makeXY(mat *DenseMatrix, n_factors int, max_rating float64, rng *rand.Rand) (X, Y *DenseMatrix) {
+	return randomFactors(mat.Rows(), mat.Cols(), n_factors, max_rating, rng)
 }
 
-// create X and Y matrices for the ALS algorithm.
-func makeXY(mat *DenseMatrix, n_factors int, max_rating float64, seed int) (X, Y *DenseMatrix) {
-	rand.Seed(int64(seed))
-	rows := mat.Rows()
-	cols := mat.Cols()
+// random X and Y factors for a rows x cols rating matrix
+func randomFactors(rows, cols, n_factors int, max_rating float64, rng *rand.Rand) (X, Y *DenseMatrix) {
 	X_data := make([]float64, rows*n_factors)
 	Y_data := make([]float64, cols*n_factors)
 	for i := 0; i < len(X_data); i++ {
-		X_data[i] = max_rating * rand.Float64()
+		X_data[i] = max_rating * rng.Float64()
 	}
 	for j := 0; j < len(Y_data); j++ {
-		Y_data[j] = max_rating * rand.Float64()
+		Y_data[j] = max_rating * rng.Float64()
 	}
 	X = MakeDenseMatrix(X_data, rows, n_factors)
 	Y = MakeDenseMatrix(Y_data, n_factors, cols)
@@ -84,15 +64,6 @@ func sumMatrix(mat *DenseMatrix) (sum float64) {
 	return
 }
 
-// Auxilliary function for Matrix Solver.
-func swapCols(mat *DenseMatrix, i, j int) *DenseMatrix {
-	p := mat.Copy()
-	trans := p.Transpose()
-	trans.SwapRows(i, j)
-	toret := trans.Transpose()
-	return toret
-}
-
 // Scales matrix mat by weight.
 func simpleTimes(mat, weight *DenseMatrix) *DenseMatrix {
 	if len(mat.Array()) != len(weight.Array()) {
@@ -110,9 +81,8 @@ func simpleTimes(mat, weight *DenseMatrix) *DenseMatrix {
 func getErrorInline(W, q, X, Y *DenseMatrix) float64 {
 	Q := q.Copy()
 	dot, err := X.TimesDense(Y)
-	errcheck(err)
-	err = Q.SubtractDense(dot)
-	errcheck(err)
+	must(err)
+	must(Q.SubtractDense(dot))
 	Prod := simpleTimes(Q, W)
 	tosum := simpleTimes(Prod, Prod)
 	sum := sumMatrix(tosum)
@@ -122,7 +92,7 @@ func getErrorInline(W, q, X, Y *DenseMatrix) float64 {
 // a function to set the values for a given row
 func setRow(mat *DenseMatrix, which int, row []float64) *DenseMatrix {
 	if mat.Cols() != len(row) {
-		fmt.Println("The row to set needs to be the same dimension as the matrix")
+		must(wrap(ErrDimensionMismatch, "The row to set needs to be the same dimension as the matrix"))
 	}
 	// iterate over columns to set the values for a selected row
 	for i := 0; i < mat.Cols(); i++ {
@@ -134,7 +104,7 @@ func setRow(mat *DenseMatrix, which int, row []float64) *DenseMatrix {
 // a function to set the values for a given column
 func setCol(mat *DenseMatrix, which int, col []float64) *DenseMatrix {
 	if mat.Rows() != len(col) {
-		fmt.Println("The column to set needs to be the same dimension as the matrix")
+		must(wrap(ErrDimensionMismatch, "The column to set needs to be the same dimension as the matrix"))
 	}
 	// iterate over rows to set the values for a selected columns
 	for i := 0; i < mat.Rows(); i++ {
@@ -143,21 +113,6 @@ func setCol(mat *DenseMatrix, which int, col []float64) *DenseMatrix {
 	return mat
 }
 
-// function to substract the minimum from all elements of the matrix
-func matrixMinMinus(mat *DenseMatrix) *DenseMatrix {
-	values := mat.Array()
-	min := float64(100)
-	for i := 0; i < len(values); i++ {
-		if values[i] < min {
-			min = values[i]
-		}
-	}
-	for i := 0; i < len(values); i++ {
-		values[i] -= min
-	}
-	return MakeDenseMatrix(values, mat.Rows(), mat.Cols())
-}
-
 // returns the max value of the (dense)matrix
 func matrixMax(mat *DenseMatrix) float64 {
 	values := mat.Array()
@@ -170,153 +125,244 @@ func matrixMax(mat *DenseMatrix) float64 {
 	return max
 }
 
-// Params: the user/product matrix, number of factors for recommendation, iterations, and lambda value for ALS.
-// Returns the trained matrix with predictions for 0 valued entries, and the final error calculation (float64)
-func Train(Q *DenseMatrix, n_factors, iterations int, lambda float64) (*DenseMatrix, float64) {
-	W := makeWeightMatrix(Q)
-	maxval := matrixMax(Q)
-	X, Y := makeXY(Q, n_factors, maxval, 47)
-	// to store error values
-	errors := make([]float64, 0)
-
-	for ii := 0; ii < iterations; ii++ {
-		// scaled identity matrix
-		I := Eye(n_factors)
-		I.Scale(lambda)
-
+// Runs the alternating least squares iterations on the model until it has completed the given
+// number of iterations, minimizing the W weighted squared error between P and X*Y.
+// With a validation set, stops early once it stops improving and keeps the best iteration's factors.
+// The metrics of every iteration are recorded in the model's History.
+func (m *Model) fit(iterations int, cfg *config) {
+	stop := cfg.earlyStopping()
+	m.History.Validated = cfg.validation != nil
+	for m.Iterations < iterations {
 		// solve for X
-		for u := 0; u < Q.Rows(); u++ {
-			weightedRow := W.GetRowVector(u).Array()
-			w_yt, _ := Diagonal(weightedRow).TimesDense(Y.Transpose())
-			y_wt_yt, _ := Y.TimesDense(w_yt)
-			y_wt_yt.AddDense(I)
-			y_wt_ytInv, _ := y_wt_yt.Inverse()
+		m.X = m.solveSide(m.Y.Transpose().Arrays(), m.W, m.P, m.X, cfg)
+		// now alternate to solve for Y
+		m.Y = m.solveSide(m.X.Arrays(), m.W.Transpose(), m.P.Transpose(), m.Y.Transpose(), cfg).Transpose()
+		if cfg.err != nil {
+			return
+		}
+		// Calculate the error values at each iteration
+		error_value := getErrorInline(m.W, m.P, m.X, m.Y)
+		m.Iterations++
+		stats := IterationStats{Iteration: m.Iterations, TrainingError: error_value}
+		if cfg.validation != nil {
+			stats.ValidationError = m.validationError(cfg.validation, cfg.validationMask)
+		}
+		cfg.record(&m.History, stats)
+		if cfg.onIteration != nil {
+			cfg.onIteration(m.Iterations, error_value)
+		}
+		cfg.checkpoint(m)
+		if cfg.err != nil {
+			return
+		}
+		if stop.done(m, stats.ValidationError) {
+			break
+		}
+	}
+	stop.restoreBest(m)
+}
 
-			q_u := Q.GetRowVector(u).Transpose()
-			wu_qu, _ := Diagonal(weightedRow).TimesDense(q_u)
-			x_tosolve, _ := Y.TimesDense(wu_qu)
-			new_row, _ := y_wt_ytInv.TimesDense(x_tosolve)
-			X = setRow(X, u, new_row.Array())
+// regularization of a user/product with the given weights and targets
+func (m *Model) lambdaFor(w, p []float64) float64 {
+	if !m.WeightedLambda {
+		return m.Lambda
+	}
+	n := 0
+	for r := range w {
+		// all implicit entries have a weight, but only the observed ones a target
+		if (m.Implicit && p[r] != 0) || (!m.Implicit && w[r] != 0) {
+			n++
 		}
-		// now alternate to solve for Y
-		for i := 0; i < Q.Cols(); i++ {
-			weightedCol := W.GetColVector(i).Transpose().Array()
-			w_x, _ := Diagonal(weightedCol).TimesDense(X)
-			x_t_w_x, _ := X.Transpose().TimesDense(w_x)
-			x_t_w_x.AddDense(I)
-			x_t_w_xInv, _ := x_t_w_x.Inverse()
+	}
+	if n == 0 {
+		// keep the system positive definite for users/products without ratings
+		n = 1
+	}
+	return m.Lambda * float64(n)
+}
 
-			q_i := Q.GetColVector(i)
-			wi_qi, _ := Diagonal(weightedCol).TimesDense(q_i)
-			y_tosolve, _ := X.Transpose().TimesDense(wi_qi)
-			new_col, _ := x_t_w_xInv.TimesDense(y_tosolve)
-			Y = setCol(Y, i, new_col.Array())
+// Re-solves all rows of X (users, or products with transposed W, P and X) against the fixed factors F,
+// one per column of W. Rows whose system can't be solved keep their current factors, and the first
+// such error is recorded in cfg.
+func (m *Model) solveSide(F [][]float64, W, P, X *DenseMatrix, cfg *config) *DenseMatrix {
+	w, p, x0 := W.Arrays(), P.Arrays(), X.Arrays()
+	lambdas := make([]float64, len(w))
+	for r := range w {
+		lambdas[r] = m.lambdaFor(w[r], p[r])
+	}
+	solutions, errs := cfg.solveAll(F, w, p, x0, lambdas)
+	for r, err := range errs {
+		if err != nil {
+			cfg.fail(err)
+			continue
 		}
-		// Calculate the error values at each iteration
-		error_value := getErrorInline(W, Q, X, Y)
-		errors = append(errors, error_value)
+		X = setRow(X, r, solutions[r])
 	}
-	fmt.Printf("\nFinal Error value of: %v\n", errors[len(errors)-1])
-	weighted_Qhat, _ := X.TimesDense(Y)
-	return weighted_Qhat, errors[len(errors)-1]
+	return X
 }
 
-// Params: the rating matrix, number of factors, number of iterations, and lambda for building
-// recommendation matrix.
-// Returns the confidence matrix on a scale from 0 to 1.
-func TrainImplicit(R *DenseMatrix, n_factors, iterations int, lambda float64) *DenseMatrix {
-	P := makeWeightMatrix(R)
-	C := makeCMatrix(R)
-	X, Y := makeXY(R, n_factors, 5, 47)
+// re-solves the factors of user u against the fixed product factors Yt (one row per product).
+func (m *Model) solveUser(u int, Yt [][]float64, cfg *config) error {
+	w, p := m.W.RowCopy(u), m.P.RowCopy(u)
+	new_row, err := cfg.solveFactors(Yt, w, p, m.X.RowCopy(u), m.lambdaFor(w, p))
+	if err != nil {
+		return err
+	}
+	m.X = setRow(m.X, u, new_row)
+	return nil
+}
 
-	for ii := 0; ii < iterations; ii++ {
-		// scaled identity matrix
-		I := Eye(n_factors)
-		I.Scale(lambda)
+// re-solves the factors of product i against the fixed user factors Xr (one row per user).
+func (m *Model) solveItem(i int, Xr [][]float64, cfg *config) error {
+	w, p := m.W.ColCopy(i), m.P.ColCopy(i)
+	new_col, err := cfg.solveFactors(Xr, w, p, m.Y.ColCopy(i), m.lambdaFor(w, p))
+	if err != nil {
+		return err
+	}
+	m.Y = setCol(m.Y, i, new_col)
+	return nil
+}
 
-		// solve for X
-		for u := 0; u < C.Rows(); u++ {
-			weightedRow := C.GetRowVector(u).Array()
-			c_yt, _ := Diagonal(weightedRow).TimesDense(Y)
-			y_ct_yt, _ := Y.Transpose().TimesDense(c_yt)
-			y_ct_yt.AddDense(I)
-			y_ct_ytInv, _ := y_ct_yt.Inverse()
+// Params: the user/product matrix, number of factors for recommendation, iterations, and lambda value for ALS.
+// Optional settings such as WithConjugateGradient can be passed as trailing options.
+// Returns the trained model, and the final error calculation (float64). Fails with ErrDimensionMismatch
+// or ErrInvalidArgument for bad options, and ErrSingularSystem if a user or product can't be solved for.
+func TrainModel(Q *DenseMatrix, n_factors, iterations int, lambda float64, opts ...Option) (*Model, float64, error) {
+	cfg := newConfig(opts)
+	observed := cfg.observedMask(Q)
+	model := &Model{
+		W:              cfg.decayed(observed, cfg.weightsFor(Q, observed.Copy()), false),
+		P:              maskedTargets(Q, observed),
+		Lambda:         lambda,
+		WeightedLambda: cfg.weightedLambda,
+		Norm:           newNormalizer(Q, observed, cfg.normalization, cfg.normalizeByItem),
+		Clip:           cfg.clip,
+		NonNegative:    cfg.nonNegative,
+		MaxNorm:        cfg.maxNorm,
+		MissingValue:   cfg.missing(),
+	}
+	model.MinRating, model.MaxRating = ratingRange(Q, observed)
+	if cfg.weights != nil {
+		model.Observed = observed
+	}
+	if model.Norm != nil {
+		model.P = model.Norm.apply(Q, observed)
+	}
+	model.X, model.Y, model.Iterations = cfg.startingFactors(model, n_factors, matrixMax(Q))
+	cfg.checkValidation(Q)
+	if cfg.err != nil {
+		return nil, 0, cfg.err
+	}
+	model.fit(iterations, cfg)
+	if cfg.err != nil {
+		return nil, 0, cfg.err
+	}
+	model.trained(AlgorithmALS, Q, n_factors, iterations, cfg)
+	// the returned model is not necessarily the last iteration's when stopping early
+	return model, getErrorInline(model.W, model.P, model.X, model.Y), nil
+}
 
-			p_u := P.GetRowVector(u).Transpose()
-			cu_pu, _ := Diagonal(weightedRow).TimesDense(p_u)
-			x_tosolve, _ := Y.Transpose().TimesDense(cu_pu)
-			new_row, _ := y_ct_ytInv.TimesDense(x_tosolve)
-			X = setRow(X, u, new_row.Array())
-		}
+// Params: the user/product matrix, number of factors for recommendation, iterations, and lambda value for ALS.
+// Optional settings such as WithConjugateGradient can be passed as trailing options.
+// Returns the trained matrix with predictions for 0 valued entries, and the final error calculation (float64).
+// Fails like TrainModel.
+func Train(Q *DenseMatrix, n_factors, iterations int, lambda float64, opts ...Option) (*DenseMatrix, float64, error) {
+	model, error_value, err := TrainModel(Q, n_factors, iterations, lambda, opts...)
+	if err != nil {
+		return nil, 0, err
+	}
+	logger.Printf("Final Error value of: %v", error_value)
+	return model.PredictionsWith(newConfig(opts).postProcess...), error_value, nil
+}
 
-		// now alternate to solve for Y
-		for i := 0; i < C.Cols(); i++ {
-			weightedCol := C.GetColVector(i).Transpose().Array()
-			c_x, _ := Diagonal(weightedCol).TimesDense(X)
-			x_t_c_x, _ := X.Transpose().TimesDense(c_x)
-			x_t_c_x.AddDense(I)
-			x_t_c_xInv, _ := x_t_c_x.Inverse()
+// Params: the rating matrix, number of factors, number of iterations, and lambda for building
+// recommendation model.
+// Optional settings such as WithConjugateGradient can be passed as trailing options.
+// Returns the trained model, weighting each preference by its confidence. Fails like TrainModel.
+func TrainImplicitModel(R *DenseMatrix, n_factors, iterations int, lambda float64, opts ...Option) (*Model, error) {
+	cfg := newConfig(opts)
+	observed := cfg.observedMask(R)
+	model := &Model{
+		W:              cfg.decayed(observed, cfg.weightsFor(R, confidences(R, observed)), true),
+		P:              observed,
+		Lambda:         lambda,
+		WeightedLambda: cfg.weightedLambda,
+		Implicit:       true,
+		NonNegative:    cfg.nonNegative,
+		MaxNorm:        cfg.maxNorm,
+		MissingValue:   cfg.missing(),
+	}
+	model.X, model.Y, model.Iterations = cfg.startingFactors(model, n_factors, 5)
+	cfg.checkValidation(R)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	model.fit(iterations, cfg)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	model.trained(AlgorithmImplicitALS, R, n_factors, iterations, cfg)
+	return model, nil
+}
 
-			p_i := P.GetColVector(i)
-			ci_pi, _ := Diagonal(weightedCol).TimesDense(p_i)
-			y_tosolve, _ := X.Transpose().TimesDense(ci_pi)
-			new_col, _ := x_t_c_xInv.TimesDense(y_tosolve)
-			Y = setCol(Y, i, new_col.Array())
-		}
+// Params: the rating matrix, number of factors, number of iterations, and lambda for building
+// recommendation matrix.
+// Optional settings such as WithConjugateGradient can be passed as trailing options.
+// Returns the confidence matrix, roughly on a scale from 0 to 1; WithPostProcessing(RowMinMax())
+// maps every user's row onto exactly [0, 1]. Fails like TrainModel.
+func TrainImplicit(R *DenseMatrix, n_factors, iterations int, lambda float64, opts ...Option) (*DenseMatrix, error) {
+	model, err := TrainImplicitModel(R, n_factors, iterations, lambda, opts...)
+	if err != nil {
+		return nil, err
 	}
-	weighted_Qhat, _ := X.TimesDense(Y)
-	return weighted_Qhat
+	return model.PredictionsWith(newConfig(opts).postProcess...), nil
 }
 
 // Returns recommended value for a given user-product indices. Error if out of range.
 func Predict(Qhat *DenseMatrix, user, product int) (float64, error) {
-	if user > Qhat.Rows() || product > Qhat.Cols() {
-		return 0.0, errors.New("User/Product index out of range")
+	if user < 0 || user >= Qhat.Rows() || product < 0 || product >= Qhat.Cols() {
+		return 0.0, wrap(ErrInvalidArgument, "User/Product index out of range")
 	}
 	return Qhat.Get(user, product), nil
 }
 
-func oppositeWeights(Q *DenseMatrix) *DenseMatrix {
-	mat := Q.Array()
-	for i := 0; i < len(mat); i++ {
-		if mat[i] > 0 {
-			mat[i] = 0
-		} else {
-			mat[i] = 1
+// a copy of Qhat with the entries that are nonzero in the observed mask set to -Inf, so they are
+// ranked below every unrated product, even ones with negative predictions
+func maskRated(Qhat, observed *DenseMatrix) *DenseMatrix {
+	mat := Qhat.Copy().Array()
+	for i, o := range observed.Array() {
+		if o != 0 {
+			mat[i] = math.Inf(-1)
 		}
 	}
-	return MakeDenseMatrix(mat, Q.Rows(), Q.Cols())
+	return MakeDenseMatrix(mat, Qhat.Rows(), Qhat.Cols())
 }
 
 // looks at the model generated by ALS and makes a user/product prediction
 // Returns best n recommendations for a user index in the matrix.
 // If products is nil, returns top indices. Else returns names of top products.
-func GetTopNRecommendations(Q, Qhat *DenseMatrix, user, n int, products []string) ([]string, error) {
-	qhat := Qhat.Copy()
-	inverseWeights := oppositeWeights(Q)
-	qhat = simpleTimes(qhat, inverseWeights)
+// The products the user rated in Q are skipped; WithObserved and WithMissingValue say which
+// those are, as for training.
+func GetTopNRecommendations(Q, Qhat *DenseMatrix, user, n int, products []string, opts ...Option) ([]string, error) {
+	cfg := newConfig(opts)
+	observed := cfg.observedMask(Q)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	qhat := maskRated(Qhat, observed)
 
-	if user > qhat.Rows() || n > qhat.Cols() {
-		return nil, errors.New("User/Product index out of range")
+	if user < 0 || user >= qhat.Rows() || n < 0 || n > qhat.Cols() {
+		return nil, wrap(ErrInvalidArgument, "User/Product index out of range")
 	} else {
 		user_row := qhat.GetRowVector(user).Array()
-		productScores := make(map[float64]string, 0)
-		// make score - product map if product list is present. Else use indices.
-		if products != nil {
-			for idx, val := range user_row {
-				productScores[val] = products[idx]
-			}
-		} else {
-			for idx, val := range user_row {
-				productScores[val] = strconv.Itoa(idx)
-			}
-		}
-		// Sort user row
-		sort.Sort(sort.Reverse(sort.Float64Slice(user_row)))
-		// get top-N recommendations
+		// get top-N recommendations, by name if product list is present. Else use indices.
 		var recommendations []string
-		for i := 0; i < n; i++ {
-			recommendations = append(recommendations, productScores[user_row[i]])
+		for _, top := range topk.TopK(user_row, n) {
+			if products != nil {
+				recommendations = append(recommendations, products[top.Index])
+			} else {
+				recommendations = append(recommendations, strconv.Itoa(top.Index))
+			}
 		}
 		return recommendations, nil
 	}
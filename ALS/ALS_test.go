@@ -1,10 +1,20 @@
 package ALS
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
 	"testing"
 
 	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/baseline"
 )
 
 func Assert(t *testing.T, condition bool, args ...interface{}) {
@@ -37,12 +47,13 @@ func TestExplicit(t *testing.T) {
 	n_iterations := 10
 	lambda := 0.01
 
-	Qhat, err := Train(Q, n_factors, n_iterations, lambda)
+	Qhat, error_value, err := Train(Q, n_factors, n_iterations, lambda)
+	Assert(t, err == nil, err)
 	if Qhat.Rows() != Q.Rows() || Qhat.Cols() != Q.Cols() {
 		t.Errorf("Unexpected Dimensions. Got %v & %v", Qhat.Rows(), Qhat.Cols())
 	}
 	Assert(t, Qhat.Get(0, 3) > 2)
-	Assert(t, err < 1)
+	Assert(t, error_value < 1)
 }
 
 func TestImplicit(t *testing.T) {
@@ -53,7 +64,7 @@ func TestImplicit(t *testing.T) {
 	n_iterations := 5
 	lambda := 0.01
 
-	Qhat := TrainImplicit(Q, n_factors, n_iterations, lambda)
+	Qhat, _ := TrainImplicit(Q, n_factors, n_iterations, lambda)
 	fmt.Println(Qhat)
 	Assert(t, Qhat.Get(1, 0) > 0)
 }
@@ -65,7 +76,7 @@ func TestPredictions(t *testing.T) {
 		2, 0, 4, 1, 0,
 		5, 2, 0, 1, 0}, 5, 5)
 
-	Qhat, _ := Train(Q, 5, 10, 0.01)
+	Qhat, _, _ := Train(Q, 5, 10, 0.01)
 	fmt.Printf("Prediction Test, Prediction Matrix: %v", Qhat)
 	// If Product Names is nil, then returns top indices for each user. Returns in descending order.
 	products := []string{"Macy Gray", "The Black Keys", "Spoon", "A Tribe Called Quest", "Kanye West"}
@@ -73,3 +84,1472 @@ func TestPredictions(t *testing.T) {
 	fmt.Println(preds)
 	Assert(t, preds[0] == "Spoon")
 }
+
+func TestConjugateGradient(t *testing.T) {
+	F := [][]float64{{1, 2, 0}, {0, 1, 3}, {2, 1, 1}, {1, 0, 1}}
+	w := []float64{1, 1, 0, 2}
+	q := []float64{5, 3, 4, 1}
+
+	exact, err := newConfig(nil).solveFactors(F, w, q, make([]float64, 3), 0.1)
+	Assert(t, err == nil)
+	// CG converges in at most k steps for a k x k system
+	approx := conjugateGradient(F, w, q, make([]float64, 3), 0.1, 3)
+	for i := range exact {
+		Assert(t, math.Abs(exact[i]-approx[i]) < 1e-8, exact, approx)
+	}
+}
+
+func TestModelUpdate(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		1, 2, 3, 3, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 5, 5)
+
+	model, _, _ := TrainModel(Q, 3, 10, 0.01)
+	before, _ := model.Predict(1, 0)
+	Assert(t, model.Update(1, 0, 5) == nil)
+	after, _ := model.Predict(1, 0)
+	Assert(t, math.Abs(after-5) < math.Abs(before-5), before, after)
+
+	// a brand new user is appended to the model
+	Assert(t, model.Update(5, 2, 4) == nil)
+	Assert(t, model.X.Rows() == 6 && model.P.Rows() == 6)
+	pred, err := model.Predict(5, 2)
+	Assert(t, err == nil && pred > 2, pred)
+
+	Assert(t, model.Update(7, 0, 1) != nil)
+}
+
+func TestDeterministicTraining(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+
+	first, _, _ := TrainModel(Q, 3, 5, 0.01, WithSeed(7))
+	second, _, _ := TrainModel(Q, 3, 5, 0.01, WithSeed(7))
+	Assert(t, Equals(first.X, second.X) && Equals(first.Y, second.Y))
+
+	other, _, _ := TrainModel(Q, 3, 5, 0.01, WithSeed(8))
+	Assert(t, !Equals(first.X, other.X))
+}
+
+func TestCustomWeights(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+
+	// trust the rating of user 2 for product 0 much less than the rest
+	W := makeWeightMatrix(Q)
+	W.Set(2, 0, 0.001)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01, WithWeights(W))
+	Assert(t, model.W.Get(2, 0) == 0.001)
+
+	// weights don't decide what was rated: user 0 gets a weight for unrated product 3 and none
+	// for its rating of product 4
+	W = makeWeightMatrix(Q)
+	W.Set(0, 3, 0.5)
+	W.Set(0, 4, 0)
+	model, _, _ = TrainModel(Q, 3, 10, 0.01, WithWeights(W))
+	top, _, _ := model.TopN(0, 5)
+	Assert(t, len(top) == 1 && top[0] == 3, top)
+	var buf bytes.Buffer
+	Assert(t, model.Save(&buf) == nil)
+	loaded, err := LoadModel(&buf)
+	Assert(t, err == nil && Equals(loaded.Observed, model.Observed), err)
+	clone := model.Clone()
+	Assert(t, clone.Update(0, 3, 2) == nil && clone.Update(4, 0, 3) == nil)
+	top, _, _ = clone.TopN(0, 5)
+	Assert(t, len(top) == 0, top)
+	top, _, _ = clone.TopN(4, 5)
+	Assert(t, len(top) == 4 && len(model.rated(0)) == 4, top)
+
+	// mismatched shapes are an error
+	_, _, err = TrainModel(Q, 3, 10, 0.01, WithWeights(Eye(3)))
+	Assert(t, errors.Is(err, ErrDimensionMismatch), err)
+}
+
+func TestCheckpointResume(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+
+	dir, err := ioutil.TempDir("", "als-checkpoints")
+	Assert(t, err == nil)
+	defer os.RemoveAll(dir)
+
+	full, _, _ := TrainModel(Q, 3, 6, 0.01, WithCheckpoints(4, CheckpointToDir(dir)))
+	checkpoint, err := LatestCheckpoint(dir)
+	Assert(t, err == nil, err)
+	Assert(t, checkpoint.Iterations == 4)
+
+	// picking up at iteration 4 ends with the same model as the uninterrupted run
+	resumed, _, _ := TrainModel(Q, 3, 6, 0.01, ResumeFrom(checkpoint))
+	Assert(t, resumed.Iterations == 6)
+	Assert(t, ApproxEquals(full.X, resumed.X, 1e-9) && ApproxEquals(full.Y, resumed.Y, 1e-9))
+}
+
+type recordingLogger []string
+
+func (r *recordingLogger) Printf(format string, v ...interface{}) {
+	*r = append(*r, fmt.Sprintf(format, v...))
+}
+
+func TestProgressReporting(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+
+	losses := make([]float64, 0)
+	_, final, _ := Train(Q, 3, 4, 0.01, OnIteration(func(iter int, loss float64) {
+		Assert(t, iter == len(losses)+1)
+		losses = append(losses, loss)
+	}))
+	Assert(t, len(losses) == 4 && losses[3] == final, losses)
+
+	log := &recordingLogger{}
+	SetLogger(log)
+	defer SetLogger(nil)
+	Train(Q, 3, 1, 0.01)
+	Assert(t, len(*log) == 1 && strings.HasPrefix((*log)[0], "Final Error"), *log)
+}
+
+func TestEarlyStopping(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		1, 2, 3, 0, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 5, 5)
+	// held out ratings, not part of Q
+	V := MakeDenseMatrix([]float64{0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0,
+		0, 0, 0, 3, 0,
+		0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0}, 5, 5)
+
+	ran := 0
+	model, _, _ := TrainModel(Q, 5, 20, 0.01, WithValidation(V, 2), OnIteration(func(iter int, loss float64) {
+		ran = iter
+	}))
+	// the kept iteration is the best one seen on V, and training stopped 2 iterations after it
+	Assert(t, model.Iterations >= 1 && model.Iterations <= ran)
+	Assert(t, ran == 20 || ran == model.Iterations+2, ran, model.Iterations)
+
+	// scoring the kept model against V gives the best validation error
+	best := model.ValidationError(V)
+	full, _, _ := TrainModel(Q, 5, 20, 0.01)
+	Assert(t, best <= full.ValidationError(V)+1e-12)
+}
+
+func TestHistory(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	V := Zeros(4, 5)
+	V.Set(1, 0, 1)
+
+	var streamed bytes.Buffer
+	model, final, _ := TrainModel(Q, 3, 3, 0.01, WithValidation(V, 0), StreamHistory(&streamed, CSV))
+	history := model.History
+	Assert(t, history.Validated && len(history.Iterations) == 3)
+	// the returned model is the best iteration on V
+	Assert(t, history.Iterations[2].Iteration == 3 && history.Iterations[model.Iterations-1].TrainingError == final)
+
+	var written bytes.Buffer
+	Assert(t, history.WriteCSV(&written) == nil)
+	Assert(t, streamed.String() == written.String(), streamed.String(), written.String())
+	lines := strings.Split(strings.TrimSpace(written.String()), "\n")
+	Assert(t, len(lines) == 4 && lines[0] == "iteration,training_error,validation_error", lines)
+
+	written.Reset()
+	Assert(t, history.WriteJSON(&written) == nil)
+	Assert(t, strings.Count(written.String(), "validation_error") == 3, written.String())
+}
+
+func TestTopN(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1, 0,
+		0, 0, 0, 4, 1, 2,
+		1, 2, 3, 3, 1, 0,
+		2, 0, 4, 1, 0, 5,
+		5, 2, 0, 1, 0, 4}, 5, 6)
+
+	model, _, _ := TrainModel(Q, 3, 10, 0.01)
+	products, scores, err := model.TopN(1, 2)
+	Assert(t, err == nil && len(products) == 2 && scores[0] >= scores[1])
+	for i, p := range products {
+		// already rated products are never recommended
+		Assert(t, Q.Get(1, p) == 0, products)
+		pred, _ := model.Predict(1, p)
+		Assert(t, math.Abs(pred-scores[i]) < 1e-9)
+	}
+
+	// with a (tiny) index the results are the same, as every product gets scored
+	Assert(t, model.BuildIndex(4, 2) == nil)
+	indexed, _, _ := model.TopN(1, 2)
+	Assert(t, indexed[0] == products[0] && indexed[1] == products[1], indexed, products)
+
+	_, _, err = model.TopN(9, 2)
+	Assert(t, err != nil)
+}
+
+func TestSimilarItems(t *testing.T) {
+	model := &Model{
+		X: Ones(1, 2),
+		// products 0 and 2 point the same way, product 1 is orthogonal, product 3 opposite
+		Y: MakeDenseMatrix([]float64{
+			1, 0, 2, -1,
+			1, 1, 2, -1}, 2, 4),
+	}
+	products, sims, err := model.SimilarItems(0, 2)
+	Assert(t, err == nil && len(products) == 2)
+	Assert(t, products[0] == 2 && math.Abs(sims[0]-1) < 1e-9, products, sims)
+	Assert(t, products[1] == 1, products)
+
+	_, _, err = model.SimilarItems(4, 2)
+	Assert(t, err != nil)
+
+	// with an index, only the candidates it returns are scored, which still finds most neighbors
+	rng := rand.New(rand.NewSource(9))
+	data := make([]float64, 8*300)
+	for n := range data {
+		data[n] = rng.NormFloat64()
+	}
+	model.X, model.Y = Ones(1, 8), MakeDenseMatrix(data, 8, 300)
+	want, _, _ := model.SimilarItems(7, 10)
+	Assert(t, model.BuildIndex(8, 4) == nil)
+	got, sims, err := model.SimilarItems(7, 10)
+	Assert(t, err == nil && len(got) == 10, err, got)
+	found := 0
+	for n, i := range got {
+		Assert(t, i != 7 && (n == 0 || sims[n] <= sims[n-1]), got, sims)
+		for _, j := range want {
+			if i == j {
+				found++
+			}
+		}
+	}
+	Assert(t, found >= 7, found, got, want)
+}
+
+func TestSimilarUsers(t *testing.T) {
+	model := &Model{
+		X: MakeDenseMatrix([]float64{
+			1, 1,
+			-1, 0,
+			3, 3.5,
+			0, 1}, 4, 2),
+		Y: Ones(2, 1),
+	}
+	users, sims, err := model.SimilarUsers(0, 3)
+	Assert(t, err == nil && len(users) == 3)
+	Assert(t, users[0] == 2 && users[1] == 3 && users[2] == 1, users)
+	Assert(t, sims[0] > sims[1] && sims[2] < 0, sims)
+
+	_, _, err = model.SimilarUsers(-1, 2)
+	Assert(t, err != nil)
+}
+
+func TestPredictPairs(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	model, _, _ := TrainModel(Q, 3, 5, 0.01)
+
+	pairs := make([][2]int, 0)
+	for n := 0; n < 3000; n++ {
+		pairs = append(pairs, [2]int{n % 4, n % 5})
+	}
+	pairs = append(pairs, [2]int{4, 0})
+	preds := model.PredictPairs(pairs)
+	Assert(t, len(preds) == len(pairs))
+	for n, pair := range pairs[:3000] {
+		pred, _ := model.Predict(pair[0], pair[1])
+		Assert(t, preds[n] == pred)
+	}
+	Assert(t, math.IsNaN(preds[3000]))
+	serial := model.PredictPairs(pairs, WithParallelism(1))
+	Assert(t, fmt.Sprint(serial) == fmt.Sprint(preds))
+}
+
+func TestNormalization(t *testing.T) {
+	// user 1 rates everything 2 points lower than user 0
+	Q := MakeDenseMatrix([]float64{5, 4, 5, 0,
+		3, 2, 0, 1,
+		4, 0, 4, 3}, 3, 4)
+
+	for _, method := range []NormalizationMethod{MeanCentering, ZScore, MinMax} {
+		for _, byItem := range []bool{false, true} {
+			model, _, _ := TrainModel(Q, 3, 20, 0.001, WithNormalization(method, byItem))
+			Assert(t, model.Norm != nil && model.Norm.ByItem == byItem)
+			// predictions of rated entries are back on the original scale
+			pred, _ := model.Predict(0, 0)
+			Assert(t, math.Abs(pred-5) < 0.1, method, byItem, pred)
+			Assert(t, math.Abs(model.Predictions().Get(1, 3)-1) < 0.1, method, byItem)
+			Assert(t, model.PredictPairs([][2]int{{0, 0}})[0] == pred)
+		}
+	}
+
+	centered := newNormalizer(Q, makeWeightMatrix(Q), MeanCentering, false)
+	Assert(t, centered.Offsets[0] == 14.0/3 && centered.Offsets[1] == 2)
+	Assert(t, centered.Normalize(1, 0, 3) == 1 && centered.Denormalize(1, 0, 1) == 3)
+
+	var buf bytes.Buffer
+	model, _, _ := TrainModel(Q, 3, 5, 0.01, WithNormalization(ZScore, false))
+	Assert(t, model.Save(&buf) == nil)
+	loaded, err := LoadModel(&buf)
+	Assert(t, err == nil && loaded.Norm != nil)
+	before, _ := model.Predict(2, 1)
+	after, _ := loaded.Predict(2, 1)
+	Assert(t, before == after)
+
+	// user 0 rates everything at their mean, so every normalized target is 0
+	flat := MakeDenseMatrix([]float64{3, 3, 3, 0,
+		3, 2, 0, 1,
+		4, 0, 4, 3}, 3, 4)
+	model, _, _ = TrainModel(flat, 3, 10, 0.01, WithNormalization(MeanCentering, false))
+	ids, _, _ := model.TopN(0, 4)
+	Assert(t, len(ids) == 1 && ids[0] == 3, ids)
+	Assert(t, len(model.Float32().Rated[0]) == 3)
+	Assert(t, model.productPopularity()[0] == 3)
+}
+
+func TestTimeDecay(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	// every rating is made at time 10, except user 2's rating of product 0 at time 0
+	T := Numbers(4, 5, 10)
+	T.Set(2, 0, 0)
+
+	model, _, _ := TrainModel(Q, 3, 10, 0.01, WithTimeDecay(T, 5))
+	Assert(t, model.W.Get(2, 0) == 0.25, model.W.Get(2, 0))
+	Assert(t, model.W.Get(2, 2) == 1 && model.W.Get(1, 0) == 0)
+
+	// implicit confidences decay towards that of an unobserved product
+	implicit, _ := TrainImplicitModel(Q, 3, 10, 0.01, WithTimeDecay(T, 5))
+	Assert(t, implicit.W.Get(2, 0) == 1+40*2*0.25, implicit.W.Get(2, 0))
+	Assert(t, implicit.W.Get(1, 0) == 1)
+}
+
+func TestContextModel(t *testing.T) {
+	// users love products 0-1 on weekdays and products 2-3 at weekends
+	weekday := MakeDenseMatrix([]float64{
+		5, 5, 1, 0, 0,
+		5, 0, 1, 1, 0,
+		0, 5, 1, 1, 0,
+		5, 5, 0, 1, 0}, 4, 5)
+	weekend := MakeDenseMatrix([]float64{
+		1, 0, 5, 5, 0,
+		1, 1, 5, 0, 0,
+		0, 1, 0, 5, 0,
+		1, 0, 5, 5, 0}, 4, 5)
+
+	model, err_value, err := TrainContextModel([]*DenseMatrix{weekday, weekend}, 2, 50, 0.1)
+	Assert(t, err == nil && err_value < 1, err_value)
+	weekdayPred, _ := model.Predict(2, 0, 0)
+	weekendPred, _ := model.Predict(2, 0, 1)
+	Assert(t, weekdayPred > weekendPred, weekdayPred, weekendPred)
+
+	// user 1 has rated 0-3 in some context, leaving only product 4
+	top, _, _ := model.TopN(1, 1, 3)
+	Assert(t, len(top) == 1 && top[0] == 4, top)
+
+	_, err = model.Predict(0, 0, 2)
+	Assert(t, err != nil)
+	_, _, err = TrainContextModel([]*DenseMatrix{weekday, Eye(3)}, 3, 20, 0.01)
+	Assert(t, err != nil)
+}
+
+func TestRerankMMR(t *testing.T) {
+	model := &Model{
+		// products 0 and 1 are near duplicates, product 2 is different
+		Y: MakeDenseMatrix([]float64{
+			1, 0.9, 0,
+			0, 0.1, 1}, 2, 3),
+	}
+	ids, scores := []int{0, 1, 2}, []float64{5, 4.9, 4}
+
+	top, topScores, err := model.RerankMMR(ids, scores, 2, 1)
+	Assert(t, err == nil && top[0] == 0 && top[1] == 1, top)
+	Assert(t, topScores[1] == 4.9)
+
+	// trading some relevance for diversity skips the near duplicate
+	top, topScores, _ = model.RerankMMR(ids, scores, 3, 0.5)
+	Assert(t, top[0] == 0 && top[1] == 2 && top[2] == 1, top)
+	Assert(t, topScores[1] == 4, topScores)
+
+	_, _, err = model.RerankMMR(ids, scores, 2, 2)
+	Assert(t, err != nil)
+	_, _, err = model.RerankMMR(ids, scores, -1, 0.5)
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, _, err = model.RerankMMR(ids, []float64{5, math.NaN(), 4}, 2, 0.5)
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+}
+
+func TestTopNDebiased(t *testing.T) {
+	model := &Model{
+		X: MakeDenseMatrix([]float64{1, 1}, 1, 2),
+		// product 0 scores a bit higher, but everyone else rated it
+		Y: MakeDenseMatrix([]float64{
+			3, 2, 0,
+			0, 0, 0}, 2, 3),
+		P: MakeDenseMatrix([]float64{
+			0, 0, 1,
+			4, 0, 0,
+			5, 0, 0,
+			3, 0, 0}, 4, 3),
+	}
+	model.W = makeWeightMatrix(model.P)
+	top, _, _ := model.TopNDebiased(0, 2, 0)
+	Assert(t, top[0] == 0 && top[1] == 1, top)
+
+	top, scores, _ := model.TopNDebiased(0, 2, 1)
+	Assert(t, top[0] == 1 && top[1] == 0, top)
+	Assert(t, scores[0] == 2 && scores[1] == 0.75, scores)
+}
+
+func TestExplain(t *testing.T) {
+	model := &Model{
+		X: MakeDenseMatrix([]float64{1, 1}, 1, 2),
+		Y: MakeDenseMatrix([]float64{
+			1, 0.9, 0, 1,
+			0, 0.1, 1, 0.1}, 2, 4),
+		W: MakeDenseMatrix([]float64{1, 1, 1, 0}, 1, 4),
+		P: MakeDenseMatrix([]float64{2, 5, 5, 0}, 1, 4),
+	}
+	// product 3 is close to products 0 and 1, and the user liked 1 more
+	ids, contributions, err := model.Explain(0, 3, 2)
+	Assert(t, err == nil && len(ids) == 2 && ids[0] == 1 && ids[1] == 0, ids)
+	Assert(t, contributions[0] > contributions[1], contributions)
+
+	_, _, err = model.Explain(0, 4, 2)
+	Assert(t, err != nil)
+}
+
+func TestWeightedLambda(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	model, err_value, _ := TrainModel(Q, 3, 10, 0.05, WithWeightedLambda())
+	Assert(t, model.WeightedLambda && err_value < 1, err_value)
+	// user 0 rated 4 products, product 3 was rated 3 times
+	Assert(t, model.lambdaFor(model.W.RowCopy(0), model.P.RowCopy(0)) == 0.2)
+	Assert(t, math.Abs(model.lambdaFor(model.W.ColCopy(3), model.P.ColCopy(3))-0.15) < 1e-12)
+
+	implicit, _ := TrainImplicitModel(Q, 3, 10, 0.05, WithWeightedLambda())
+	Assert(t, implicit.lambdaFor(implicit.W.RowCopy(1), implicit.P.RowCopy(1)) == 0.1)
+
+	var buf bytes.Buffer
+	Assert(t, model.Save(&buf) == nil)
+	loaded, _ := LoadModel(&buf)
+	Assert(t, loaded.WeightedLambda)
+}
+
+func TestSVDInit(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	// no iterations: the SVD starting point alone should already fit well
+	_, random_err, _ := TrainModel(Q, 3, 0, 0.01)
+	model, svd_err, _ := TrainModel(Q, 3, 0, 0.01, WithSVDInit())
+	Assert(t, svd_err < random_err/10, svd_err, random_err)
+	Assert(t, model.X.Rows() == 4 && model.Y.Cols() == 5)
+
+	// more factors than the rank of the (transposed) matrix are padded
+	model, err_value, _ := TrainModel(Q.Transpose(), 6, 5, 0.01, WithSVDInit())
+	Assert(t, model.X.Cols() == 6 && err_value < 1, err_value)
+}
+
+func TestFloat32(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01, WithNormalization(MeanCentering, false))
+	compact := model.Float32()
+
+	var buf bytes.Buffer
+	Assert(t, compact.Save(&buf) == nil)
+	compact, err := LoadModel32(&buf)
+	Assert(t, err == nil, err)
+
+	for u := 0; u < 4; u++ {
+		for i := 0; i < 5; i++ {
+			want, _ := model.Predict(u, i)
+			got, _ := compact.Predict(u, i)
+			Assert(t, math.Abs(want-got) < 1e-4, want, got)
+		}
+	}
+	want, _, _ := model.TopN(3, 2)
+	got, _, _ := compact.TopN(3, 2)
+	Assert(t, fmt.Sprint(want) == fmt.Sprint(got), want, got)
+	want, _, _ = model.SimilarItems(1, 3)
+	got, _, _ = compact.SimilarItems(1, 3)
+	Assert(t, fmt.Sprint(want) == fmt.Sprint(got), want, got)
+
+	// the copy keeps its own normalizer when the model grows
+	compact = model.Float32()
+	Assert(t, model.Update(4, 0, 3) == nil)
+	Assert(t, len(compact.Norm.Offsets) == 4 && len(model.Norm.Offsets) == 5)
+
+	// training in float32 follows training in float64
+	model, _, _ = TrainModel(Q, 3, 10, 0.01, WithNormalization(MeanCentering, false), WithSeed(5))
+	trained, err := TrainModel32(Q, 3, 10, 0.01, WithNormalization(MeanCentering, false), WithSeed(5))
+	Assert(t, err == nil && trained.Factors == 3 && len(trained.Rated[0]) == 4, err)
+	for u := 0; u < 4; u++ {
+		for i := 0; i < 5; i++ {
+			want, _ := model.Predict(u, i)
+			got, _ := trained.Predict(u, i)
+			Assert(t, math.Abs(want-got) < 1e-3, u, i, want, got)
+		}
+	}
+	for _, opt := range []Option{WithMaxNorm(-1), WithSVDInit(), WithConjugateGradient(3)} {
+		_, err = TrainModel32(Q, 3, 10, 0, opt)
+		Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	}
+}
+
+func TestQuantized(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	data := make([]float64, 60*40)
+	for n := range data {
+		if rng.Float64() < 0.3 {
+			data[n] = float64(1 + rng.Intn(5))
+		}
+	}
+	model, err := TrainImplicitModel(MakeDenseMatrix(data, 60, 40), 8, 5, 0.1)
+	Assert(t, err == nil, err)
+	q := model.Quantize()
+	Assert(t, len(q.X) == 60*8 && len(q.Y) == 40*8)
+
+	var buf bytes.Buffer
+	Assert(t, q.Save(&buf) == nil)
+	q, err = LoadModel8(&buf)
+	Assert(t, err == nil, err)
+
+	exact := q.Dequantize()
+	overlap := 0
+	for u := 0; u < 60; u++ {
+		for i := 0; i < 40; i++ {
+			want, _ := model.Predict(u, i)
+			got, _ := q.Predict(u, i)
+			Assert(t, math.Abs(want-got) < 0.05, want, got)
+			dequantized, _ := exact.Predict(u, i)
+			Assert(t, math.Abs(dequantized-got) < 1e-4, dequantized, got)
+		}
+		want, _, _ := model.TopN(u, 5)
+		got, _, _ := q.TopN(u, 5)
+		for _, a := range want {
+			for _, b := range got {
+				if a == b {
+					overlap++
+				}
+			}
+		}
+	}
+	// nearly the same top 5
+	Assert(t, overlap >= 60*5*9/10, overlap)
+
+	_, err = q.Predict(60, 0)
+	Assert(t, err != nil)
+	_, _, err = q.TopN(-1, 5)
+	Assert(t, err != nil)
+}
+
+func TestOutOfCore(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	dir, _ := ioutil.TempDir("", "als")
+	defer os.RemoveAll(dir)
+	path := dir + "/ratings"
+	err := WriteRatingFile(path, 4, 5, func(emit func(int, int, float64)) error {
+		for u := 0; u < 4; u++ {
+			for i := 0; i < 5; i++ {
+				emit(u, i, Q.Get(u, i))
+			}
+		}
+		return nil
+	})
+	Assert(t, err == nil, err)
+	r, err := OpenRatingFile(path)
+	Assert(t, err == nil, err)
+	defer r.Close()
+	Assert(t, r.Users() == 4 && r.Products() == 5 && r.Len() == 12)
+	sum := 0.0
+	err = r.EachProductRating(0, func(user int, rating float64) { sum += rating })
+	Assert(t, err == nil && sum == 12, sum)
+	for _, index := range []int{-1, 5} {
+		Assert(t, errors.Is(r.EachProductRating(index, func(int, float64) {}), ErrInvalidArgument), index)
+	}
+	Assert(t, errors.Is(r.EachUserRating(4, func(int, float64) {}), ErrInvalidArgument))
+
+	// same starting point and updates as the in-memory trainers, and a model that serves the same
+	model, _, _ := TrainModel(Q, 3, 5, 0.1)
+	streamed, err := TrainOutOfCore(r, 3, 5, 0.1)
+	Assert(t, err == nil, err)
+	Assert(t, ApproxEquals(model.X, streamed.X, 1e-6) && ApproxEquals(model.Y, streamed.Y, 1e-6))
+	top, _, _ := model.TopN(1, 2)
+	streamedTop, _, err := streamed.TopN(1, 2)
+	Assert(t, err == nil && fmt.Sprint(top) == fmt.Sprint(streamedTop), top, streamedTop)
+	Assert(t, streamed.MaxRating == 5 && streamed.Iterations == 5)
+
+	implicit, _ := TrainImplicitModel(Q, 3, 5, 0.1)
+	var losses []float64
+	streamed, _ = TrainImplicitOutOfCore(r, 3, 5, 0.1, OnIteration(func(iter int, loss float64) {
+		losses = append(losses, loss)
+	}))
+	Assert(t, ApproxEquals(implicit.X, streamed.X, 1e-6) && ApproxEquals(implicit.Y, streamed.Y, 1e-6))
+	Assert(t, streamed.Implicit && ApproxEquals(implicit.W, streamed.W, 1e-12))
+	want := getErrorInline(implicit.W, implicit.P, implicit.X, implicit.Y)
+	Assert(t, math.Abs(losses[4]-want) < 1e-6*want, losses[4], want)
+
+	_, err = OpenRatingFile(dir)
+	Assert(t, err != nil)
+
+	// a second pass that moves a rating to another user or drops one is caught
+	for _, change := range [][3]int{{0, 0, 1}, {0, 0, -1}} {
+		pass := 0
+		err = WriteRatingFile(dir+"/changed", 4, 5, func(emit func(int, int, float64)) error {
+			pass++
+			for u := 0; u < 4; u++ {
+				for i := 0; i < 5; i++ {
+					if pass == 2 && u == change[0] && i == change[1] {
+						if change[2] >= 0 {
+							emit(change[2], i, Q.Get(u, i))
+						}
+						continue
+					}
+					emit(u, i, Q.Get(u, i))
+				}
+			}
+			return nil
+		})
+		Assert(t, err != nil, change)
+	}
+}
+
+func TestDistributed(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	dir, _ := ioutil.TempDir("", "als")
+	defer os.RemoveAll(dir)
+	WriteRatingFile(dir+"/ratings", 4, 5, func(emit func(int, int, float64)) error {
+		for u := 0; u < 4; u++ {
+			for i := 0; i < 5; i++ {
+				emit(u, i, Q.Get(u, i))
+			}
+		}
+		return nil
+	})
+	r, _ := OpenRatingFile(dir + "/ratings")
+	defer r.Close()
+
+	var addrs []string
+	for k := 0; k < 3; k++ {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Skip("cannot listen on localhost: ", err)
+		}
+		defer l.Close()
+		go ServeWorker(l)
+		addrs = append(addrs, l.Addr().String())
+	}
+	c, err := Dial(addrs)
+	Assert(t, err == nil, err)
+	defer c.Close()
+
+	for _, implicit := range []bool{false, true} {
+		// the workers' shares of the error add up to the error over the whole file
+		var losses, wantLosses []float64
+		X, Y, err := c.Train(r, 3, 5, 0.1, implicit, WithWeightedLambda(), OnIteration(func(_ int, loss float64) {
+			losses = append(losses, loss)
+		}))
+		Assert(t, err == nil, err)
+		wantX, wantY, _ := trainOutOfCore(r, 3, 5, 0.1, implicit, []Option{WithWeightedLambda(), OnIteration(func(_ int, loss float64) {
+			wantLosses = append(wantLosses, loss)
+		})})
+		Assert(t, ApproxEquals(X, wantX, 1e-9) && ApproxEquals(Y, wantY, 1e-9), implicit)
+		Assert(t, len(losses) == 5 && len(wantLosses) == 5, losses)
+		for k := range losses {
+			Assert(t, math.Abs(losses[k]-wantLosses[k]) < 1e-9, losses, wantLosses)
+		}
+	}
+
+	// streaming the partitions in small chunks gives the same result
+	defer func(chunk int) { distributeChunk = chunk }(distributeChunk)
+	distributeChunk = 2
+	X, Y, err := c.Train(r, 3, 5, 0.1, false)
+	Assert(t, err == nil, err)
+	wantX, wantY, _ := trainOutOfCore(r, 3, 5, 0.1, false, nil)
+	Assert(t, ApproxEquals(X, wantX, 1e-9) && ApproxEquals(Y, wantY, 1e-9))
+}
+
+// run with -race: RPCs can reach a worker concurrently
+func TestWorkerConcurrency(t *testing.T) {
+	w := &Worker{}
+	part := &Partition{
+		Users:    map[int][]Rated{0: {{0, 5}, {1, 3}}, 1: {{1, 4}}},
+		Products: map[int][]Rated{0: {{0, 5}}, 1: {{0, 3}, {1, 4}}},
+	}
+	var ok bool
+	Assert(t, w.Load(part, &ok) == nil)
+	args := &SolveArgs{Factors: [][]float64{{1, 0}, {0, 1}}, Lambda: 0.1}
+
+	done := make(chan error)
+	for k := 0; k < 8; k++ {
+		go func(k int) {
+			var reply SolveReply
+			if k%2 == 0 {
+				done <- w.Append(&Partition{Users: map[int][]Rated{2 + k: {{0, 1}}}}, &ok)
+				return
+			}
+			if err := w.SolveUsers(args, &reply); err != nil {
+				done <- err
+				return
+			}
+			done <- w.SolveProducts(args, &reply)
+		}(k)
+	}
+	for k := 0; k < 8; k++ {
+		Assert(t, <-done == nil)
+	}
+	var reply SolveReply
+	Assert(t, w.SolveUsers(args, &reply) == nil && len(reply.Indices) == 6, reply.Indices)
+}
+
+func TestClipping(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01, WithClipping())
+	Assert(t, model.Clip && model.MinRating == 1 && model.MaxRating == 5)
+	for _, pred := range append(model.Predictions().Array(), model.PredictPairs([][2]int{{1, 0}, {3, 2}})...) {
+		Assert(t, pred >= 1 && pred <= 5, pred)
+	}
+
+	model.Update(1, 0, 0.5)
+	Assert(t, model.MinRating == 0.5)
+	model.Clip = false
+	raw, _ := model.Predict(1, 0)
+	model.Clip = true
+	clipped, _ := model.Predict(1, 0)
+	Assert(t, clipped == math.Max(0.5, math.Min(5, raw)), raw, clipped)
+}
+
+func TestConstraints(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	factors := func(m *Model) [][]float64 {
+		return append(m.X.Arrays(), m.Y.Transpose().Arrays()...)
+	}
+
+	model, _, err := TrainModel(Q, 3, 10, 0.01, WithNonNegative())
+	Assert(t, err == nil && model.NonNegative, err)
+	model.Update(1, 0, 1)
+	for _, x := range factors(model) {
+		for _, v := range x {
+			Assert(t, v >= 0, x)
+		}
+	}
+
+	for _, opt := range [][]Option{
+		{WithMaxNorm(2)},
+		{WithMaxNorm(2), WithConjugateGradient(3)},
+		{WithMaxNorm(2), WithNonNegative(), WithSVDInit()},
+	} {
+		model, _, err = TrainModel(Q, 3, 10, 0.01, opt...)
+		Assert(t, err == nil && model.MaxNorm == 2, err)
+		model.Update(4, 1, 5)
+		for _, x := range factors(model) {
+			Assert(t, math.Sqrt(dot(x, x)) <= 2+1e-9, x)
+		}
+		// predictions are bounded by the squared norm
+		for _, pred := range model.Predictions().Array() {
+			Assert(t, math.Abs(pred) <= 4+1e-9, pred)
+		}
+	}
+
+	sgd, _, err := TrainSGD(Q, 3, 20, 0.01, 0.05, WithNonNegative(), WithMaxNorm(3))
+	Assert(t, err == nil)
+	warp, err := TrainWARP(Q, 3, 20, 0.01, 0.05, WithNonNegative(), WithMaxNorm(3))
+	Assert(t, err == nil)
+	for _, m := range []*Model{sgd, warp} {
+		for _, x := range factors(m) {
+			for _, v := range x {
+				Assert(t, v >= 0, x)
+			}
+			Assert(t, math.Sqrt(dot(x, x)) <= 3+1e-9, x)
+		}
+	}
+
+	// the constraints survive saving
+	var buf bytes.Buffer
+	model.Save(&buf)
+	loaded, _ := LoadModel(&buf)
+	Assert(t, loaded.NonNegative && loaded.MaxNorm == 2 && loaded.Metadata().Hyperparameters["max_norm"] == 2)
+
+	_, _, err = TrainModel(Q, 3, 10, 0.01, WithMaxNorm(0))
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+}
+
+func TestGroupTopN(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1, 0,
+		0, 0, 0, 4, 1, 0,
+		2, 0, 4, 1, 0, 0,
+		5, 2, 0, 1, 0, 0}, 4, 6)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01)
+
+	// products rated by any member are skipped
+	ids, _, err := model.TopNForGroup([]int{0, 1}, 5, Average)
+	Assert(t, err == nil && fmt.Sprint(ids) == "[5]", ids, err)
+
+	for _, strategy := range []Aggregation{Average, LeastMisery, MostPleasure} {
+		ids, scores, err := model.TopNForGroup([]int{1, 3, 3}, 3, strategy)
+		Assert(t, err == nil && len(ids) == 2 && scores[0] >= scores[1], ids, scores, err)
+		for k, i := range ids {
+			a, _ := model.Predict(1, i)
+			b, _ := model.Predict(3, i)
+			want := (a + b) / 2
+			if strategy == LeastMisery {
+				want = math.Min(a, b)
+			} else if strategy == MostPleasure {
+				want = math.Max(a, b)
+			}
+			Assert(t, math.Abs(scores[k]-want) < 1e-12, strategy, scores[k], want)
+		}
+	}
+
+	ids, _, _ = model.TopNForGroup([]int{1, 3}, 3, Average, Blocklist(5))
+	Assert(t, fmt.Sprint(ids) == "[2]", ids)
+
+	// a group of one is the user's own top N
+	ids, _, _ = model.TopNForGroup([]int{2}, 2, LeastMisery)
+	want, _, _ := model.TopN(2, 2)
+	Assert(t, fmt.Sprint(ids) == fmt.Sprint(want), ids, want)
+
+	_, _, err = model.TopNForGroup(nil, 2, Average)
+	Assert(t, err != nil)
+	_, _, err = model.TopNForGroup([]int{0, 4}, 2, Average)
+	Assert(t, err != nil)
+}
+
+type coldStartFunc func(user, n int, keep func(int) bool) ([]int, []float64)
+
+func (f coldStartFunc) ColdStart(user, n int, keep func(int) bool) ([]int, []float64) {
+	return f(user, n, keep)
+}
+
+func TestBreakdown(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1, 0,
+		0, 0, 0, 4, 1, 0,
+		2, 0, 4, 1, 0, 0,
+		5, 2, 0, 1, 0, 0}, 4, 6)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01, WithNormalization(MeanCentering, false), WithClipping())
+	d, err := model.TopNDebiasedDebug(1, 3, 0.5, Blocklist(0), Blocklist(0, 1))
+	Assert(t, err == nil, err)
+	ids, scores, _ := model.TopNDebiased(1, 3, 0.5, Blocklist(0), Blocklist(0, 1))
+	Assert(t, d.Rated == 2 && d.Candidates == 2 && fmt.Sprint(d.Filtered) == "[1 2]", d)
+	Assert(t, len(d.Items) == len(ids), d.Items, ids)
+	for k, b := range d.Items {
+		Assert(t, b.Product == ids[k] && b.Score == scores[k], b)
+		// the components add up to the score
+		score := math.Max(1, math.Min(5, b.Dot*b.Scale+b.Offset)) / b.Discount
+		Assert(t, math.Abs(score-b.Score) < 1e-12 && b.Offset != 0 && b.Discount >= 1, b)
+		Assert(t, math.Abs(b.Dot*b.Scale+b.Offset+b.Clipped-b.ALS*b.Discount) < 1e-12, b)
+		Assert(t, strings.HasPrefix(b.String(), fmt.Sprintf("product %d: score", b.Product)), b.String())
+	}
+	Assert(t, strings.Count(d.String(), "\n") == len(d.Items), d.String())
+	_, err = model.TopNDebug(10, 3)
+	Assert(t, err != nil)
+
+	hybrid := NewHybrid(model, 2, 0.5)
+	d, err = hybrid.TopNDebug(2, 2)
+	ids, scores, _ = hybrid.TopN(2, 2)
+	Assert(t, err == nil && len(d.Items) == len(ids) && !d.ColdStart, d, err)
+	for k, b := range d.Items {
+		Assert(t, b.Product == ids[k] && b.Score == scores[k] && b.Weight == 0.5, b)
+		want := b.ALS
+		if b.Neighbors {
+			want = 0.5*b.ALS + 0.5*b.KNN
+		}
+		Assert(t, math.Abs(want-b.Score) < 1e-12, b)
+	}
+
+	hybrid.ColdStart = coldStartFunc(func(user, n int, keep func(int) bool) ([]int, []float64) {
+		return []int{4}, []float64{7}
+	})
+	d, err = hybrid.TopNDebug(4, 2)
+	Assert(t, err == nil && d.ColdStart && len(d.Items) == 1 && d.Items[0].Score == 7, d, err)
+}
+
+func TestConfidence(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01)
+	// user 0 rated 4 products, product 0 was rated by 3 users
+	pred, confidence, err := model.PredictWithConfidence(0, 0)
+	want, _ := model.Predict(0, 0)
+	Assert(t, err == nil && pred == want)
+	// scaled down for factors shorter than the mean
+	norm := func(f, all *DenseMatrix) float64 {
+		sum := 0.0
+		for _, v := range all.Arrays() {
+			sum += math.Sqrt(dot(v, v))
+		}
+		v := f.Array()
+		return math.Min(1, math.Sqrt(dot(v, v))/(sum/float64(all.Rows())))
+	}
+	support := norm(model.X.GetRowVector(0), model.X) * norm(model.Y.GetColVector(0).Transpose(), model.Y.Transpose())
+	Assert(t, support > 0 && support <= 1, support)
+	Assert(t, math.Abs(confidence-4.0/9*3.0/8*support) < 1e-12, confidence, support)
+
+	// a new user knows nothing
+	model.Update(4, 0, 0)
+	confidence, _ = model.Confidence(4, 0)
+	Assert(t, confidence == 0)
+	_, err = model.Confidence(5, 0)
+	Assert(t, err != nil)
+}
+
+func TestFilters(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 0, 0, 0, 0, 0,
+		0, 0, 0, 4, 1, 0,
+		2, 0, 4, 1, 0, 3,
+		5, 2, 0, 1, 0, 0}, 4, 6)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01)
+	inStock := func(product int) bool { return product != 3 }
+
+	// 5 unrated products, 2 blocked: the remaining 3 are all returned
+	top, _, _ := model.TopN(0, 3, Blocklist(1), inStock)
+	Assert(t, len(top) == 3, top)
+	for _, i := range top {
+		Assert(t, i != 0 && i != 1 && i != 3, top)
+	}
+
+	top, _, _ = model.TopN(0, 3, Allowlist(2, 5))
+	Assert(t, len(top) == 2, top)
+
+	// the index gets the filters before ranking too
+	model.BuildIndex(4, 2)
+	top, _, _ = model.TopNDebiased(0, 3, 0.5, Blocklist(1), inStock)
+	Assert(t, len(top) == 3, top)
+	for _, i := range top {
+		Assert(t, i != 0 && i != 1 && i != 3, top)
+	}
+}
+
+func TestExport(t *testing.T) {
+	model := &Model{
+		X: MakeDenseMatrix([]float64{1, 2.5, -3, 4}, 2, 2),
+		Y: MakeDenseMatrix([]float64{1, 2, 3, 4, 5, 6}, 2, 3),
+	}
+	var buf bytes.Buffer
+	Assert(t, WriteCSV(&buf, model.UserFactors()) == nil)
+	Assert(t, buf.String() == "1,2.5\n-3,4\n", buf.String())
+	buf.Reset()
+	Assert(t, WriteCSV(&buf, model.ProductFactors()) == nil)
+	Assert(t, buf.String() == "1,4\n2,5\n3,6\n", buf.String())
+
+	buf.Reset()
+	Assert(t, WriteNpy(&buf, model.UserFactors()) == nil)
+	npy := buf.Bytes()
+	data := 10 + int(binary.LittleEndian.Uint16(npy[8:]))
+	Assert(t, string(npy[:8]) == "\x93NUMPY\x01\x00" && data%64 == 0 && len(npy) == data+4*8, len(npy))
+	Assert(t, strings.Contains(string(npy[:data]), "'shape': (2, 2)") && npy[data-1] == '\n')
+	Assert(t, math.Float64frombits(binary.LittleEndian.Uint64(npy[data+8:])) == 2.5)
+}
+
+func TestImportFactors(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	model, _, _ := TrainModel(Q, 3, 10, 0.01)
+
+	var users, products bytes.Buffer
+	WriteCSV(&users, model.UserFactors())
+	WriteNpy(&products, model.ProductFactors())
+	X, err := ReadCSV(&users)
+	Assert(t, err == nil, err)
+	Yt, err := ReadNpy(&products)
+	Assert(t, err == nil, err)
+
+	imported, err := ModelFromFactors(X, Yt, Q, 0.01, false)
+	Assert(t, err == nil, err)
+	Assert(t, ApproxEquals(imported.Predictions(), model.Predictions(), 1e-12))
+	want, _, _ := model.TopN(1, 2)
+	got, _, _ := imported.TopN(1, 2)
+	Assert(t, fmt.Sprint(want) == fmt.Sprint(got), want, got)
+	Assert(t, imported.Update(1, 0, 3) == nil)
+
+	// no known ratings
+	imported, _ = ModelFromFactors(X, Yt, nil, 0.01, true)
+	top, _, _ := imported.TopN(0, 5)
+	Assert(t, len(top) == 5)
+
+	_, err = ModelFromFactors(X, Eye(2), nil, 0.01, false)
+	Assert(t, err != nil)
+	_, err = ReadNpy(strings.NewReader("not numpy"))
+	Assert(t, err != nil)
+}
+
+func TestMetadata(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 0, 1,
+		0, 1, 4, 1,
+		2, 0, 4, 0}, 3, 4)
+	m, _, _ := TrainModel(Q, 2, 5, 0.01, WithWeightedLambda())
+	meta := m.Metadata()
+	Assert(t, meta.Version == FormatVersion && meta.Algorithm == AlgorithmALS && !meta.TrainedAt.IsZero())
+	Assert(t, meta.Hyperparameters["factors"] == 2 && meta.Hyperparameters["lambda"] == 0.01 && meta.Hyperparameters["weighted_lambda"] == 1, meta)
+	Assert(t, meta.Fingerprint == Fingerprint(Q) && meta.Fingerprint != Fingerprint(Q.Transpose()))
+	implicit, _ := TrainImplicitModel(Q, 2, 2, 0.01)
+	Assert(t, implicit.Metadata().Algorithm == AlgorithmImplicitALS)
+
+	var buf bytes.Buffer
+	Assert(t, m.Save(&buf) == nil)
+	loaded, err := LoadModel(&buf)
+	Assert(t, err == nil, err)
+	lmeta := loaded.Metadata()
+	Assert(t, lmeta.Fingerprint == meta.Fingerprint && lmeta.TrainedAt.Equal(meta.TrainedAt) && lmeta.Hyperparameters["factors"] == 2, lmeta)
+	Assert(t, m.Clone().Metadata().Fingerprint == meta.Fingerprint)
+
+	// models from a newer version, or with an inconsistent algorithm, are refused;
+	// models saved before metadata existed are fine
+	Assert(t, Metadata{Version: FormatVersion + 1}.compatible(false) != nil)
+	Assert(t, Metadata{Algorithm: AlgorithmImplicitALS}.compatible(false) != nil)
+	Assert(t, Metadata{Algorithm: "bpr"}.compatible(false) != nil)
+	Assert(t, Metadata{}.compatible(true) == nil)
+}
+
+func TestInitialModel(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 5, 0, 1,
+		0, 0, 0, 4, 1,
+		2, 0, 4, 1, 0,
+		5, 2, 0, 1, 0}, 4, 5)
+	yesterday, _, _ := TrainModel(Q, 3, 20, 0.01)
+
+	// a new user and a new rating came in overnight
+	Q2, _ := Q.Stack(MakeDenseMatrix([]float64{4, 0, 5, 0, 0}, 1, 5))
+	Q2.Set(1, 0, 3)
+	_, cold, _ := TrainModel(Q2, 3, 2, 0.01)
+	warm, warmErr, _ := TrainModel(Q2, 3, 2, 0.01, WithInitialModel(yesterday))
+	Assert(t, warm.Iterations == 2 && warm.X.Rows() == 5)
+	Assert(t, warmErr < cold, warmErr, cold)
+
+	// the initial model needs the same number of factors
+	_, _, err := TrainModel(Q2, 2, 2, 0.01, WithInitialModel(yesterday))
+	Assert(t, errors.Is(err, ErrDimensionMismatch), err)
+}
+
+func TestSGDLosses(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 0, 1,
+		0, 1, 4, 1,
+		2, 0, 4, 0,
+		5, 4, 0, 1}, 4, 4)
+	m, loss, _ := TrainSGD(Q, 3, 300, 0.001, 0.02)
+	Assert(t, loss < 0.05, loss)
+	Assert(t, m.Metadata().Algorithm == AlgorithmSGD && len(m.History.Iterations) == 300)
+	pred, _ := m.Predict(0, 0)
+	Assert(t, math.Abs(pred-5) < 0.5, pred)
+
+	// binary interaction prediction: interactions should score above the rest
+	for _, l := range []Loss{LogisticLoss{}, HingeLoss{}} {
+		m, _, _ = TrainSGD(Q, 3, 200, 0.01, 0.05, WithLoss(l), WithNegativeSamples(2))
+		Qhat := m.Predictions()
+		for u := 0; u < 4; u++ {
+			for i := 0; i < 4; i++ {
+				if Q.Get(u, i) != 0 {
+					Assert(t, Qhat.Get(u, i) > 0, l, u, i, Qhat)
+				}
+			}
+		}
+	}
+
+	Assert(t, HingeLoss{}.Gradient(2, 1) == 0 && HingeLoss{}.Gradient(0.5, 1) == -1 && HingeLoss{}.Gradient(0, 0) == 1)
+	Assert(t, math.Abs(LogisticLoss{}.Loss(0, 1)-math.Ln2) < 1e-12)
+}
+
+func TestWARP(t *testing.T) {
+	R := MakeDenseMatrix([]float64{1, 1, 0, 0, 0, 1,
+		0, 1, 1, 0, 0, 0,
+		1, 0, 0, 1, 1, 0,
+		0, 0, 1, 1, 0, 0}, 4, 6)
+	m, _ := TrainWARP(R, 3, 200, 0.01, 0.05, WithSeed(3))
+	Assert(t, m.Metadata().Algorithm == AlgorithmWARP && m.Implicit)
+	// every user's interactions end up ranked above all other products
+	Qhat := m.Predictions()
+	for u := 0; u < 4; u++ {
+		lowest, highest := math.Inf(1), math.Inf(-1)
+		for i := 0; i < 6; i++ {
+			if R.Get(u, i) != 0 {
+				lowest = math.Min(lowest, Qhat.Get(u, i))
+			} else {
+				highest = math.Max(highest, Qhat.Get(u, i))
+			}
+		}
+		Assert(t, lowest > highest, u, Qhat)
+	}
+	Assert(t, m.History.Iterations[199].TrainingError < m.History.Iterations[0].TrainingError, m.History.Iterations[199])
+}
+
+func TestEALS(t *testing.T) {
+	R := MakeDenseMatrix([]float64{1, 1, 0, 0, 0, 1,
+		0, 1, 1, 0, 0, 0,
+		1, 0, 0, 1, 2, 0,
+		0, 0, 1, 1, 0, 0}, 4, 6)
+	_, err := TrainEALS(Zeros(2, 2), 2, 5, 0.1, 4, 0.5)
+	Assert(t, err != nil)
+
+	m, err := TrainEALS(R, 3, 30, 0.01, 4, 0.5)
+	Assert(t, err == nil, err)
+	Assert(t, m.Metadata().Algorithm == AlgorithmEALS && m.Iterations == 30)
+	// coordinate descent never increases the objective, which matches the dense weighted error
+	for n := 1; n < 30; n++ {
+		Assert(t, m.History.Iterations[n].TrainingError <= m.History.Iterations[n-1].TrainingError+1e-9, n)
+	}
+	dense := 0.0
+	Qhat := m.Predictions()
+	for u := 0; u < 4; u++ {
+		for i := 0; i < 6; i++ {
+			d := m.P.Get(u, i) - Qhat.Get(u, i)
+			dense += m.W.Get(u, i) * d * d
+		}
+	}
+	Assert(t, math.Abs(dense-m.History.Iterations[29].TrainingError) < 1e-6, dense, m.History.Iterations[29])
+	// interactions are predicted well above the rest
+	for u := 0; u < 4; u++ {
+		for i := 0; i < 6; i++ {
+			if R.Get(u, i) != 0 {
+				Assert(t, Qhat.Get(u, i) > 0.5, u, i, Qhat)
+			}
+		}
+	}
+}
+
+func TestLazyPredictions(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 0, 1,
+		0, 1, 4, 1,
+		2, 0, 4, 0}, 3, 4)
+	m, _, _ := TrainModel(Q, 2, 5, 0.01, WithNormalization(MeanCentering, false))
+	Qhat := m.Predictions()
+
+	preds, err := m.PredictUser(1)
+	Assert(t, err == nil && len(preds) == 4)
+	for i, p := range preds {
+		Assert(t, math.Abs(p-Qhat.Get(1, i)) < 1e-9, i, p)
+	}
+	sub, err := m.PredictUsers([]int{2, 0})
+	Assert(t, err == nil && sub.Rows() == 2)
+	Assert(t, math.Abs(sub.Get(0, 1)-Qhat.Get(2, 1)) < 1e-9 && math.Abs(sub.Get(1, 3)-Qhat.Get(0, 3)) < 1e-9)
+	_, err = m.PredictUsers([]int{0, 3})
+	Assert(t, err != nil)
+
+	products, missing, err := m.PredictMissing(2)
+	Assert(t, err == nil && len(products) == 2 && products[0] == 1 && products[1] == 3, products)
+	Assert(t, math.Abs(missing[1]-Qhat.Get(2, 3)) < 1e-9)
+	_, _, err = m.PredictMissing(-1)
+	Assert(t, err != nil)
+}
+
+func TestObservedZeros(t *testing.T) {
+	// 0 is a legitimate rating here, -1 means not rated
+	Q := MakeDenseMatrix([]float64{0, 5, -1, 1,
+		-1, 1, 4, 0,
+		2, 0, 4, -1}, 3, 4)
+	mask := MakeDenseMatrix([]float64{1, 1, 0, 1,
+		0, 1, 1, 1,
+		1, 1, 1, 0}, 3, 4)
+	m, _, _ := TrainModel(Q, 2, 10, 0.01, WithMissingValue(-1))
+	Assert(t, Equals(m.W, mask), m.W)
+	Assert(t, m.P.Get(0, 0) == 0 && m.P.Get(0, 2) == 0 && m.MinRating == 0 && m.MaxRating == 5, m.P)
+
+	// an explicit mask gives the same model, whatever the unobserved entries hold
+	filled := Q.Copy()
+	filled.Set(0, 2, 3)
+	withMask, _, _ := TrainModel(filled, 2, 10, 0.01, WithObserved(mask))
+	Assert(t, ApproxEquals(m.X, withMask.X, 1e-9) && ApproxEquals(m.Y, withMask.Y, 1e-9))
+
+	// rated 0s pull the normalization down, and are validated on
+	centered, _, _ := TrainModel(Q, 2, 10, 0.01, WithMissingValue(-1), WithNormalization(MeanCentering, false))
+	Assert(t, centered.Norm.Offsets[0] == 2, centered.Norm.Offsets)
+	V := Numbers(3, 4, -1)
+	V.Set(1, 0, 0)
+	validated, _, _ := TrainModel(Q, 2, 3, 0.01, WithMissingValue(-1), WithValidation(V, 0))
+	pred, _ := validated.Predict(1, 0)
+	Assert(t, math.Abs(validated.History.Iterations[2].ValidationError-math.Abs(pred)) < 1e-9, validated.History.Iterations[2], pred)
+
+	implicit, _ := TrainImplicitModel(Q, 2, 2, 0.01, WithObserved(mask))
+	Assert(t, Equals(implicit.P, mask) && implicit.W.Get(0, 0) == 1 && implicit.W.Get(0, 1) == 201)
+
+	// the model remembers that 0 is a rating: TopN skips it, Update records it and validation scores it
+	Assert(t, m.MissingValue == -1 && math.IsNaN(withMask.MissingValue))
+	ids, _, _ := m.TopN(0, 4)
+	Assert(t, len(ids) == 1 && ids[0] == 2, ids)
+	Assert(t, m.productPopularity()[0] == 2, m.productPopularity())
+	Assert(t, m.Update(1, 0, 0) == nil && m.W.Get(1, 0) == 1 && m.P.Get(1, 0) == 0)
+	ids, _, _ = m.TopN(1, 4)
+	Assert(t, len(ids) == 0, ids)
+	Assert(t, m.Update(1, 0, -1) == nil && m.W.Get(1, 0) == 0)
+	Assert(t, withMask.Update(1, 0, 0) == nil && withMask.W.Get(1, 0) == 1)
+	pred, _ = m.Predict(1, 0)
+	Assert(t, math.Abs(m.ValidationError(V)-math.Abs(pred)) < 1e-9, m.ValidationError(V), pred)
+
+	var buf bytes.Buffer
+	Assert(t, m.Save(&buf) == nil)
+	loaded, _ := LoadModel(&buf)
+	Assert(t, loaded.MissingValue == -1 && loaded.Clone().MissingValue == -1)
+
+	top, err := GetTopNRecommendations(Q, m.Predictions(), 0, 1, nil, WithMissingValue(-1))
+	Assert(t, err == nil && fmt.Sprint(top) == "[2]", top, err)
+
+	// implicit validation only scores the held out cells, not the -1s
+	implicit, _ = TrainImplicitModel(Q, 2, 2, 0.01, WithMissingValue(-1))
+	held := Zeros(3, 4)
+	held.Set(1, 0, 2)
+	V.Set(1, 0, 2)
+	Assert(t, implicit.validationError(V, notMissing(V, -1)) == implicit.validationError(held, makeWeightMatrix(held)))
+
+	contexts := []*DenseMatrix{Q, Q}
+	cm, _, err := TrainContextModel(contexts, 2, 2, 0.01, WithMissingValue(-1))
+	Assert(t, err == nil && Equals(cm.W[1], mask), err)
+	_, _, err = TrainContextModel(contexts, 2, 2, 0.01, WithObserved(mask))
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+
+	imported, err := ModelFromFactors(m.UserFactors(), m.ProductFactors(), Q, 0.01, false, WithMissingValue(-1))
+	Assert(t, err == nil && Equals(imported.W, mask) && imported.MinRating == 0, imported.W)
+	ids, _, _ = imported.TopN(0, 4)
+	Assert(t, len(ids) == 1 && ids[0] == 2, ids)
+}
+
+func TestPostProcessing(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 0, 1,
+		0, 1, 4, 1,
+		2, 0, 4, 0}, 3, 4)
+	m, _, _ := TrainModel(Q, 2, 5, 0.01)
+	raw := m.Predictions()
+	scaled := m.PredictionsWith(RowMinMax())
+	for u := 0; u < 3; u++ {
+		min, max := math.Inf(1), math.Inf(-1)
+		for i := 0; i < 4; i++ {
+			min, max = math.Min(min, scaled.Get(u, i)), math.Max(max, scaled.Get(u, i))
+			// the order within a row is kept
+			for j := 0; j < 4; j++ {
+				Assert(t, (raw.Get(u, i) < raw.Get(u, j)) == (scaled.Get(u, i) < scaled.Get(u, j)))
+			}
+		}
+		Assert(t, min == 0 && max == 1, u, min, max)
+	}
+
+	// steps are chained in order
+	row, _ := m.PredictUser(0, RowZScore(), Clamp(-1, 1))
+	for _, s := range row {
+		Assert(t, s >= -1 && s <= 1, row)
+	}
+	trained, _, _ := Train(Q, 2, 5, 0.01, WithPostProcessing(RowMinMax()))
+	Assert(t, ApproxEquals(trained, scaled, 1e-9))
+
+	flat := []float64{2, 2}
+	RowMinMax()(0, flat)
+	RowZScore()(0, []float64{})
+	Assert(t, flat[0] == 0 && flat[1] == 0)
+}
+
+func TestCholesky(t *testing.T) {
+	// the textbook example with L = [2 0 0; 6 1 0; -8 5 3]
+	A := MakeDenseMatrix([]float64{4, 12, -16,
+		12, 37, -43,
+		-16, -43, 98}, 3, 3)
+	chol, err := factorCholesky(A)
+	Assert(t, err == nil, err)
+	Assert(t, fmt.Sprint(chol.l) == "[2 0 0 6 1 0 -8 5 3]", chol.l)
+	x := chol.solve([]float64{-20, -43, 192})
+	for n, want := range []float64{1, 2, 3} {
+		Assert(t, math.Abs(x[n]-want) < 1e-9, x)
+	}
+
+	for _, bad := range []*DenseMatrix{
+		MakeDenseMatrix([]float64{1, 2, 2, 4}, 2, 2),
+		MakeDenseMatrix([]float64{1, 0, 0, -1}, 2, 2),
+		MakeDenseMatrix([]float64{math.NaN(), 0, 0, 1}, 2, 2),
+	} {
+		_, err = factorCholesky(bad)
+		Assert(t, errors.Is(err, ErrSingularSystem), bad, err)
+	}
+	_, err = factorCholesky(Zeros(2, 3))
+	Assert(t, errors.Is(err, ErrDimensionMismatch), err)
+}
+
+func TestParallelSolve(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	F := make([][]float64, 40)
+	for r := range F {
+		F[r] = []float64{rng.Float64(), rng.Float64(), rng.Float64()}
+	}
+	// many systems sharing a few left hand sides
+	W, Q, X0, lambdas := make([][]float64, 100), make([][]float64, 100), make([][]float64, 100), make([]float64, 100)
+	for n := range W {
+		W[n], Q[n], X0[n] = make([]float64, 40), make([]float64, 40), make([]float64, 3)
+		for r := range F {
+			if (r+n)%3 != 0 {
+				W[n][r] = 1
+			}
+			Q[n][r] = 5 * rng.Float64()
+		}
+		lambdas[n] = 0.1
+	}
+	cfg := newConfig([]Option{WithParallelism(4)})
+	solutions, errs := cfg.solveAll(F, W, Q, X0, lambdas)
+	for n := range W {
+		Assert(t, errs[n] == nil, errs[n])
+		single, _ := cfg.solveFactors(F, W[n], Q[n], X0[n], lambdas[n])
+		for k := range single {
+			Assert(t, math.Abs(single[k]-solutions[n][k]) < 1e-9, n, single, solutions[n])
+		}
+	}
+
+	// a system that isn't positive definite fails on its own
+	lambdas[7] = -100
+	_, errs = cfg.solveAll(F, W, Q, X0, lambdas)
+	Assert(t, errs[7] != nil && errs[8] == nil, errs[7], errs[8])
+
+	// the number of goroutines doesn't change the model
+	R := MakeDenseMatrix([]float64{5, 5, 0, 1,
+		0, 1, 4, 1,
+		2, 0, 4, 0}, 3, 4)
+	serial, _, _ := Train(R, 2, 5, 0.01, WithParallelism(1))
+	parallel, _, _ := Train(R, 2, 5, 0.01, WithParallelism(8))
+	Assert(t, ApproxEquals(serial, parallel, 0))
+}
+
+func TestHybrid(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 0, 1, 4,
+		4, 0, 4, 1, 5,
+		1, 1, 5, 0, 2,
+		0, 1, 4, 5, 1}, 4, 5)
+	m, _, _ := TrainModel(Q, 2, 10, 0.1)
+	h := NewHybrid(m, 3, 0)
+	// weight 0 is plain ALS
+	for i := 0; i < 5; i++ {
+		p, err := h.Predict(1, i)
+		want, _ := m.Predict(1, i)
+		Assert(t, err == nil && math.Abs(p-want) < 1e-9, i, p, want)
+	}
+	// weight 1 is plain item KNN, from the user's own ratings
+	h.Weight = 1
+	p, _ := h.Predict(1, 1)
+	Assert(t, math.Abs(p-h.Neighbors.Predict(Q.RowCopy(1), 1)) < 1e-9, p)
+	_, err := h.Predict(4, 0)
+	Assert(t, err != nil)
+
+	ids, scores, _ := h.TopN(0, 5, Blocklist(3))
+	Assert(t, len(ids) == 1 && ids[0] == 2, ids)
+	Assert(t, scores[0] == func() float64 { s, _ := h.Predict(0, 2); return s }(), scores)
+
+	V := Zeros(4, 5)
+	V.Set(0, 2, 1)
+	V.Set(3, 0, 2)
+	w, err := h.FitWeight(V)
+	Assert(t, err == nil && w >= 0 && w <= 1 && h.Weight == w, w, err)
+	_, err = h.FitWeight(Zeros(4, 5))
+	Assert(t, err != nil)
+
+	implicitModel, _ := TrainImplicitModel(Q, 2, 10, 0.1)
+	implicit := NewHybrid(implicitModel, 3, 0.5)
+	ids, _, _ = implicit.TopN(0, 1)
+	Assert(t, len(ids) == 1 && ids[0] == 2, ids)
+
+	// users without interactions, including ones newer than the model, go to the cold start recommender
+	cohorts, _ := baseline.TrainCohorts(Q, []baseline.Attributes{{"country": "DE"}, {"country": "DE"},
+		{"country": "FR"}, {"country": "FR"}}, "country")
+	cohorts.MinUsers = 1
+	cohorts.Users = append(cohorts.Users, baseline.Attributes{"country": "FR"})
+	h.ColdStart = cohorts
+	ids, _, err = h.TopN(4, 2, Blocklist(2))
+	Assert(t, err == nil && len(ids) == 2 && ids[0] == 1 && ids[1] == 4, ids, err)
+	ids, _, _ = h.TopN(0, 5, Blocklist(3))
+	Assert(t, len(ids) == 1 && ids[0] == 2, ids)
+}
+
+func TestErrors(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 0, 1,
+		0, 0, 0, 0,
+		2, 0, 4, 0}, 3, 4)
+	// user 1 has no ratings, so without regularization its system is singular
+	_, _, err := TrainModel(Q, 2, 5, 0)
+	Assert(t, errors.Is(err, ErrSingularSystem), err)
+	_, _, err = TrainModel(Q, 2, 5, 0.01)
+	Assert(t, err == nil, err)
+
+	_, _, err = Train(Q, 2, 5, 0.01, WithValidation(Eye(2), 1))
+	Assert(t, errors.Is(err, ErrDimensionMismatch), err)
+	// an empty validation set would stop training on NaN errors
+	_, _, err = Train(Q, 2, 5, 0.01, WithValidation(Zeros(3, 4), 1))
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, err = TrainImplicit(Q, 2, 5, 0.01, WithValidation(Zeros(3, 4), 1))
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, err = TrainImplicit(Q, 2, 5, 0.01, WithTimeDecay(Q, -1))
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, err = TrainWARP(Q, 2, 5, 0.01, 0.05, WithObserved(Eye(2)))
+	Assert(t, errors.Is(err, ErrDimensionMismatch), err)
+	_, _, err = TrainModel(Q, 2, 5, 0.01, WithCheckpoints(1, func(int, *Model) error {
+		return errors.New("disk full")
+	}))
+	Assert(t, err != nil && err.Error() == "disk full", err)
+
+	// indices out of range are invalid arguments, one past the end included
+	m, _, _ := TrainModel(Q, 2, 5, 0.01)
+	_, err = Predict(m.Predictions(), 3, 0)
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, err = Predict(m.Predictions(), 0, -1)
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, err = m.Predict(0, 4)
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, _, err = m.TopN(3, 2)
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	_, err = m.PredictUser(-1)
+	Assert(t, errors.Is(err, ErrInvalidArgument), err)
+	Assert(t, errors.Is(m.Update(5, 0, 1), ErrInvalidArgument))
+	Assert(t, errors.Is(m.Update(0, 0, math.NaN()), ErrInvalidArgument))
+	for _, args := range [][2]int{{3, 1}, {-1, 1}, {0, -1}, {0, 5}} {
+		_, err = GetTopNRecommendations(Q, m.Predictions(), args[0], args[1], nil)
+		Assert(t, errors.Is(err, ErrInvalidArgument), args, err)
+	}
+
+	_, err = Load("../testdata/missing.txt", ",")
+	Assert(t, err != nil)
+}
+
+func TestSGDOptimizers(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{5, 5, 0, 1,
+		0, 1, 4, 1,
+		2, 0, 4, 0,
+		5, 4, 0, 1}, 4, 4)
+	// a batch of 1 with plain SGD is the default
+	plain, plainLoss, _ := TrainSGD(Q, 3, 50, 0.001, 0.02)
+	single, singleLoss, _ := TrainSGD(Q, 3, 50, 0.001, 0.02, WithBatchSize(1), WithOptimizer(PlainSGD))
+	Assert(t, plainLoss == singleLoss && Equals(plain.X, single.X), plainLoss, singleLoss)
+
+	for o, rate := range map[Optimizer]float64{AdaGrad: 0.2, Adam: 0.05} {
+		m, loss, err := TrainSGD(Q, 3, 500, 0.001, rate, WithOptimizer(o), WithBatchSize(4))
+		Assert(t, err == nil && loss < 0.01, o, loss)
+		Assert(t, m.Metadata().Hyperparameters["batch_size"] == 4, m.Metadata())
+	}
+}
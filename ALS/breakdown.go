@@ -0,0 +1,173 @@
+package ALS
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The components of a recommended product's score, for answering "why did this user get this
+// product?". The model's score is Dot*Scale + Offset, clamped to the rating range with Clip (the
+// change is Clipped), and divided by Discount in TopNDebiased (multiplied for negative scores).
+// A Hybrid blends that ALS score with KNN, the neighborhood's score, as (1-Weight)*ALS + Weight*KNN
+// when the user rated any of the product's neighbors (Neighbors), and uses ALS alone otherwise.
+type Breakdown struct {
+	Product int
+	Score   float64
+
+	Dot           float64
+	Scale, Offset float64
+	Clipped       float64
+	Discount      float64
+
+	ALS, KNN  float64
+	Weight    float64
+	Neighbors bool
+}
+
+// A TopN request with the breakdown of every recommended product, and how the catalog was narrowed
+// down: Rated products were skipped because the user has them, Filtered[k] counts the products the
+// k-th filter rejected, and Candidates the products left to rank. Approximate is set when the
+// candidates came from the index built by BuildIndex, and ColdStart when a Hybrid handed the user
+// to its ColdStart recommender (whose scores can't be broken down).
+type Debug struct {
+	User        int
+	Items       []Breakdown
+	Candidates  int
+	Rated       int
+	Filtered    []int
+	Approximate bool
+	ColdStart   bool
+}
+
+func (b Breakdown) String() string {
+	s := fmt.Sprintf("product %d: score %.4g = dot %.4g", b.Product, b.Score, b.Dot)
+	if b.Scale != 1 || b.Offset != 0 {
+		s += fmt.Sprintf(" * scale %.4g + offset %.4g", b.Scale, b.Offset)
+	}
+	if b.Clipped != 0 {
+		s += fmt.Sprintf(", clipped by %.4g", b.Clipped)
+	}
+	if b.Discount != 1 {
+		s += fmt.Sprintf(", popularity discount %.4g", b.Discount)
+	}
+	if b.Neighbors {
+		s += fmt.Sprintf(", blended: %.4g * ALS %.4g + %.4g * KNN %.4g", 1-b.Weight, b.ALS, b.Weight, b.KNN)
+	}
+	return s
+}
+
+func (d *Debug) String() string {
+	lines := []string{fmt.Sprintf("user %d: %d candidates, %d rated, filtered %v", d.User, d.Candidates, d.Rated, d.Filtered)}
+	if d.Approximate {
+		lines[0] += ", from the approximate index"
+	}
+	if d.ColdStart {
+		lines[0] += ", cold start"
+	}
+	for _, b := range d.Items {
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Like TopN, returning the breakdown of every recommended product's score along with the request.
+func (m *Model) TopNDebug(user, n int, filters ...Filter) (*Debug, error) {
+	return m.debug(user, n, 0, filters)
+}
+
+// Like TopNDebiased, returning the breakdown of every recommended product's score along with the request.
+func (m *Model) TopNDebiasedDebug(user, n int, beta float64, filters ...Filter) (*Debug, error) {
+	return m.debug(user, n, beta, filters)
+}
+
+func (m *Model) debug(user, n int, beta float64, filters []Filter) (*Debug, error) {
+	ids, scores, err := m.topN(user, n, beta, filters)
+	if err != nil {
+		return nil, err
+	}
+	d := m.narrowing(user, filters)
+	d.Approximate = m.index != nil
+	for k, i := range ids {
+		b := m.breakdown(user, i, beta)
+		b.Score = scores[k]
+		d.Items = append(d.Items, b)
+	}
+	return d, nil
+}
+
+// counts how the user's rated products and the filters narrowed the catalog down
+func (m *Model) narrowing(user int, filters []Filter) *Debug {
+	d := &Debug{User: user, Filtered: make([]int, len(filters))}
+	rated := m.rated(user)
+	for i := 0; i < m.Y.Cols(); i++ {
+		if rated[i] {
+			d.Rated++
+			continue
+		}
+		kept := true
+		for k, f := range filters {
+			if !f(i) {
+				d.Filtered[k]++
+				kept = false
+			}
+		}
+		if kept {
+			d.Candidates++
+		}
+	}
+	return d
+}
+
+// the model's score of a pair taken apart, see Breakdown
+func (m *Model) breakdown(user, product int, beta float64) Breakdown {
+	b := Breakdown{Product: product, Scale: 1, Discount: 1}
+	b.Dot = dot(m.X.RowCopy(user), m.Y.ColCopy(product))
+	score := b.Dot
+	if m.Norm != nil {
+		b.Offset, b.Scale = m.Norm.params(user, product)
+		score = score*b.Scale + b.Offset
+	}
+	if m.Clip {
+		clipped := math.Max(m.MinRating, math.Min(m.MaxRating, score))
+		b.Clipped = clipped - score
+		score = clipped
+	}
+	if beta != 0 {
+		b.Discount = math.Pow(1+m.productPopularity()[product], beta)
+		if score < 0 {
+			score *= b.Discount
+		} else {
+			score /= b.Discount
+		}
+	}
+	b.Score, b.ALS = score, score
+	return b
+}
+
+// Like TopN, returning the breakdown of every recommended product's blended score along with the
+// request.
+func (h *Hybrid) TopNDebug(user, n int, filters ...Filter) (*Debug, error) {
+	ids, scores, err := h.TopN(user, n, filters...)
+	if err != nil {
+		return nil, err
+	}
+	m := h.Model
+	if user >= m.P.Rows() || len(m.rated(user)) == 0 && h.ColdStart != nil {
+		d := &Debug{User: user, ColdStart: true, Candidates: len(ids)}
+		for k, i := range ids {
+			d.Items = append(d.Items, Breakdown{Product: i, Score: scores[k], Scale: 1, Discount: 1})
+		}
+		return d, nil
+	}
+	d := m.narrowing(user, filters)
+	ratings := h.ratings(user)
+	for k, i := range ids {
+		b := m.breakdown(user, i, 0)
+		b.ALS, b.KNN, b.Neighbors = h.scores(user, i, ratings)
+		b.Weight = h.Weight
+		b.Score = scores[k]
+		d.Items = append(d.Items, b)
+	}
+	return d, nil
+}
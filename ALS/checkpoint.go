@@ -0,0 +1,248 @@
+package ALS
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// on-disk representation of a Model. Matrices are stored row-major.
+type savedModel struct {
+	Users, Products, Factors int
+	X, Y, W, P               []float64
+	Observed                 []float64
+	Lambda                   float64
+	WeightedLambda           bool
+	Implicit                 bool
+	Iterations               int
+	Norm                     *Normalizer
+	MinRating, MaxRating     float64
+	Clip                     bool
+	NonNegative              bool
+	MaxNorm                  float64
+	MissingValue             float64
+	Version                  int
+	Algorithm                string
+	TrainedAt                time.Time
+	Hyperparameters          map[string]float64
+	Fingerprint              string
+}
+
+// Writes the model to w. Read it back with LoadModel.
+func (m *Model) Save(w io.Writer) error {
+	saved := savedModel{
+		Users:           m.X.Rows(),
+		Products:        m.Y.Cols(),
+		Factors:         m.X.Cols(),
+		X:               m.X.Array(),
+		Y:               m.Y.Array(),
+		W:               m.W.Array(),
+		P:               m.P.Array(),
+		Observed:        observedArray(m.Observed),
+		Lambda:          m.Lambda,
+		WeightedLambda:  m.WeightedLambda,
+		Implicit:        m.Implicit,
+		Iterations:      m.Iterations,
+		Norm:            m.Norm,
+		MinRating:       m.MinRating,
+		MaxRating:       m.MaxRating,
+		Clip:            m.Clip,
+		NonNegative:     m.NonNegative,
+		MaxNorm:         m.MaxNorm,
+		MissingValue:    m.MissingValue,
+		Version:         FormatVersion,
+		Algorithm:       m.meta.Algorithm,
+		TrainedAt:       m.meta.TrainedAt,
+		Hyperparameters: m.meta.Hyperparameters,
+		Fingerprint:     m.meta.Fingerprint,
+	}
+	return gob.NewEncoder(w).Encode(saved)
+}
+
+// the values of an optional mask, nil if there is none
+func observedArray(observed *DenseMatrix) []float64 {
+	if observed == nil {
+		return nil
+	}
+	return observed.Array()
+}
+
+// Reads a model written by Save. Error if it was written by a newer, incompatible version of the package.
+func LoadModel(r io.Reader) (*Model, error) {
+	var saved savedModel
+	if err := gob.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, err
+	}
+	users, products, factors := saved.Users, saved.Products, saved.Factors
+	if len(saved.X) != users*factors || len(saved.Y) != factors*products ||
+		len(saved.W) != users*products || len(saved.P) != users*products ||
+		saved.Observed != nil && len(saved.Observed) != users*products {
+		return nil, errors.New("Saved model has inconsistent dimensions")
+	}
+	meta := Metadata{
+		Version:         saved.Version,
+		Algorithm:       saved.Algorithm,
+		TrainedAt:       saved.TrainedAt,
+		Hyperparameters: saved.Hyperparameters,
+		Fingerprint:     saved.Fingerprint,
+	}
+	if err := meta.compatible(saved.Implicit); err != nil {
+		return nil, err
+	}
+	var observed *DenseMatrix
+	if saved.Observed != nil {
+		observed = MakeDenseMatrix(saved.Observed, users, products)
+	}
+	return &Model{
+		X:              MakeDenseMatrix(saved.X, users, factors),
+		Y:              MakeDenseMatrix(saved.Y, factors, products),
+		W:              MakeDenseMatrix(saved.W, users, products),
+		P:              MakeDenseMatrix(saved.P, users, products),
+		Observed:       observed,
+		Lambda:         saved.Lambda,
+		WeightedLambda: saved.WeightedLambda,
+		Implicit:       saved.Implicit,
+		Iterations:     saved.Iterations,
+		Norm:           saved.Norm,
+		MinRating:      saved.MinRating,
+		MaxRating:      saved.MaxRating,
+		Clip:           saved.Clip,
+		NonNegative:    saved.NonNegative,
+		MaxNorm:        saved.MaxNorm,
+		MissingValue:   saved.MissingValue,
+		meta:           meta,
+	}, nil
+}
+
+// Calls save with the partially trained model after every n completed iterations, so a long
+// run that crashes or is pre-empted can be continued with ResumeFrom. See CheckpointToDir.
+// m keeps training after save returns, so save should write it out rather than hold on to it.
+func WithCheckpoints(every int, save func(iteration int, m *Model) error) Option {
+	return func(c *config) {
+		c.checkpointEvery = every
+		c.checkpointFn = save
+	}
+}
+
+// Continue training from a partially trained model (e.g. a checkpoint) instead of random
+// factors. Only the iterations the model has not completed yet are run.
+func ResumeFrom(m *Model) Option {
+	return func(c *config) {
+		c.resume = m
+	}
+}
+
+const checkpointPattern = "checkpoint-%06d.model"
+
+// Returns a checkpoint function for WithCheckpoints that saves each checkpoint to its own file in dir.
+func CheckpointToDir(dir string) func(int, *Model) error {
+	return func(iteration int, m *Model) error {
+		path := filepath.Join(dir, fmt.Sprintf(checkpointPattern, iteration))
+		// write to a temporary file first so a crash never leaves a truncated checkpoint behind
+		f, err := os.Create(path + ".tmp")
+		if err != nil {
+			return err
+		}
+		if err = m.Save(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+		return os.Rename(path+".tmp", path)
+	}
+}
+
+// Loads the most recent checkpoint written to dir by CheckpointToDir.
+func LatestCheckpoint(dir string) (*Model, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "checkpoint-*.model"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, errors.New("No checkpoints found in " + dir)
+	}
+	// zero padded iteration numbers sort lexically
+	sort.Strings(paths)
+	f, err := os.Open(paths[len(paths)-1])
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadModel(f)
+}
+
+// saves a checkpoint if one is due
+func (c *config) checkpoint(m *Model) {
+	if c.checkpointEvery <= 0 || c.checkpointFn == nil || m.Iterations%c.checkpointEvery != 0 {
+		return
+	}
+	c.fail(c.checkpointFn(m.Iterations, m))
+}
+
+// Start training from the factors of an earlier model, e.g. yesterday's, instead of random ones,
+// which typically converges in a fraction of the iterations. Unlike ResumeFrom, the ratings may have
+// changed and grown: users and products are matched by index, and the ones m doesn't know yet start
+// out random. The full number of iterations is run. m needs the same number of factors, otherwise
+// training fails with ErrDimensionMismatch.
+func WithInitialModel(m *Model) Option {
+	return func(c *config) {
+		c.initial = m
+	}
+}
+
+// random factors for a rows x cols rating matrix, with the factors of the initial model copied over
+// for the users and products it has
+func (c *config) initialFactors(rows, cols, n_factors int, max_rating float64) (X, Y *DenseMatrix) {
+	X, Y = randomFactors(rows, cols, n_factors, max_rating, c.rng)
+	m := c.initial
+	if m == nil {
+		return c.constrainFactors(X, Y)
+	}
+	if m.X.Cols() != n_factors {
+		c.fail(wrap(ErrDimensionMismatch, "Initial model does not have the requested number of factors"))
+		return X, Y
+	}
+	for u := 0; u < rows && u < m.X.Rows(); u++ {
+		for f := 0; f < n_factors; f++ {
+			X.Set(u, f, m.X.Get(u, f))
+		}
+	}
+	for i := 0; i < cols && i < m.Y.Cols(); i++ {
+		for f := 0; f < n_factors; f++ {
+			Y.Set(f, i, m.Y.Get(f, i))
+		}
+	}
+	return c.constrainFactors(X, Y)
+}
+
+// Returns the factors to start training from, along with the number of iterations they have
+// already been trained for: the resumed model's, a truncated SVD of the ratings, or random ones
+// (with the initial model's copied over).
+func (c *config) startingFactors(model *Model, n_factors int, max_rating float64) (X, Y *DenseMatrix, done int) {
+	Q := model.P
+	if m := c.resume; m != nil {
+		if m.X.Rows() == Q.Rows() && m.Y.Cols() == Q.Cols() && m.X.Cols() == n_factors {
+			return m.X.Copy(), m.Y.Copy(), m.Iterations
+		}
+		c.fail(wrap(ErrDimensionMismatch, "Model to resume from does not match the rating matrix or number of factors"))
+	}
+	if c.svdInit {
+		X, Y, err := svdFactors(model, n_factors, c.rng)
+		if err == nil {
+			X, Y = c.constrainFactors(X, Y)
+			return X, Y, 0
+		}
+		c.fail(err)
+	}
+	X, Y = c.initialFactors(Q.Rows(), Q.Cols(), n_factors, max_rating)
+	return X, Y, 0
+}
@@ -0,0 +1,57 @@
+package ALS
+
+import "math"
+
+// number of ratings at which a user's or product's support reaches 1/2
+const confidenceShrinkage = 5.0
+
+// Returns how much the model's prediction for a user/product pair can be trusted, between 0 and 1.
+// Factors learned from few ratings mostly reflect the random start and the regularization, so
+// the score is the product of n/(n + 5) for the user's and the product's number of ratings n.
+// Regularization also shrinks weakly supported factors towards 0, so each of the two is further
+// scaled by the norm of its factor vector relative to the mean norm of its side, capped at 1.
+// Use it to suppress low confidence recommendations, or fall back to popularity for them.
+func (m *Model) Confidence(user, product int) (float64, error) {
+	if user < 0 || user >= m.P.Rows() || product < 0 || product >= m.P.Cols() {
+		return 0.0, wrap(ErrInvalidArgument, "User/Product index out of range")
+	}
+	n_user := float64(len(m.rated(user)))
+	n_product := m.productPopularity()[product]
+	norms := m.meanNorms()
+	return n_user / (n_user + confidenceShrinkage) * normSupport(m.X.RowCopy(user), norms[0]) *
+		n_product / (n_product + confidenceShrinkage) * normSupport(m.Y.ColCopy(product), norms[1]), nil
+}
+
+// the norm of a factor vector relative to the mean norm, capped at 1
+func normSupport(f []float64, mean float64) float64 {
+	if mean == 0 {
+		return 0
+	}
+	return math.Min(1, math.Sqrt(dot(f, f))/mean)
+}
+
+// mean norm of the user and of the product factor vectors, cached until the next Update
+func (m *Model) meanNorms() []float64 {
+	m.popMu.Lock()
+	defer m.popMu.Unlock()
+	if m.norms == nil {
+		m.norms = make([]float64, 2)
+		for _, x := range m.X.Arrays() {
+			m.norms[0] += math.Sqrt(dot(x, x)) / float64(m.X.Rows())
+		}
+		for _, y := range m.Y.Transpose().Arrays() {
+			m.norms[1] += math.Sqrt(dot(y, y)) / float64(m.Y.Cols())
+		}
+	}
+	return m.norms
+}
+
+// Returns the prediction for a user/product pair along with its confidence, see Confidence.
+func (m *Model) PredictWithConfidence(user, product int) (float64, float64, error) {
+	pred, err := m.Predict(user, product)
+	if err != nil {
+		return 0.0, 0.0, err
+	}
+	confidence, err := m.Confidence(user, product)
+	return pred, confidence, err
+}
@@ -0,0 +1,78 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Constrain all factors to be non-negative. Every solved factor vector is projected onto the
+// non-negative orthant (negative entries set to 0), and so are the initial factors and every
+// gradient step of TrainSGD and TrainWARP. Non-negative factors read as additive parts, e.g. how
+// much of every "taste" a user has, and can't blow up by cancelling each other out.
+// TrainEALS only applies it to the initial factors.
+func WithNonNegative() Option {
+	return func(c *config) {
+		c.nonNegative = true
+	}
+}
+
+// Bound the Euclidean norm of every factor vector by maxNorm: solved vectors (and the initial
+// factors and gradient steps, as with WithNonNegative) that are longer get scaled down to it, which
+// keeps predictions within maxNorm^2 in absolute value. TrainEALS only applies it to the
+// initial factors.
+func WithMaxNorm(maxNorm float64) Option {
+	return func(c *config) {
+		if maxNorm <= 0 {
+			c.fail(wrap(ErrInvalidArgument, "Maximum factor norm must be positive"))
+			return
+		}
+		c.maxNorm = maxNorm
+	}
+}
+
+// constraints of the model, for configs made after training, e.g. by Update
+func (m *Model) constraints(c *config) *config {
+	c.nonNegative, c.maxNorm = m.NonNegative, m.MaxNorm
+	return c
+}
+
+// projects x onto the configured constraints, in place, and returns it
+func (c *config) constrain(x []float64) []float64 {
+	return project(x, c.nonNegative, c.maxNorm)
+}
+
+// clamps x to be non-negative and scales it down to maxNorm (if not 0), in place
+func project(x []float64, nonNegative bool, maxNorm float64) []float64 {
+	if nonNegative {
+		for f := range x {
+			if x[f] < 0 {
+				x[f] = 0
+			}
+		}
+	}
+	if maxNorm > 0 {
+		if norm := math.Sqrt(dot(x, x)); norm > maxNorm {
+			for f := range x {
+				x[f] *= maxNorm / norm
+			}
+		}
+	}
+	return x
+}
+
+// projects the user factors (rows of X) and product factors (columns of Y) onto the configured
+// constraints
+func (c *config) constrainFactors(X, Y *DenseMatrix) (*DenseMatrix, *DenseMatrix) {
+	if !c.nonNegative && c.maxNorm == 0 {
+		return X, Y
+	}
+	for _, x := range X.Arrays() {
+		c.constrain(x)
+	}
+	Yt := Y.Transpose()
+	for _, y := range Yt.Arrays() {
+		c.constrain(y)
+	}
+	return X, Yt.Transpose()
+}
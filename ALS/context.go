@@ -0,0 +1,206 @@
+package ALS
+
+import (
+	"errors"
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// A context aware model, trained on one rating matrix per context (time of day, device, ...).
+// It is a CP tensor factorization: the predicted rating of a user/product pair in context c is
+// sum_f X[u][f] * Y[f][i] * Z[c][f], so each row of Z re-weights the latent factors for its context.
+// W and P hold the weights and targets for each context, as in Model.
+type ContextModel struct {
+	X, Y, Z    *DenseMatrix
+	W, P       []*DenseMatrix
+	Lambda     float64
+	Iterations int
+}
+
+// Params: one user/product rating matrix per context (all the same shape, 0 meaning not rated in
+// that context), number of factors, iterations, and lambda value for ALS.
+// Users, products and contexts are solved for in turn. Of the options, WithSeed, WithRand,
+// WithConjugateGradient, WithMissingValue and OnIteration apply. WithObserved fails with
+// ErrInvalidArgument, as a single mask can't say what was rated in which context.
+// Returns the trained model, and the final error calculation (float64)
+func TrainContextModel(Q []*DenseMatrix, n_factors, iterations int, lambda float64, opts ...Option) (*ContextModel, float64, error) {
+	if len(Q) == 0 {
+		return nil, 0, errors.New("Need at least one context to train on")
+	}
+	for _, q := range Q {
+		if q.Rows() != Q[0].Rows() || q.Cols() != Q[0].Cols() {
+			return nil, 0, wrap(ErrDimensionMismatch, "The rating matrices of all contexts need to be the same dimension")
+		}
+	}
+	cfg := newConfig(opts)
+	if cfg.observed != nil {
+		return nil, 0, wrap(ErrInvalidArgument, "Context models take WithMissingValue rather than an observed mask")
+	}
+	m := &ContextModel{
+		W:      make([]*DenseMatrix, len(Q)),
+		P:      make([]*DenseMatrix, len(Q)),
+		Z:      Numbers(len(Q), n_factors, 1),
+		Lambda: lambda,
+	}
+	max_rating := 0.0
+	for c, q := range Q {
+		m.W[c] = cfg.missingMask(q)
+		m.P[c] = maskedTargets(q, m.W[c])
+		max_rating = math.Max(max_rating, matrixMax(q))
+	}
+	// contexts start out neutral, so the first pass fits a plain factorization
+	m.X, m.Y = makeXY(Q[0], n_factors, max_rating, cfg.rng)
+
+	for m.Iterations < iterations {
+		m.solveUsers(cfg)
+		m.solveProducts(cfg)
+		m.solveContexts(cfg)
+		if cfg.err != nil {
+			return nil, 0, cfg.err
+		}
+		m.Iterations++
+		if cfg.onIteration != nil {
+			cfg.onIteration(m.Iterations, m.trainingError())
+		}
+	}
+	return m, m.trainingError(), nil
+}
+
+// elementwise product of factor vectors
+func hadamard(a, b []float64) []float64 {
+	out := make([]float64, len(a))
+	for f := range a {
+		out[f] = a[f] * b[f]
+	}
+	return out
+}
+
+// Each solve gathers the rated entries involving one user, product or context, with the
+// elementwise product of the other two factor vectors as features, and reuses the ALS solver.
+func (m *ContextModel) solveUsers(cfg *config) {
+	Yt, Z := m.Y.Transpose().Arrays(), m.Z.Arrays()
+	for u := 0; u < m.X.Rows(); u++ {
+		var F [][]float64
+		var w, q []float64
+		for c := range m.W {
+			for i := range Yt {
+				if weight := m.W[c].Get(u, i); weight != 0 {
+					F = append(F, hadamard(Yt[i], Z[c]))
+					w, q = append(w, weight), append(q, m.P[c].Get(u, i))
+				}
+			}
+		}
+		if row, ok := m.solve(cfg, F, w, q, m.X.RowCopy(u)); ok {
+			setRow(m.X, u, row)
+		}
+	}
+}
+
+func (m *ContextModel) solveProducts(cfg *config) {
+	Xr, Z := m.X.Arrays(), m.Z.Arrays()
+	for i := 0; i < m.Y.Cols(); i++ {
+		var F [][]float64
+		var w, q []float64
+		for c := range m.W {
+			for u := range Xr {
+				if weight := m.W[c].Get(u, i); weight != 0 {
+					F = append(F, hadamard(Xr[u], Z[c]))
+					w, q = append(w, weight), append(q, m.P[c].Get(u, i))
+				}
+			}
+		}
+		if col, ok := m.solve(cfg, F, w, q, m.Y.ColCopy(i)); ok {
+			setCol(m.Y, i, col)
+		}
+	}
+}
+
+func (m *ContextModel) solveContexts(cfg *config) {
+	Xr, Yt := m.X.Arrays(), m.Y.Transpose().Arrays()
+	for c := range m.W {
+		var F [][]float64
+		var w, q []float64
+		for u := range Xr {
+			for i := range Yt {
+				if weight := m.W[c].Get(u, i); weight != 0 {
+					F = append(F, hadamard(Xr[u], Yt[i]))
+					w, q = append(w, weight), append(q, m.P[c].Get(u, i))
+				}
+			}
+		}
+		if row, ok := m.solve(cfg, F, w, q, m.Z.RowCopy(c)); ok {
+			setRow(m.Z, c, row)
+		}
+	}
+}
+
+// solves for one factor vector, leaving it alone if it has no ratings
+func (m *ContextModel) solve(cfg *config, F [][]float64, w, q, x0 []float64) ([]float64, bool) {
+	if len(F) == 0 {
+		return nil, false
+	}
+	x, err := cfg.solveFactors(F, w, q, x0, m.Lambda)
+	if err != nil {
+		cfg.fail(err)
+		return nil, false
+	}
+	return x, true
+}
+
+// weighted squared error over all contexts
+func (m *ContextModel) trainingError() float64 {
+	sum := 0.0
+	for c := range m.W {
+		for u := 0; u < m.X.Rows(); u++ {
+			for i := 0; i < m.Y.Cols(); i++ {
+				if w := m.W[c].Get(u, i); w != 0 {
+					diff := m.P[c].Get(u, i) - m.score(u, i, c)
+					sum += w * diff * diff
+				}
+			}
+		}
+	}
+	return sum
+}
+
+func (m *ContextModel) score(user, product, context int) float64 {
+	sum := 0.0
+	for f := 0; f < m.X.Cols(); f++ {
+		sum += m.X.Get(user, f) * m.Y.Get(f, product) * m.Z.Get(context, f)
+	}
+	return sum
+}
+
+// Returns the predicted rating of a product by a user in the given context. Error if out of range.
+func (m *ContextModel) Predict(user, product, context int) (float64, error) {
+	if user < 0 || user >= m.X.Rows() || product < 0 || product >= m.Y.Cols() || context < 0 || context >= m.Z.Rows() {
+		return 0.0, wrap(ErrInvalidArgument, "User/Product/Context index out of range")
+	}
+	return m.score(user, product, context), nil
+}
+
+// Returns the indices of the n products with the highest predicted values for the user in the
+// given context, in descending order along with their predictions. Products the user already
+// rated in any context are skipped.
+func (m *ContextModel) TopN(user, context, n int) ([]int, []float64, error) {
+	if user < 0 || user >= m.X.Rows() || context < 0 || context >= m.Z.Rows() {
+		return nil, nil, wrap(ErrInvalidArgument, "User/Context index out of range")
+	}
+	products := make([]int, 0, m.Y.Cols())
+	for i := 0; i < m.Y.Cols(); i++ {
+		rated := false
+		for c := range m.P {
+			rated = rated || m.W[c].Get(user, i) != 0
+		}
+		if !rated {
+			products = append(products, i)
+		}
+	}
+	// fold the context into the user's factors once
+	weighted := hadamard(m.X.RowCopy(user), m.Z.RowCopy(context))
+	ids, scores := rank(products, n, func(i int) float64 {
+		return dot(weighted, m.Y.ColCopy(i))
+	})
+	return ids, scores, nil
+}
@@ -0,0 +1,64 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Down-weight older interactions. T holds the timestamp of every rating (same shape as the
+// rating matrix, in any unit such as unix seconds), and the weight of a rating halves every
+// halfLife units before the most recent timestamp in T.
+// In the implicit case only the extra confidence 40*r decays, so an old interaction
+// fades towards an unobserved one instead of below it.
+func WithTimeDecay(T *DenseMatrix, halfLife float64) Option {
+	return func(c *config) {
+		c.timestamps = T
+		c.halfLife = halfLife
+	}
+}
+
+// fraction of its weight a rating made at time t keeps at time now
+func decay(t, now, halfLife float64) float64 {
+	if math.IsNaN(t) || t >= now {
+		return 1
+	}
+	return math.Pow(0.5, (now-t)/halfLife)
+}
+
+// Applies the time decay, if any, to the weights W of the entries that are nonzero in observed.
+// W is modified in place and returned.
+func (c *config) decayed(observed, W *DenseMatrix, implicit bool) *DenseMatrix {
+	T := c.timestamps
+	if T == nil {
+		return W
+	}
+	if T.Rows() != observed.Rows() || T.Cols() != observed.Cols() {
+		c.fail(wrap(ErrDimensionMismatch, "Timestamp matrix needs to be the same dimension as the rating matrix"))
+		return W
+	}
+	if c.halfLife <= 0 {
+		c.fail(wrap(ErrInvalidArgument, "Half-life of the time decay needs to be positive"))
+		return W
+	}
+	now := math.Inf(-1)
+	for _, t := range T.Array() {
+		if t > now {
+			now = t
+		}
+	}
+	for i := 0; i < observed.Rows(); i++ {
+		for j := 0; j < observed.Cols(); j++ {
+			if observed.Get(i, j) == 0 {
+				continue
+			}
+			d := decay(T.Get(i, j), now, c.halfLife)
+			if implicit {
+				W.Set(i, j, 1+(W.Get(i, j)-1)*d)
+			} else {
+				W.Set(i, j, W.Get(i, j)*d)
+			}
+		}
+	}
+	return W
+}
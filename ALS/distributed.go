@@ -0,0 +1,393 @@
+package ALS
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/rpc"
+	"sync"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Distributed ALS: users and products are partitioned across worker processes, user u and
+// product i going to worker u % n and i % n. Each iteration the coordinator sends all product
+// factors to every worker, which solves its own users and sends back their factors, and then the
+// same for the products. Workers only ever hold their share of the ratings, and each factor vector
+// is solved by exactly one worker, so the result is the same as TrainOutOfCore's. The ratings
+// are streamed to the workers in chunks, so the coordinator never holds a whole partition, and
+// it reads the rating file only while streaming: the largest rating and the training error are
+// computed by the workers, each over its own users.
+
+// A rating of a partition's user (or product), by the product (or user) index.
+type Rated struct {
+	Index  int
+	Rating float64
+}
+
+// A worker's share of the ratings, keyed by user and by product.
+type Partition struct {
+	Users, Products map[int][]Rated
+}
+
+// Factors to solve against, and the training settings.
+type SolveArgs struct {
+	Factors        [][]float64
+	Lambda         float64
+	Implicit       bool
+	WeightedLambda bool
+	NonNegative    bool
+	MaxNorm        float64
+}
+
+// The factors of a worker's users (or products).
+type SolveReply struct {
+	Indices []int
+	Factors [][]float64
+}
+
+// All user and product factors, to compute the training error of.
+type ErrorArgs struct {
+	Users, Products [][]float64
+	Implicit        bool
+}
+
+// Solves the factors of one partition of users and products. Its exported methods are called
+// by the coordinator over RPC, see ServeWorker, possibly concurrently.
+type Worker struct {
+	mu   sync.RWMutex
+	part Partition
+}
+
+// Replaces the worker's partition of the ratings.
+func (w *Worker) Load(part *Partition, _ *bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.part = Partition{Users: make(map[int][]Rated), Products: make(map[int][]Rated)}
+	w.part.add(part)
+	return nil
+}
+
+// Adds a chunk of ratings to the worker's partition.
+func (w *Worker) Append(part *Partition, _ *bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.part.Users == nil {
+		w.part = Partition{Users: make(map[int][]Rated), Products: make(map[int][]Rated)}
+	}
+	w.part.add(part)
+	return nil
+}
+
+// adds the ratings of other to the partition, whose maps must be allocated
+func (p *Partition) add(other *Partition) {
+	for u, rated := range other.Users {
+		p.Users[u] = append(p.Users[u], rated...)
+	}
+	for i, rated := range other.Products {
+		p.Products[i] = append(p.Products[i], rated...)
+	}
+}
+
+// Solves the worker's users against the given product factors.
+func (w *Worker) SolveUsers(args *SolveArgs, reply *SolveReply) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.solve(w.part.Users, args, reply)
+}
+
+// Solves the worker's products against the given user factors.
+func (w *Worker) SolveProducts(args *SolveArgs, reply *SolveReply) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.solve(w.part.Products, args, reply)
+}
+
+// The largest rating of the worker's users, 0 if it has none.
+func (w *Worker) MaxRating(_ *bool, reply *float64) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	*reply = 0
+	for _, rated := range w.part.Users {
+		for _, r := range rated {
+			*reply = math.Max(*reply, r.Rating)
+		}
+	}
+	return nil
+}
+
+// The worker's users' share of the training error of the given factors, see OnIteration.
+func (w *Worker) Error(args *ErrorArgs, reply *float64) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	var G *DenseMatrix
+	if args.Implicit {
+		G = gram(args.Products)
+	}
+	*reply = 0
+	for u, rated := range w.part.Users {
+		if u < 0 || u >= len(args.Users) {
+			return errors.New("User index out of range of the factors")
+		}
+		for _, r := range rated {
+			if r.Index < 0 || r.Index >= len(args.Products) {
+				return errors.New("Rating index out of range of the factors")
+			}
+		}
+		each := func(fn func(int, float64)) {
+			for _, r := range rated {
+				fn(r.Index, r.Rating)
+			}
+		}
+		*reply += rowError(args.Users[u], args.Products, G, each, args.Implicit)
+	}
+	return nil
+}
+
+func (w *Worker) solve(rows map[int][]Rated, args *SolveArgs, reply *SolveReply) error {
+	if len(args.Factors) == 0 {
+		return errors.New("No factors to solve against")
+	}
+	var base *DenseMatrix
+	if args.Implicit {
+		base = gram(args.Factors)
+	}
+	cfg := &config{weightedLambda: args.WeightedLambda, nonNegative: args.NonNegative, maxNorm: args.MaxNorm}
+	each := func(which int, fn func(int, float64)) {
+		for _, r := range rows[which] {
+			fn(r.Index, r.Rating)
+		}
+	}
+	for which, rated := range rows {
+		for _, r := range rated {
+			if r.Index < 0 || r.Index >= len(args.Factors) {
+				return errors.New("Rating index out of range of the factors")
+			}
+		}
+		x, err := solveSparse(args.Factors, base, each, which, args.Lambda, args.Implicit, cfg)
+		if err != nil {
+			return err
+		}
+		reply.Indices = append(reply.Indices, which)
+		reply.Factors = append(reply.Factors, x)
+	}
+	return nil
+}
+
+// Serves a Worker over RPC on l until it fails, e.g. from a worker process's main:
+//
+//	l, _ := net.Listen("tcp", ":7070")
+//	log.Fatal(ALS.ServeWorker(l))
+func ServeWorker(l net.Listener) error {
+	server := rpc.NewServer()
+	if err := server.Register(&Worker{}); err != nil {
+		return err
+	}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go server.ServeConn(conn)
+	}
+}
+
+// Trains ALS across a set of workers, see ServeWorker.
+type Coordinator struct {
+	workers []*rpc.Client
+}
+
+// Connects to workers listening on the given TCP addresses.
+func Dial(addrs []string) (*Coordinator, error) {
+	c := &Coordinator{}
+	for _, addr := range addrs {
+		client, err := rpc.Dial("tcp", addr)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.workers = append(c.workers, client)
+	}
+	if len(c.workers) == 0 {
+		return nil, errors.New("Need at least one worker")
+	}
+	return c, nil
+}
+
+// Closes the connections to the workers.
+func (c *Coordinator) Close() error {
+	var err error
+	for _, w := range c.workers {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// calls method on every worker in parallel, with args for each, and waits for all of them
+func (c *Coordinator) callAll(method string, args func(k int) interface{}, replies func(k int) interface{}) error {
+	calls := make([]*rpc.Call, len(c.workers))
+	for k, w := range c.workers {
+		calls[k] = w.Go(method, args(k), replies(k), nil)
+	}
+	var err error
+	for _, call := range calls {
+		<-call.Done
+		if call.Error != nil && err == nil {
+			err = call.Error
+		}
+	}
+	return err
+}
+
+// number of ratings (plus one per user/product) the coordinator sends a worker at a time
+var distributeChunk = 1 << 16
+
+// Sends every worker its partition of the rating file, all workers in parallel.
+func (c *Coordinator) distribute(r *RatingFile) error {
+	errs := make([]error, len(c.workers))
+	var wg sync.WaitGroup
+	for k := range c.workers {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			errs[k] = c.send(r, k)
+		}(k)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streams worker k's users and products to it in chunks of about distributeChunk ratings
+func (c *Coordinator) send(r *RatingFile, k int) error {
+	w, n := c.workers[k], len(c.workers)
+	var ok bool
+	if err := w.Call("Worker.Load", &Partition{}, &ok); err != nil {
+		return err
+	}
+	part, size := Partition{Users: make(map[int][]Rated), Products: make(map[int][]Rated)}, 0
+	flush := func() error {
+		err := w.Call("Worker.Append", &part, &ok)
+		part, size = Partition{Users: make(map[int][]Rated), Products: make(map[int][]Rated)}, 0
+		return err
+	}
+	for u := k; u < r.Users(); u += n {
+		rated := []Rated{}
+		r.eachUser(u, func(i int, rating float64) {
+			rated = append(rated, Rated{i, rating})
+		})
+		part.Users[u] = rated
+		if size += len(rated) + 1; size >= distributeChunk {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	for i := k; i < r.Products(); i += n {
+		rated := []Rated{}
+		r.eachProduct(i, func(u int, rating float64) {
+			rated = append(rated, Rated{u, rating})
+		})
+		part.Products[i] = rated
+		if size += len(rated) + 1; size >= distributeChunk {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return flush()
+}
+
+// sends the fixed factors to every worker and collects the solved ones into solved
+func (c *Coordinator) solveAll(method string, args *SolveArgs, solved [][]float64) error {
+	replies := make([]SolveReply, len(c.workers))
+	err := c.callAll(method,
+		func(int) interface{} { return args },
+		func(k int) interface{} { return &replies[k] })
+	if err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		for n, which := range reply.Indices {
+			solved[which] = reply.Factors[n]
+		}
+	}
+	return nil
+}
+
+// sums the workers' shares of the training error
+func (c *Coordinator) sparseError(Xr, Yt [][]float64, implicit bool) (float64, error) {
+	args := &ErrorArgs{Users: Xr, Products: Yt, Implicit: implicit}
+	replies := make([]float64, len(c.workers))
+	err := c.callAll("Worker.Error",
+		func(int) interface{} { return args },
+		func(k int) interface{} { return &replies[k] })
+	sum := 0.0
+	for _, e := range replies {
+		sum += e
+	}
+	return sum, err
+}
+
+// the largest rating of all workers
+func (c *Coordinator) maxRating() (float64, error) {
+	replies := make([]float64, len(c.workers))
+	var none bool
+	err := c.callAll("Worker.MaxRating",
+		func(int) interface{} { return &none },
+		func(k int) interface{} { return &replies[k] })
+	max := 0.0
+	for _, m := range replies {
+		max = math.Max(max, m)
+	}
+	return max, err
+}
+
+// Params: a rating file, number of factors, iterations, lambda value for ALS, and whether the
+// ratings are implicit. Partitions the ratings across the workers and trains like TrainOutOfCore
+// (or TrainImplicitOutOfCore). Of the options, WithSeed, WithRand, WithWeightedLambda and
+// OnIteration apply.
+// Returns the user factors X (one row per user) and product factors Y (one column per product).
+func (c *Coordinator) Train(r *RatingFile, n_factors, iterations int, lambda float64, implicit bool, opts ...Option) (X, Y *DenseMatrix, err error) {
+	cfg := newConfig(opts)
+	if err := c.distribute(r); err != nil {
+		return nil, nil, err
+	}
+	max_rating := 5.0
+	if !implicit {
+		if max_rating, err = c.maxRating(); err != nil {
+			return nil, nil, err
+		}
+	}
+	X, Y = cfg.initialFactors(r.Users(), r.Products(), n_factors, max_rating)
+	if cfg.err != nil {
+		return nil, nil, cfg.err
+	}
+	Xr, Yt := X.Arrays(), Y.Transpose().Arrays()
+
+	for iter := 1; iter <= iterations; iter++ {
+		args := &SolveArgs{Factors: Yt, Lambda: lambda, Implicit: implicit, WeightedLambda: cfg.weightedLambda,
+			NonNegative: cfg.nonNegative, MaxNorm: cfg.maxNorm}
+		if err := c.solveAll("Worker.SolveUsers", args, Xr); err != nil {
+			return nil, nil, err
+		}
+		args.Factors = Xr
+		if err := c.solveAll("Worker.SolveProducts", args, Yt); err != nil {
+			return nil, nil, err
+		}
+		if cfg.onIteration != nil {
+			e, err := c.sparseError(Xr, Yt, implicit)
+			if err != nil {
+				return nil, nil, err
+			}
+			cfg.onIteration(iter, e)
+		}
+	}
+	return MakeDenseMatrixStacked(Xr), MakeDenseMatrixStacked(Yt).Transpose(), nil
+}
@@ -0,0 +1,181 @@
+package ALS
+
+import (
+	"errors"
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// an observed entry of the interaction matrix, with its current prediction
+type ealsEntry struct {
+	user, product int
+	weight        float64
+	pred          float64
+}
+
+// Params: the interaction matrix, number of factors, number of iterations, lambda, and the total
+// weight c0 of the missing entries with its popularity exponent alpha (e.g. 512 and 0.4).
+// Fits implicit feedback with element-wise ALS (eALS, He et al. 2016): every missing entry is a
+// negative with weight c_i = c0 * f_i^alpha / sum_j f_j^alpha, where f_i is the fraction of
+// interactions on product i, so popular products the user skipped count as stronger negatives.
+// Observed entries get the usual 1 + 40*r confidence, or the WithWeights value. Each factor is
+// solved in closed form on its own, with the missing entries accounted for through cached K x K
+// Gram matrices, so an iteration costs O((users + products) * K^2 + interactions * K) instead of
+// the K^3 solves per user and product of TrainImplicitModel. The training error recorded in the
+// model's History is the weighted squared error over all entries.
+func TrainEALS(R *DenseMatrix, n_factors, iterations int, lambda, c0, alpha float64, opts ...Option) (*Model, error) {
+	cfg := newConfig(opts)
+	rows, cols := R.Rows(), R.Cols()
+	observed := cfg.observedMask(R)
+	confidence := cfg.weightsFor(R, confidences(R, observed))
+
+	entries := make([]ealsEntry, 0)
+	byUser := make([][]int, rows)
+	byProduct := make([][]int, cols)
+	popularity := make([]float64, cols)
+	for u := 0; u < rows; u++ {
+		for i := 0; i < cols; i++ {
+			if observed.Get(u, i) != 0 {
+				byUser[u] = append(byUser[u], len(entries))
+				byProduct[i] = append(byProduct[i], len(entries))
+				entries = append(entries, ealsEntry{user: u, product: i, weight: confidence.Get(u, i)})
+				popularity[i]++
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("No interactions to train on")
+	}
+	// popularity based weights of the missing entries
+	missing := make([]float64, cols)
+	total := 0.0
+	for i, f := range popularity {
+		missing[i] = math.Pow(f/float64(len(entries)), alpha)
+		total += missing[i]
+	}
+	for i := range missing {
+		missing[i] *= c0 / total
+	}
+
+	X0, Y0 := cfg.initialFactors(rows, cols, n_factors, smallInitScale)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	P, Q := X0.Arrays(), Y0.Transpose().Arrays()
+	for n := range entries {
+		entries[n].pred = dot(P[entries[n].user], Q[entries[n].product])
+	}
+	model := &Model{Lambda: lambda, Implicit: true, MissingValue: cfg.missing()}
+
+	for iter := 1; iter <= iterations; iter++ {
+		// users, against the weighted Gram matrix of the product factors
+		Sq := ealsGram(Q, missing)
+		for u := range P {
+			ealsUpdate(P[u], Q, byUser[u], entries, Sq, 1, missing, lambda, false)
+		}
+		// products, against the Gram matrix of the user factors, scaled by each product's weight
+		Sp := ealsGram(P, nil)
+		for i := range Q {
+			ealsUpdate(Q[i], P, byProduct[i], entries, Sp, missing[i], missing, lambda, true)
+		}
+		model.Iterations = iter
+		error_value := ealsLoss(P, Q, entries, missing)
+		cfg.record(&model.History, IterationStats{Iteration: iter, TrainingError: error_value})
+		if cfg.err != nil {
+			return nil, cfg.err
+		}
+		if cfg.onIteration != nil {
+			cfg.onIteration(iter, error_value)
+		}
+	}
+
+	W := Zeros(rows, cols)
+	for u := 0; u < rows; u++ {
+		for i := 0; i < cols; i++ {
+			W.Set(u, i, missing[i])
+		}
+	}
+	for _, e := range entries {
+		W.Set(e.user, e.product, e.weight)
+	}
+	model.X, model.Y = MakeDenseMatrixStacked(P), MakeDenseMatrixStacked(Q).Transpose()
+	model.W, model.P = W, observed
+	model.trained(AlgorithmEALS, R, n_factors, iterations, cfg)
+	model.meta.Hyperparameters["c0"] = c0
+	model.meta.Hyperparameters["alpha"] = alpha
+	return model, nil
+}
+
+// sum over the rows of F of weight * f f^T, with all weights 1 if nil
+func ealsGram(F [][]float64, weights []float64) [][]float64 {
+	k := len(F[0])
+	S := make([][]float64, k)
+	for a := range S {
+		S[a] = make([]float64, k)
+	}
+	for r, f := range F {
+		w := 1.0
+		if weights != nil {
+			w = weights[r]
+		}
+		for a := 0; a < k; a++ {
+			for b := 0; b < k; b++ {
+				S[a][b] += w * f[a] * f[b]
+			}
+		}
+	}
+	return S
+}
+
+// Coordinate descent over the factors x of a single user (or product), whose observed entries are
+// entries[observed]. others are the fixed factors on the other side and S their Gram matrix, scaled
+// by scale: a product's own missing weight, while for users the weights are already folded into S.
+func ealsUpdate(x []float64, others [][]float64, observed []int, entries []ealsEntry, S [][]float64, scale float64, missing []float64, lambda float64, product bool) {
+	other := func(e *ealsEntry) int {
+		if product {
+			return e.user
+		}
+		return e.product
+	}
+	for f := range x {
+		num, den := 0.0, scale*S[f][f]+lambda
+		for k := range x {
+			if k != f {
+				num -= scale * x[k] * S[k][f]
+			}
+		}
+		for _, n := range observed {
+			e := &entries[n]
+			c := missing[e.product]
+			y := others[other(e)][f]
+			without := e.pred - x[f]*y
+			num += (e.weight - (e.weight-c)*without) * y
+			den += (e.weight - c) * y * y
+		}
+		updated := num / den
+		for _, n := range observed {
+			e := &entries[n]
+			e.pred += (updated - x[f]) * others[other(e)][f]
+		}
+		x[f] = updated
+	}
+}
+
+// weighted squared error over all entries: the missing ones through the Gram matrix, the
+// observed ones corrected to their own weight and target
+func ealsLoss(P, Q [][]float64, entries []ealsEntry, missing []float64) float64 {
+	Sp := ealsGram(P, nil)
+	loss := 0.0
+	for i, q := range Q {
+		for a := range q {
+			for b := range q {
+				loss += missing[i] * q[a] * Sp[a][b] * q[b]
+			}
+		}
+	}
+	for _, e := range entries {
+		loss += e.weight*(1-e.pred)*(1-e.pred) - missing[e.product]*e.pred*e.pred
+	}
+	return loss
+}
@@ -0,0 +1,37 @@
+package ALS
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors the trainers and solvers return, wrapped with the details; test for them with errors.Is.
+var (
+	// A factor vector's normal equations are not positive definite, e.g. a user without ratings
+	// when lambda is 0, so its factors can't be solved for.
+	ErrSingularSystem = errors.New("Singular system")
+	// A matrix passed in (ratings, weights, timestamps, ...) doesn't have the shape it needs.
+	ErrDimensionMismatch = errors.New("Dimension mismatch")
+	// An option or argument, such as a user or product index, is outside its valid range.
+	ErrInvalidArgument = errors.New("Invalid argument")
+)
+
+// wraps a sentinel error with a description of what went wrong
+func wrap(sentinel error, msg string) error {
+	return fmt.Errorf("%s: %w", msg, sentinel)
+}
+
+// Records the first error of a training run, which the trainer then returns.
+func (c *config) fail(err error) {
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+// panics on errors that can only come from a bug in this package, such as multiplying factor
+// matrices the package built itself with mismatched shapes
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
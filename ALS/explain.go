@@ -0,0 +1,25 @@
+package ALS
+
+import "github.com/timkaye11/goRecommend/collabFilter"
+
+// Returns up to k products the user rated that contributed most to recommending the given product,
+// for "recommended because you liked X and Y" style explanations, along with their contributions.
+// A rated product's contribution is its cosine similarity to the recommended product in latent
+// factor space, times the user's (normalized) rating or, in the implicit case, confidence.
+// Products are returned in descending order of contribution.
+func (m *Model) Explain(user, product, k int) ([]int, []float64, error) {
+	if user < 0 || user >= m.X.Rows() || product < 0 || product >= m.Y.Cols() {
+		return nil, nil, wrap(ErrInvalidArgument, "User/Product index out of range")
+	}
+	target := m.Y.ColCopy(product)
+	rated := make([]int, 0)
+	for i := range m.rated(user) {
+		if i != product {
+			rated = append(rated, i)
+		}
+	}
+	ids, contributions := rank(rated, k, func(i int) float64 {
+		return collabFilter.CosineSim(target, m.Y.ColCopy(i)) * m.W.Get(user, i) * m.P.Get(user, i)
+	})
+	return ids, contributions, nil
+}
@@ -0,0 +1,64 @@
+package ALS
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Returns the user factors, one row per user.
+func (m *Model) UserFactors() *DenseMatrix {
+	return m.X.Copy()
+}
+
+// Returns the product factors, one row per product (the transpose of Y).
+func (m *Model) ProductFactors() *DenseMatrix {
+	return m.Y.Transpose()
+}
+
+// Writes the matrix as CSV, one row per line, e.g. the factors from UserFactors/ProductFactors
+// for analysis in a notebook.
+func WriteCSV(w io.Writer, M *DenseMatrix) error {
+	bw := bufio.NewWriter(w)
+	for r := 0; r < M.Rows(); r++ {
+		values := make([]string, M.Cols())
+		for c, val := range M.RowCopy(r) {
+			values[c] = strconv.FormatFloat(val, 'g', -1, 64)
+		}
+		if _, err := bw.WriteString(strings.Join(values, ",") + "\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Writes the matrix in NumPy's .npy format (version 1.0, little endian float64, C order), so it
+// loads with numpy.load.
+func WriteNpy(w io.Writer, M *DenseMatrix) error {
+	header := fmt.Sprintf("{'descr': '<f8', 'fortran_order': False, 'shape': (%d, %d), }", M.Rows(), M.Cols())
+	// magic, version and header length take 10 bytes; pad the header so the data is 64 byte aligned
+	padding := 64 - (10+len(header)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	header += strings.Repeat(" ", padding) + "\n"
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("\x93NUMPY\x01\x00")
+	binary.Write(bw, binary.LittleEndian, uint16(len(header)))
+	bw.WriteString(header)
+	buf := make([]byte, 8)
+	for _, val := range M.Array() {
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(val))
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,37 @@
+package ALS
+
+// A business rule deciding whether a product may be recommended, e.g. whether it is in stock
+// or in an allowed category. Returns true to keep the product.
+type Filter func(product int) bool
+
+// Rejects the given products.
+func Blocklist(products ...int) Filter {
+	blocked := make(map[int]bool, len(products))
+	for _, i := range products {
+		blocked[i] = true
+	}
+	return func(product int) bool {
+		return !blocked[product]
+	}
+}
+
+// Only keeps the given products.
+func Allowlist(products ...int) Filter {
+	allowed := make(map[int]bool, len(products))
+	for _, i := range products {
+		allowed[i] = true
+	}
+	return func(product int) bool {
+		return allowed[product]
+	}
+}
+
+// whether every filter keeps the product
+func keep(filters []Filter, product int) bool {
+	for _, f := range filters {
+		if !f(product) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,281 @@
+package ALS
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// A read only model with float32 factors, for serving large models in half the memory of a Model.
+// Either a trained Model is rounded with Float32, or TrainModel32 keeps the factors in float32
+// while training; both cost next to nothing in accuracy. X holds the user factors and Y the product factors, both row-major with
+// one row per user/product, and Rated the sorted products each user rated.
+type Model32 struct {
+	Users, Products, Factors int
+	X, Y                     []float32
+	Rated                    [][]int32
+	Norm                     *Normalizer
+	MinRating, MaxRating     float64
+	Clip                     bool
+}
+
+// Returns a float32 copy of the model for serving. Updates to m are not reflected in it.
+func (m *Model) Float32() *Model32 {
+	c := &Model32{
+		Users:     m.X.Rows(),
+		Products:  m.Y.Cols(),
+		Factors:   m.X.Cols(),
+		Rated:     make([][]int32, m.X.Rows()),
+		Norm:      m.Norm.copy(),
+		MinRating: m.MinRating,
+		MaxRating: m.MaxRating,
+		Clip:      m.Clip,
+	}
+	c.X = toFloat32(m.X.Array())
+	c.Y = toFloat32(m.Y.Transpose().Array())
+	for u := 0; u < c.Users && u < m.P.Rows(); u++ {
+		for i, o := range m.observedRow(u) {
+			if o != 0 {
+				c.Rated[u] = append(c.Rated[u], int32(i))
+			}
+		}
+	}
+	return c
+}
+
+// Trains an explicit model like TrainModel, but keeps the factors in float32 while iterating,
+// rounding each user's and product's solution as it is stored; its normal equations are still
+// built and solved in float64, one at a time. The random starting factors are drawn in float64
+// and dropped once rounded, but the weights and targets are held as dense float64 matrices, along
+// with their transposes, for the whole training, so this halves the memory of the factors only.
+// Honours the options TrainModel does for the data (WithObserved, WithMissingValue, WithWeights,
+// WithTimeDecay, WithNormalization, WithClipping) and the solve (WithWeightedLambda,
+// WithNonNegative, WithMaxNorm, WithSeed, WithParallelism); iteration callbacks, checkpoints and
+// validation are ignored, and WithSVDInit and WithConjugateGradient fail with ErrInvalidArgument.
+// Fails like TrainModel otherwise.
+func TrainModel32(Q *DenseMatrix, n_factors, iterations int, lambda float64, opts ...Option) (*Model32, error) {
+	cfg := newConfig(opts)
+	if cfg.svdInit || cfg.cgSteps > 0 {
+		return nil, wrap(ErrInvalidArgument, "TrainModel32 does not support SVD initialization or conjugate gradient")
+	}
+	observed := cfg.observedMask(Q)
+	W := cfg.decayed(observed, cfg.weightsFor(Q, observed.Copy()), false)
+	norm := newNormalizer(Q, observed, cfg.normalization, cfg.normalizeByItem)
+	P := maskedTargets(Q, observed)
+	if norm != nil {
+		P = norm.apply(Q, observed)
+	}
+	X0, Y0 := cfg.initialFactors(Q.Rows(), Q.Cols(), n_factors, matrixMax(Q))
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	m := &Model32{
+		Users:    Q.Rows(),
+		Products: Q.Cols(),
+		Factors:  n_factors,
+		X:        toFloat32(X0.Array()),
+		Y:        toFloat32(Y0.Transpose().Array()),
+		Rated:    make([][]int32, Q.Rows()),
+		Norm:     norm,
+		Clip:     cfg.clip,
+	}
+	m.MinRating, m.MaxRating = ratingRange(Q, observed)
+
+	byUser, byProduct := W.Arrays(), W.Transpose().Arrays()
+	targets, targetsT := P.Arrays(), P.Transpose().Arrays()
+	for iter := 0; iter < iterations; iter++ {
+		if err := cfg.solve32(m.X, m.Y, byUser, targets, lambda); err != nil {
+			return nil, err
+		}
+		if err := cfg.solve32(m.Y, m.X, byProduct, targetsT, lambda); err != nil {
+			return nil, err
+		}
+	}
+	for u := range m.Rated {
+		for i, o := range observed.RowCopy(u) {
+			if o != 0 {
+				m.Rated[u] = append(m.Rated[u], int32(i))
+			}
+		}
+	}
+	return m, nil
+}
+
+// re-solves every row of the row-major factors X against the fixed factors F, where row r of X
+// is fit to targets P[r] with weights W[r]; the float32 counterpart of solveAll
+func (c *config) solve32(X, F []float32, W, P [][]float64, lambda float64) error {
+	k := len(X) / len(W)
+	errs := make([]error, len(W))
+	parallelFor(len(W), c.parallelism, func(start, end int) {
+		for r := start; r < end; r++ {
+			A, b := Zeros(k, k), make([]float64, k)
+			n := 0
+			for j, w := range W[r] {
+				if w == 0 {
+					continue
+				}
+				n++
+				f := F[j*k : (j+1)*k]
+				for a := 0; a < k; a++ {
+					wf := w * float64(f[a])
+					b[a] += wf * P[r][j]
+					for d := 0; d <= a; d++ {
+						A.Set(a, d, A.Get(a, d)+wf*float64(f[d]))
+					}
+				}
+			}
+			l := lambda
+			if c.weightedLambda && n > 0 {
+				l *= float64(n)
+			}
+			for a := 0; a < k; a++ {
+				A.Set(a, a, A.Get(a, a)+l)
+				for d := 0; d < a; d++ {
+					A.Set(d, a, A.Get(a, d))
+				}
+			}
+			chol, err := factorCholesky(A)
+			if err != nil {
+				errs[r] = err
+				continue
+			}
+			for a, v := range c.constrain(chol.solve(b)) {
+				X[r*k+a] = float32(v)
+			}
+		}
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toFloat32(values []float64) []float32 {
+	out := make([]float32, len(values))
+	for n, v := range values {
+		out[n] = float32(v)
+	}
+	return out
+}
+
+func (m *Model32) user(u int) []float32 {
+	return m.X[u*m.Factors : (u+1)*m.Factors]
+}
+
+func (m *Model32) product(i int) []float32 {
+	return m.Y[i*m.Factors : (i+1)*m.Factors]
+}
+
+func dot32(a, b []float32) float32 {
+	var sum float32
+	for f := range a {
+		sum += a[f] * b[f]
+	}
+	return sum
+}
+
+func (m *Model32) score(user, product int) float64 {
+	pred := float64(dot32(m.user(user), m.product(product)))
+	if m.Norm != nil {
+		pred = m.Norm.Denormalize(user, product, pred)
+	}
+	if m.Clip {
+		pred = math.Max(m.MinRating, math.Min(m.MaxRating, pred))
+	}
+	return pred
+}
+
+// Returns the predicted value for a given user-product pair. Error if out of range.
+func (m *Model32) Predict(user, product int) (float64, error) {
+	if user < 0 || user >= m.Users || product < 0 || product >= m.Products {
+		return 0.0, wrap(ErrInvalidArgument, "User/Product index out of range")
+	}
+	return m.score(user, product), nil
+}
+
+// Returns the n products with the highest predicted values for the user that they haven't rated,
+// in descending order along with their predictions, like Model.TopN.
+func (m *Model32) TopN(user, n int) ([]int, []float64, error) {
+	if user < 0 || user >= m.Users {
+		return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	products := make([]int, 0, m.Products)
+	rated := m.Rated[user]
+	for i := 0; i < m.Products; i++ {
+		// both are sorted, so walk them together
+		if len(rated) > 0 && int(rated[0]) == i {
+			rated = rated[1:]
+			continue
+		}
+		products = append(products, i)
+	}
+	ids, scores := rank(products, n, func(i int) float64 {
+		return m.score(user, i)
+	})
+	return ids, scores, nil
+}
+
+// Returns the k products most similar to the given product by the cosine similarity of their
+// factors, like Model.SimilarItems.
+func (m *Model32) SimilarItems(product, k int) ([]int, []float64, error) {
+	if product < 0 || product >= m.Products {
+		return nil, nil, wrap(ErrInvalidArgument, "Product index out of range")
+	}
+	target := m.product(product)
+	norm := float32(math.Sqrt(float64(dot32(target, target))))
+	others := make([]int, 0, m.Products-1)
+	for i := 0; i < m.Products; i++ {
+		if i != product {
+			others = append(others, i)
+		}
+	}
+	ids, sims := rank(others, k, func(i int) float64 {
+		other := m.product(i)
+		denom := norm * float32(math.Sqrt(float64(dot32(other, other))))
+		if denom == 0 {
+			return 0
+		}
+		return float64(dot32(target, other) / denom)
+	})
+	return ids, sims, nil
+}
+
+// Returns the user factors, one row per user, like Model.UserFactors.
+func (m *Model32) UserFactors() *DenseMatrix {
+	return MakeDenseMatrix(toFloat64(m.X), m.Users, m.Factors)
+}
+
+// Returns the product factors, one row per product, like Model.ProductFactors.
+func (m *Model32) ProductFactors() *DenseMatrix {
+	return MakeDenseMatrix(toFloat64(m.Y), m.Products, m.Factors)
+}
+
+func toFloat64(values []float32) []float64 {
+	out := make([]float64, len(values))
+	for n, v := range values {
+		out[n] = float64(v)
+	}
+	return out
+}
+
+// Writes the float32 model to w. Read it back with LoadModel32.
+func (m *Model32) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(m)
+}
+
+// Reads a float32 model written by Model32.Save.
+func LoadModel32(r io.Reader) (*Model32, error) {
+	m := &Model32{}
+	if err := gob.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	if len(m.X) != m.Users*m.Factors || len(m.Y) != m.Products*m.Factors || len(m.Rated) != m.Users {
+		return nil, errors.New("Saved model has inconsistent dimensions")
+	}
+	return m, nil
+}
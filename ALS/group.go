@@ -0,0 +1,77 @@
+package ALS
+
+import (
+	"errors"
+	"math"
+)
+
+// How TopNForGroup combines the predictions of the members of a group into the group's score.
+type Aggregation int
+
+const (
+	// the mean of the members' predictions: best for the group as a whole
+	Average Aggregation = iota
+	// the lowest prediction of any member: nobody is made miserable
+	LeastMisery
+	// the highest prediction of any member: somebody is delighted
+	MostPleasure
+)
+
+// Returns the n products with the highest aggregated predictions for a group of users, e.g. a
+// family sharing an account or a watch party, in descending order along with the group's scores.
+// Products any member already rated are skipped, as are products rejected by any of the filters.
+// Error if the group is empty or a user index is out of range.
+func (m *Model) TopNForGroup(users []int, n int, strategy Aggregation, filters ...Filter) ([]int, []float64, error) {
+	if len(users) == 0 {
+		return nil, nil, errors.New("Group needs at least one user")
+	}
+	members := make([]int, 0, len(users))
+	factors := make([][]float64, 0, len(users))
+	rated := make(map[int]bool)
+	seen := make(map[int]bool, len(users))
+	for _, u := range users {
+		if u < 0 || u >= m.X.Rows() {
+			return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+		}
+		// a member listed twice doesn't count twice
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		members = append(members, u)
+		factors = append(factors, m.X.RowCopy(u))
+		for i := range m.rated(u) {
+			rated[i] = true
+		}
+	}
+	products := make([]int, 0, m.Y.Cols())
+	for i := 0; i < m.Y.Cols(); i++ {
+		if !rated[i] && keep(filters, i) {
+			products = append(products, i)
+		}
+	}
+	score := func(i int) float64 {
+		y := m.Y.ColCopy(i)
+		group := 0.0
+		switch strategy {
+		case LeastMisery:
+			group = math.Inf(1)
+		case MostPleasure:
+			group = math.Inf(-1)
+		}
+		for k, u := range members {
+			pred := m.denormalize(u, i, dot(factors[k], y))
+			switch strategy {
+			case LeastMisery:
+				group = math.Min(group, pred)
+			case MostPleasure:
+				group = math.Max(group, pred)
+			default:
+				group += pred / float64(len(members))
+			}
+		}
+		return group
+	}
+	ids, scores := rank(products, n, score)
+	return ids, scores, nil
+}
@@ -0,0 +1,112 @@
+package ALS
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+// Metrics recorded after a single training iteration.
+// ValidationError is only meaningful when training with WithValidation.
+type IterationStats struct {
+	Iteration       int
+	TrainingError   float64
+	ValidationError float64
+}
+
+// Learning curve of a training run, one entry per iteration, for plotting convergence and
+// spotting over-regularization (training and validation error both flat and high).
+type History struct {
+	Validated  bool
+	Iterations []IterationStats
+}
+
+// Output formats for StreamHistory.
+type HistoryFormat int
+
+const (
+	// comma separated values with a header row
+	CSV HistoryFormat = iota
+	// one JSON object per line
+	JSON
+)
+
+// Writes every iteration's metrics to w as soon as it completes, so long runs can be watched live.
+// The full history is also kept on the trained model either way.
+func StreamHistory(w io.Writer, format HistoryFormat) Option {
+	return func(c *config) {
+		c.historyWriter = w
+		c.historyFormat = format
+	}
+}
+
+func (s IterationStats) fields(validated bool) []string {
+	fields := []string{
+		strconv.Itoa(s.Iteration),
+		strconv.FormatFloat(s.TrainingError, 'g', -1, 64),
+	}
+	if validated {
+		fields = append(fields, strconv.FormatFloat(s.ValidationError, 'g', -1, 64))
+	}
+	return fields
+}
+
+func csvHeader(validated bool) []string {
+	header := []string{"iteration", "training_error"}
+	if validated {
+		header = append(header, "validation_error")
+	}
+	return header
+}
+
+func writeJSONStats(w io.Writer, s IterationStats, validated bool) error {
+	record := map[string]interface{}{
+		"iteration":      s.Iteration,
+		"training_error": s.TrainingError,
+	}
+	if validated {
+		record["validation_error"] = s.ValidationError
+	}
+	return json.NewEncoder(w).Encode(record)
+}
+
+// Writes the history to w as CSV, with a header row.
+func (h *History) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write(csvHeader(h.Validated))
+	for _, s := range h.Iterations {
+		out.Write(s.fields(h.Validated))
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// Writes the history to w as JSON, one object per iteration and line.
+func (h *History) WriteJSON(w io.Writer) error {
+	for _, s := range h.Iterations {
+		if err := writeJSONStats(w, s, h.Validated); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appends an iteration's metrics to the history, streaming them out if asked to
+func (c *config) record(h *History, s IterationStats) {
+	h.Iterations = append(h.Iterations, s)
+	if c.historyWriter == nil {
+		return
+	}
+	if c.historyFormat == JSON {
+		c.fail(writeJSONStats(c.historyWriter, s, h.Validated))
+		return
+	}
+	out := csv.NewWriter(c.historyWriter)
+	if len(h.Iterations) == 1 {
+		out.Write(csvHeader(h.Validated))
+	}
+	out.Write(s.fields(h.Validated))
+	out.Flush()
+	c.fail(out.Error())
+}
@@ -0,0 +1,181 @@
+package ALS
+
+import (
+	"errors"
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/collabFilter"
+)
+
+// A hybrid recommender scoring a user/product pair with (1-Weight)*ALS + Weight*KNN, where KNN is
+// the item-based neighborhood prediction from the user's own ratings of the product's most similar
+// products. The neighborhood picks up local item-item patterns the low rank factors smooth over,
+// which mostly helps users with few ratings. Weight is 0 (plain ALS) to 1 (plain KNN); FitWeight
+// learns it from held out ratings. Pairs without any rated neighbor get the ALS score.
+// Users without any interactions, whose factors say little, are handed to ColdStart if set.
+type Hybrid struct {
+	Model     *Model
+	Neighbors *collabFilter.ItemNeighbors
+	Weight    float64
+	ColdStart ColdStart
+}
+
+// Recommends products to users without any interactions, e.g. from attributes they signed up with
+// (see baseline.Cohorts). Users may be past the end of the model, such as ones that signed up after
+// training.
+type ColdStart interface {
+	// the n best products for the user among those keep accepts, best first, and their scores
+	ColdStart(user, n int, keep func(product int) bool) ([]int, []float64)
+}
+
+// Builds a hybrid of the model with the k nearest neighbors of every product, computed with cosine
+// similarity from the ratings the model was trained on.
+func NewHybrid(m *Model, k int, weight float64) *Hybrid {
+	prefs := m.P
+	if !m.Implicit {
+		prefs = maskedTargets(m.P, m.observed())
+	}
+	return &Hybrid{
+		Model:     m,
+		Neighbors: collabFilter.ComputeItemNeighbors(prefs, k, nil),
+		Weight:    weight,
+	}
+}
+
+// the ALS and neighborhood scores of a pair; ok is false if the user rated none of the neighbors
+func (h *Hybrid) scores(user, product int, ratings []float64) (als, knn float64, ok bool) {
+	m := h.Model
+	als = m.denormalize(user, product, dot(m.X.RowCopy(user), m.Y.ColCopy(product)))
+	items, sims := h.Neighbors.Neighbors(product)
+	if m.Implicit {
+		// share of the product's neighborhood the user interacted with, on the scale of the preferences
+		hit, total := 0.0, 0.0
+		for n, i := range items {
+			if ratings[i] != 0 {
+				hit += sims[n]
+				ok = true
+			}
+			total += math.Abs(sims[n])
+		}
+		if ok {
+			knn = hit / total
+		}
+		return
+	}
+	for _, i := range items {
+		if ratings[i] != 0 {
+			ok = true
+			break
+		}
+	}
+	if ok {
+		knn = m.denormalize(user, product, h.Neighbors.Predict(ratings, product))
+	}
+	return
+}
+
+func (h *Hybrid) blend(user, product int, ratings []float64) float64 {
+	als, knn, ok := h.scores(user, product, ratings)
+	if !ok {
+		return als
+	}
+	return (1-h.Weight)*als + h.Weight*knn
+}
+
+// the user's ratings as the neighborhood sees them, 0 where unrated
+func (h *Hybrid) ratings(user int) []float64 {
+	ratings := h.Model.P.RowCopy(user)
+	if !h.Model.Implicit {
+		for i, o := range h.Model.observedRow(user) {
+			if o == 0 {
+				ratings[i] = 0
+			}
+		}
+	}
+	return ratings
+}
+
+// Returns the blended score of a user/product pair. Error if either index is out of range.
+func (h *Hybrid) Predict(user, product int) (float64, error) {
+	if user < 0 || user >= h.Model.P.Rows() || product < 0 || product >= h.Model.P.Cols() {
+		return 0, wrap(ErrInvalidArgument, "User/Product index out of range")
+	}
+	return h.blend(user, product, h.ratings(user)), nil
+}
+
+// Like Model.TopN, but ranks the products the user hasn't rated by their blended score.
+// Users without interactions get the ColdStart recommendations, if set.
+func (h *Hybrid) TopN(user, n int, filters ...Filter) ([]int, []float64, error) {
+	if user < 0 {
+		return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	rated := h.Model.rated(user)
+	if h.ColdStart != nil && len(rated) == 0 {
+		ids, scores := h.ColdStart.ColdStart(user, n, func(product int) bool {
+			return product < h.Model.Y.Cols() && keep(filters, product)
+		})
+		return ids, scores, nil
+	}
+	if user >= h.Model.P.Rows() {
+		return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	products := make([]int, 0, h.Model.Y.Cols())
+	for i := 0; i < h.Model.Y.Cols(); i++ {
+		if !rated[i] && keep(filters, i) {
+			products = append(products, i)
+		}
+	}
+	ratings := h.ratings(user)
+	ids, scores := rank(products, n, func(i int) float64 {
+		return h.blend(user, i, ratings)
+	})
+	return ids, scores, nil
+}
+
+// Learns Weight from held out ratings V (same shape as the training matrix, 0 or NaN where not held
+// out) by least squares on the pairs that have a neighborhood score, clamped to [0, 1].
+// For implicit models every product a user with held out interactions hasn't trained on is a target:
+// 1 if it was held out, 0 otherwise. Returns the new weight.
+func (h *Hybrid) FitWeight(V *DenseMatrix) (float64, error) {
+	m := h.Model
+	if V.Rows() != m.P.Rows() || V.Cols() != m.P.Cols() {
+		return h.Weight, errors.New("Held out ratings need the same dimensions as the training matrix")
+	}
+	held := notMissing(V, m.MissingValue)
+	// minimizes sum (target - als - w*(knn - als))^2 over w
+	num, den := 0.0, 0.0
+	for u := 0; u < V.Rows(); u++ {
+		row := held.RowCopy(u)
+		some := false
+		for _, o := range row {
+			some = some || o != 0
+		}
+		if !some {
+			continue
+		}
+		ratings := h.ratings(u)
+		for i, o := range row {
+			target := V.Get(u, i)
+			if m.Implicit {
+				if m.P.Get(u, i) != 0 {
+					continue
+				}
+				target = o
+			} else if o == 0 {
+				continue
+			}
+			als, knn, ok := h.scores(u, i, ratings)
+			if !ok {
+				continue
+			}
+			num += (target - als) * (knn - als)
+			den += (knn - als) * (knn - als)
+		}
+	}
+	if den == 0 {
+		return h.Weight, errors.New("No held out ratings with a neighborhood score")
+	}
+	h.Weight = math.Max(0, math.Min(1, num/den))
+	return h.Weight, nil
+}
@@ -0,0 +1,149 @@
+package ALS
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Builds a model from externally trained embeddings (e.g. Spark ALS or a neural model), so the
+// prediction, similarity, re-ranking and serving code can be used without retraining in Go.
+// userFactors and productFactors have one row per user/product, as written by UserFactors and
+// ProductFactors. Q holds the known ratings, which TopN skips and Update builds on; it may be nil.
+// lambda is only used by Update. WithObserved and WithMissingValue say which entries of Q are
+// ratings, as for training; other options are ignored.
+func ModelFromFactors(userFactors, productFactors, Q *DenseMatrix, lambda float64, implicit bool, opts ...Option) (*Model, error) {
+	if userFactors.Cols() != productFactors.Cols() {
+		return nil, errors.New("User and product factors need the same number of factors")
+	}
+	users, products := userFactors.Rows(), productFactors.Rows()
+	if Q == nil {
+		Q = Zeros(users, products)
+	}
+	if Q.Rows() != users || Q.Cols() != products {
+		return nil, errors.New("Rating matrix does not match the number of users and products")
+	}
+	cfg := newConfig(opts)
+	observed := cfg.observedMask(Q)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	m := &Model{
+		X:            userFactors.Copy(),
+		Y:            productFactors.Transpose(),
+		Lambda:       lambda,
+		Implicit:     implicit,
+		MissingValue: cfg.missing(),
+	}
+	if implicit {
+		m.W, m.P = confidences(Q, observed), observed
+	} else {
+		m.W, m.P = observed, maskedTargets(Q, observed)
+		m.MinRating, m.MaxRating = ratingRange(Q, m.W)
+	}
+	m.meta = Metadata{Version: FormatVersion, Algorithm: AlgorithmImported, TrainedAt: time.Now(), Fingerprint: Fingerprint(Q)}
+	return m, nil
+}
+
+// Reads a matrix from CSV, one row per line, as written by WriteCSV.
+func ReadCSV(r io.Reader) (*DenseMatrix, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("CSV has no rows")
+	}
+	rows := make([][]float64, len(records))
+	for i, record := range records {
+		rows[i] = make([]float64, len(record))
+		for j, value := range record {
+			if rows[i][j], err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return MakeDenseMatrixStacked(rows), nil
+}
+
+var npyShape = regexp.MustCompile(`'shape':\s*\((\d+),\s*(\d*)\)`)
+
+// Reads a two dimensional float64 or float32 matrix in NumPy's .npy format, as written by
+// WriteNpy or numpy.save. Fortran ordered arrays are supported; big endian ones are not.
+func ReadNpy(r io.Reader) (*DenseMatrix, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic[:6]) != "\x93NUMPY" {
+		return nil, errors.New("Not a .npy file")
+	}
+	var headerLen int
+	switch magic[6] {
+	case 1:
+		var n uint16
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	default:
+		return nil, errors.New("Unsupported .npy version")
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+
+	h := string(header)
+	size := 0
+	switch {
+	case strings.Contains(h, "'<f8'"):
+		size = 8
+	case strings.Contains(h, "'<f4'"):
+		size = 4
+	default:
+		return nil, errors.New("Only little endian float64 and float32 .npy files are supported")
+	}
+	shape := npyShape.FindStringSubmatch(h)
+	if shape == nil {
+		return nil, errors.New("Could not read the shape of the .npy file")
+	}
+	rows, _ := strconv.Atoi(shape[1])
+	cols := 1
+	if shape[2] != "" {
+		cols, _ = strconv.Atoi(shape[2])
+	}
+
+	data := make([]byte, rows*cols*size)
+	if _, err := io.ReadFull(br, data); err != nil {
+		return nil, err
+	}
+	values := make([]float64, rows*cols)
+	for n := range values {
+		if size == 8 {
+			values[n] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*n:]))
+		} else {
+			values[n] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*n:])))
+		}
+	}
+	if strings.Contains(h, "'fortran_order': True") {
+		return MakeDenseMatrix(values, cols, rows).Transpose(), nil
+	}
+	return MakeDenseMatrix(values, rows, cols), nil
+}
@@ -0,0 +1,59 @@
+package ALS
+
+import . "github.com/skelterjohn/go.matrix"
+
+// Lazy alternatives to Predictions, which materializes the full users x products matrix even
+// though usually only a few users' scores, or only their unobserved cells, are needed.
+
+// Returns the predictions for every product for a single user, on the original rating scale,
+// passed through the post-processing steps, if any.
+func (m *Model) PredictUser(user int, steps ...PostProcessor) ([]float64, error) {
+	if user < 0 || user >= m.X.Rows() {
+		return nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	x := m.X.RowCopy(user)
+	preds := make([]float64, m.Y.Cols())
+	for i := range preds {
+		preds[i] = m.denormalize(user, i, dot(x, m.Y.ColCopy(i)))
+	}
+	for _, step := range steps {
+		step(user, preds)
+	}
+	return preds, nil
+}
+
+// Returns the predictions of the given users only, one row per user in the given order,
+// passed through the post-processing steps, if any.
+func (m *Model) PredictUsers(users []int, steps ...PostProcessor) (*DenseMatrix, error) {
+	rows := make([][]float64, len(users))
+	for n, u := range users {
+		preds, err := m.PredictUser(u, steps...)
+		if err != nil {
+			return nil, err
+		}
+		rows[n] = preds
+	}
+	if len(rows) == 0 {
+		return Zeros(0, m.Y.Cols()), nil
+	}
+	return MakeDenseMatrixStacked(rows), nil
+}
+
+// Returns the products the user has not rated (or interacted with) along with their predictions,
+// in product order, which are the cells recommendations are picked from.
+func (m *Model) PredictMissing(user int) ([]int, []float64, error) {
+	if user < 0 || user >= m.X.Rows() {
+		return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	rated := m.rated(user)
+	x := m.X.RowCopy(user)
+	products := make([]int, 0, m.Y.Cols()-len(rated))
+	preds := make([]float64, 0, m.Y.Cols()-len(rated))
+	for i := 0; i < m.Y.Cols(); i++ {
+		if !rated[i] {
+			products = append(products, i)
+			preds = append(preds, m.denormalize(user, i, dot(x, m.Y.ColCopy(i))))
+		}
+	}
+	return products, preds, nil
+}
@@ -0,0 +1,21 @@
+package ALS
+
+// Logger receives diagnostic messages from the ALS package, such as the final training error. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// nothing is printed unless the application asks for it
+var logger Logger = nopLogger{}
+
+// Sends the package's diagnostic messages to l. Pass nil to silence them again (the default).
+func SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	logger = l
+}
@@ -0,0 +1,109 @@
+package ALS
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Version of the format written by Save. LoadModel refuses models written by a newer version.
+const FormatVersion = 1
+
+// names of the algorithms a Model can come from
+const (
+	AlgorithmALS         = "als"
+	AlgorithmImplicitALS = "als-implicit"
+	AlgorithmImported    = "imported"
+	AlgorithmSGD         = "sgd"
+	AlgorithmWARP        = "warp"
+	AlgorithmEALS        = "eals"
+)
+
+// Describes where a model came from: the format version it was saved with, the algorithm and
+// hyperparameters it was trained with, when, and a fingerprint of the training data to tell whether
+// two models were trained on the same ratings. Models saved before metadata existed have Version 0.
+// Updates after training don't change the metadata.
+type Metadata struct {
+	Version         int
+	Algorithm       string
+	TrainedAt       time.Time
+	Hyperparameters map[string]float64
+	Fingerprint     string
+}
+
+// Returns the metadata of the model.
+func (m *Model) Metadata() Metadata {
+	meta := m.meta
+	meta.Hyperparameters = make(map[string]float64, len(m.meta.Hyperparameters))
+	for k, v := range m.meta.Hyperparameters {
+		meta.Hyperparameters[k] = v
+	}
+	return meta
+}
+
+// records how the model was just trained on Q
+func (m *Model) trained(algorithm string, Q *DenseMatrix, n_factors, iterations int, cfg *config) {
+	m.meta = Metadata{
+		Version:   FormatVersion,
+		Algorithm: algorithm,
+		TrainedAt: time.Now(),
+		Hyperparameters: map[string]float64{
+			"factors":         float64(n_factors),
+			"iterations":      float64(iterations),
+			"lambda":          m.Lambda,
+			"weighted_lambda": boolFloat(m.WeightedLambda),
+			"cg_steps":        float64(cfg.cgSteps),
+			"half_life":       cfg.halfLife,
+			"non_negative":    boolFloat(cfg.nonNegative),
+			"max_norm":        cfg.maxNorm,
+		},
+		Fingerprint: Fingerprint(Q),
+	}
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Returns a hex SHA-256 of the shape and values of a rating matrix, as recorded in the metadata
+// of the models trained on it.
+func Fingerprint(Q *DenseMatrix) string {
+	h := sha256.New()
+	buf := make([]byte, 8)
+	for _, n := range []int{Q.Rows(), Q.Cols()} {
+		binary.LittleEndian.PutUint64(buf, uint64(n))
+		h.Write(buf)
+	}
+	for _, val := range Q.Array() {
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(val))
+		h.Write(buf)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checks that a saved model can be loaded by this version of the package
+func (meta Metadata) compatible(implicit bool) error {
+	if meta.Version > FormatVersion {
+		return errors.New("Saved model has format version " + strconv.Itoa(meta.Version) +
+			", newer than the supported " + strconv.Itoa(FormatVersion))
+	}
+	switch meta.Algorithm {
+	case "", AlgorithmImported, AlgorithmSGD, AlgorithmWARP, AlgorithmEALS:
+	case AlgorithmALS, AlgorithmImplicitALS:
+		if (meta.Algorithm == AlgorithmImplicitALS) != implicit {
+			return errors.New("Saved model's algorithm " + meta.Algorithm + " does not match its implicit flag")
+		}
+	default:
+		return errors.New("Saved model was trained with unknown algorithm " + meta.Algorithm)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// By default 0 and NaN mean "not rated", which breaks datasets where 0 is a legitimate rating.
+// These options say explicitly which entries are observed instead.
+
+// Mark the observed entries of the rating matrix with a mask of the same shape, nonzero for
+// observed entries (e.g. from data.Dataset's Observed), so ratings of 0 are trained on like any
+// other. The mask decides the weights, targets, normalization and rating range of every trainer.
+// Elsewhere (validation sets, Model.Update) only NaN means missing then, unless WithMissingValue
+// is given too.
+func WithObserved(mask *DenseMatrix) Option {
+	return func(c *config) {
+		c.observed = mask
+	}
+}
+
+// Treat entries equal to value as missing instead of 0, e.g. -1 when 0 is a valid rating.
+// NaN always means missing. Also applies to the validation set of WithValidation.
+func WithMissingValue(value float64) Option {
+	return func(c *config) {
+		c.missingValue = value
+		c.missingSet = true
+	}
+}
+
+// the value that means "not rated": the WithMissingValue sentinel, NaN (only) with a WithObserved
+// mask, 0 otherwise. Models keep it as MissingValue.
+func (c *config) missing() float64 {
+	switch {
+	case c.missingSet:
+		return c.missingValue
+	case c.observed != nil:
+		return math.NaN()
+	}
+	return 0
+}
+
+// 0/1 mask of the entries of Q that are not missing according to the sentinel value
+func (c *config) missingMask(Q *DenseMatrix) *DenseMatrix {
+	return notMissing(Q, c.missing())
+}
+
+// 0/1 mask of the entries of Q that are neither equal to missing nor NaN
+func notMissing(Q *DenseMatrix, missing float64) *DenseMatrix {
+	mask := Zeros(Q.Rows(), Q.Cols())
+	for u := 0; u < Q.Rows(); u++ {
+		for i := 0; i < Q.Cols(); i++ {
+			if val := Q.Get(u, i); val != missing && !math.IsNaN(val) {
+				mask.Set(u, i, 1)
+			}
+		}
+	}
+	return mask
+}
+
+// 0/1 mask of the observed entries of the rating matrix Q: the WithObserved mask if it is valid,
+// otherwise the entries that are not missing
+func (c *config) observedMask(Q *DenseMatrix) *DenseMatrix {
+	if c.observed == nil {
+		return c.missingMask(Q)
+	}
+	if c.observed.Rows() != Q.Rows() || c.observed.Cols() != Q.Cols() {
+		c.fail(wrap(ErrDimensionMismatch, "Observed mask needs to be the same dimension as the rating matrix"))
+		return c.missingMask(Q)
+	}
+	mask := Zeros(Q.Rows(), Q.Cols())
+	for u := 0; u < Q.Rows(); u++ {
+		for i := 0; i < Q.Cols(); i++ {
+			if o := c.observed.Get(u, i); o != 0 && !math.IsNaN(o) && !math.IsNaN(Q.Get(u, i)) {
+				mask.Set(u, i, 1)
+			}
+		}
+	}
+	return mask
+}
+
+// the ratings of Q at the observed entries, 0 elsewhere
+func maskedTargets(Q, mask *DenseMatrix) *DenseMatrix {
+	P := Zeros(Q.Rows(), Q.Cols())
+	for u := 0; u < Q.Rows(); u++ {
+		for i := 0; i < Q.Cols(); i++ {
+			if mask.Get(u, i) != 0 {
+				P.Set(u, i, Q.Get(u, i))
+			}
+		}
+	}
+	return P
+}
+
+// implicit confidences: 1 + 40*r at the observed entries, 1 elsewhere
+func confidences(R, mask *DenseMatrix) *DenseMatrix {
+	C := Numbers(R.Rows(), R.Cols(), 1)
+	for u := 0; u < R.Rows(); u++ {
+		for i := 0; i < R.Cols(); i++ {
+			if mask.Get(u, i) != 0 {
+				C.Set(u, i, 1+40*R.Get(u, i))
+			}
+		}
+	}
+	return C
+}
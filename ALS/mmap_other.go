@@ -0,0 +1,36 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package ALS
+
+import (
+	"io"
+	"os"
+)
+
+// Without mmap the file is read into memory, and written back on close if writable.
+type mapping struct {
+	data []byte
+	file *os.File
+}
+
+func mapFile(f *os.File, size int, writable bool) (*mapping, error) {
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	m := &mapping{data: data}
+	if writable {
+		m.file = f
+	}
+	return m, nil
+}
+
+func (m *mapping) close() error {
+	var err error
+	if m.file != nil {
+		_, err = m.file.WriteAt(m.data, 0)
+	}
+	m.data = nil
+	return err
+}
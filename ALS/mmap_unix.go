@@ -0,0 +1,38 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package ALS
+
+import (
+	"os"
+	"syscall"
+)
+
+// a file mapped into memory, paged in and out by the OS
+type mapping struct {
+	data []byte
+}
+
+func mapFile(f *os.File, size int, writable bool) (*mapping, error) {
+	if size == 0 {
+		return &mapping{}, nil
+	}
+	prot := syscall.PROT_READ
+	if writable {
+		prot |= syscall.PROT_WRITE
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, size, prot, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mapping{data: data}, nil
+}
+
+func (m *mapping) close() error {
+	if m.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(m.data)
+	m.data = nil
+	return err
+}
@@ -0,0 +1,262 @@
+package ALS
+
+import (
+	"math"
+	"sync"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/ann"
+)
+
+// A trained ALS model. Rows of X are the user factors and columns of Y are the product factors,
+// so the predicted rating for a user/product pair is the dot product of the two.
+// W and P are the weight (or confidence) and target matrices the model was fit against.
+// Iterations counts the ALS iterations completed so far, and History holds their metrics.
+// Norm is set when the ratings were normalized before training; predictions undo it.
+// With WeightedLambda, each user/product is regularized by Lambda times its number of ratings.
+// MinRating and MaxRating are the range of the explicit ratings trained on; with Clip set,
+// predictions are clamped to it. NonNegative and MaxNorm are the constraints on the factors,
+// see WithNonNegative and WithMaxNorm; Update keeps to them. MissingValue is the rating that means
+// "not rated" to Update and ValidationError besides NaN: 0 unless trained with WithMissingValue, and
+// NaN (so that 0 is a rating) when trained with WithObserved alone. Observed is nonzero where an
+// explicit model trained WithWeights has a rating, since its weights can be 0 for a rating or
+// positive for an unrated pair; it is nil otherwise, and then the nonzero weights are the ratings.
+type Model struct {
+	X, Y                 *DenseMatrix
+	W, P                 *DenseMatrix
+	Observed             *DenseMatrix
+	Lambda               float64
+	WeightedLambda       bool
+	Implicit             bool
+	Iterations           int
+	History              History
+	Norm                 *Normalizer
+	MinRating, MaxRating float64
+	Clip                 bool
+	NonNegative          bool
+	MaxNorm              float64
+	MissingValue         float64
+
+	// optional approximate index over the product factors, see BuildIndex
+	index *ann.Index
+	// how and when the model was trained, see Metadata
+	meta Metadata
+	// cached number of ratings per product, see TopNDebiased
+	popularity []float64
+	// cached mean norms of the user and product factors, see Confidence
+	norms []float64
+	popMu sync.Mutex
+}
+
+// Returns the full user/product prediction matrix X*Y, on the original rating scale.
+func (m *Model) Predictions() *DenseMatrix {
+	Qhat, err := m.X.TimesDense(m.Y)
+	must(err)
+	if m.Norm != nil || m.Clip {
+		for u := 0; u < Qhat.Rows(); u++ {
+			for i := 0; i < Qhat.Cols(); i++ {
+				Qhat.Set(u, i, m.denormalize(u, i, Qhat.Get(u, i)))
+			}
+		}
+	}
+	return Qhat
+}
+
+// maps a raw factor dot product back onto the rating scale, clamping it to the rating range if Clip is set
+func (m *Model) denormalize(user, product int, pred float64) float64 {
+	if m.Norm != nil {
+		pred = m.Norm.Denormalize(user, product, pred)
+	}
+	if m.Clip {
+		pred = math.Max(m.MinRating, math.Min(m.MaxRating, pred))
+	}
+	return pred
+}
+
+// the smallest and largest rating of Q at the entries that are nonzero in observed
+func ratingRange(Q, observed *DenseMatrix) (min, max float64) {
+	min, max = math.Inf(1), math.Inf(-1)
+	mask := observed.Array()
+	for n, val := range Q.Array() {
+		if mask[n] != 0 {
+			min, max = math.Min(min, val), math.Max(max, val)
+		}
+	}
+	if min > max {
+		return 0, 0
+	}
+	return min, max
+}
+
+// Returns the predicted value for a given user-product pair. Error if out of range.
+func (m *Model) Predict(user, product int) (float64, error) {
+	if user < 0 || user >= m.X.Rows() || product < 0 || product >= m.Y.Cols() {
+		return 0.0, wrap(ErrInvalidArgument, "User/Product index out of range")
+	}
+	return m.denormalize(user, product, dot(m.X.RowCopy(user), m.Y.ColCopy(product))), nil
+}
+
+// Scores a list of (user, product) pairs, e.g. for offline scoring or re-ranking candidates.
+// The pairs are split into chunks that are scored in parallel, on as many goroutines as
+// WithParallelism allows (the number of CPUs by default). Pairs with a user or product index
+// out of range get a prediction of NaN.
+func (m *Model) PredictPairs(pairs [][2]int, opts ...Option) []float64 {
+	users := m.X.Arrays()
+	products := m.Y.Transpose().Arrays()
+	preds := make([]float64, len(pairs))
+
+	parallelFor(len(pairs), newConfig(opts).parallelism, func(start, end int) {
+		for n := start; n < end; n++ {
+			u, i := pairs[n][0], pairs[n][1]
+			if u < 0 || u >= len(users) || i < 0 || i >= len(products) {
+				preds[n] = math.NaN()
+				continue
+			}
+			preds[n] = m.denormalize(u, i, dot(users[u], products[i]))
+		}
+	})
+	return preds
+}
+
+// weight and target for a single rating, following observedMask/confidences
+func (m *Model) cell(rating float64) (w, p float64) {
+	missing := m.isMissing(rating)
+	if m.Implicit {
+		if missing {
+			return 1, 0
+		}
+		return 1 + 40*rating, 1
+	}
+	if missing {
+		return 0, 0
+	}
+	return 1, rating
+}
+
+// nonzero where a user rated (or interacted with) a product: Observed or else the weights of an
+// explicit model, since a normalized target can be 0, and the 0/1 preferences of an implicit one
+func (m *Model) observed() *DenseMatrix {
+	if m.Implicit {
+		return m.P
+	}
+	if m.Observed != nil {
+		return m.Observed
+	}
+	return m.W
+}
+
+// whether a rating means "not rated" to the model, see MissingValue
+func (m *Model) isMissing(rating float64) bool {
+	return rating == m.MissingValue || math.IsNaN(rating)
+}
+
+// weight and (normalized) target for a user's rating of a product
+func (m *Model) cellAt(user, product int, rating float64) (w, p float64) {
+	w, p = m.cell(rating)
+	if m.Norm != nil && w != 0 {
+		p = m.Norm.Normalize(user, product, rating)
+	}
+	return w, p
+}
+
+// Absorbs a new rating (or implicit count) for a user/product pair without retraining.
+// The rating is stored and only the factors of that user and that product are re-solved.
+// A rating equal to MissingValue (0 by default) removes the pair. Users/products one past the end of the model are added.
+func (m *Model) Update(user, product int, rating float64) error {
+	if math.IsNaN(rating) {
+		return wrap(ErrInvalidArgument, "Cannot update the model with a NaN rating")
+	}
+	if user < 0 || user > m.P.Rows() || product < 0 || product > m.P.Cols() {
+		return wrap(ErrInvalidArgument, "User/Product index out of range")
+	}
+	if user == m.P.Rows() {
+		m.addUser()
+	}
+	if product == m.P.Cols() {
+		m.addProduct()
+	}
+	if !m.Implicit && !m.isMissing(rating) {
+		m.MinRating, m.MaxRating = math.Min(m.MinRating, rating), math.Max(m.MaxRating, rating)
+	}
+	w, p := m.cellAt(user, product, rating)
+	m.W.Set(user, product, w)
+	m.P.Set(user, product, p)
+	if m.Observed != nil {
+		m.Observed.Set(user, product, w)
+	}
+
+	cfg := m.constraints(newConfig(nil))
+	if err := m.solveUser(user, m.Y.Transpose().Arrays(), cfg); err != nil {
+		return err
+	}
+	if err := m.solveItem(product, m.X.Arrays(), cfg); err != nil {
+		return err
+	}
+	// the product factors and ratings changed, so an index over them is out of date
+	m.index = nil
+	m.popMu.Lock()
+	m.popularity, m.norms = nil, nil
+	m.popMu.Unlock()
+	return nil
+}
+
+// appends an empty user to the model
+func (m *Model) addUser() {
+	w, _ := m.cell(math.NaN())
+	m.W, _ = m.W.Stack(Numbers(1, m.W.Cols(), w))
+	m.P, _ = m.P.Stack(Zeros(1, m.P.Cols()))
+	if m.Observed != nil {
+		m.Observed, _ = m.Observed.Stack(Zeros(1, m.Observed.Cols()))
+	}
+	m.X, _ = m.X.Stack(Zeros(1, m.X.Cols()))
+	if m.Norm != nil && !m.Norm.ByItem {
+		m.Norm.grow()
+	}
+}
+
+// appends an unrated product to the model
+func (m *Model) addProduct() {
+	w, _ := m.cell(math.NaN())
+	m.W, _ = m.W.Augment(Numbers(m.W.Rows(), 1, w))
+	m.P, _ = m.P.Augment(Zeros(m.P.Rows(), 1))
+	if m.Observed != nil {
+		m.Observed, _ = m.Observed.Augment(Zeros(m.Observed.Rows(), 1))
+	}
+	m.Y, _ = m.Y.Augment(Zeros(m.Y.Rows(), 1))
+	if m.Norm != nil && m.Norm.ByItem {
+		m.Norm.grow()
+	}
+}
+
+// Returns a deep copy of the model that can be updated without affecting the original,
+// e.g. to apply updates to a model that is being served (copy-on-write).
+func (m *Model) Clone() *Model {
+	c := &Model{
+		X:              m.X.Copy(),
+		Y:              m.Y.Copy(),
+		W:              m.W.Copy(),
+		P:              m.P.Copy(),
+		Lambda:         m.Lambda,
+		WeightedLambda: m.WeightedLambda,
+		Implicit:       m.Implicit,
+		Iterations:     m.Iterations,
+		MinRating:      m.MinRating,
+		MaxRating:      m.MaxRating,
+		Clip:           m.Clip,
+		NonNegative:    m.NonNegative,
+		MaxNorm:        m.MaxNorm,
+		MissingValue:   m.MissingValue,
+		History: History{
+			Validated:  m.History.Validated,
+			Iterations: append([]IterationStats(nil), m.History.Iterations...),
+		},
+		// the index is never modified once built, only dropped
+		index: m.index,
+	}
+	if m.Observed != nil {
+		c.Observed = m.Observed.Copy()
+	}
+	c.meta = m.Metadata()
+	c.Norm = m.Norm.copy()
+	return c
+}
@@ -0,0 +1,169 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// How ratings are rescaled before explicit training. Predictions are mapped back to the original scale.
+type NormalizationMethod int
+
+const (
+	// train on the raw ratings
+	NoNormalization NormalizationMethod = iota
+	// subtract each user's (or product's) mean rating
+	MeanCentering
+	// subtract the mean and divide by the standard deviation
+	ZScore
+	// map each user's (or product's) ratings onto [0, 1]
+	MinMax
+)
+
+// Per user (or per product, when ByItem is set) offsets and scales of the ratings:
+// the model is trained on (rating - offset) / scale.
+type Normalizer struct {
+	Method  NormalizationMethod
+	ByItem  bool
+	Offsets []float64
+	Scales  []float64
+}
+
+// Normalize the ratings per user (or per product if byItem is set) before explicit training, so users
+// with different rating habits (a harsh 3 vs. a generous 5) become comparable. Ignored by TrainImplicit.
+func WithNormalization(method NormalizationMethod, byItem bool) Option {
+	return func(c *config) {
+		c.normalization = method
+		c.normalizeByItem = byItem
+	}
+}
+
+// Computes the offsets and scales of the rows (or columns) of Q, counting only the entries that
+// are nonzero in observed. Users/products without ratings get the global mean as offset and a scale of 1.
+func newNormalizer(Q, observed *DenseMatrix, method NormalizationMethod, byItem bool) *Normalizer {
+	if method == NoNormalization {
+		return nil
+	}
+	mat, mask := Q, observed
+	if byItem {
+		mat, mask = Q.Transpose(), observed.Transpose()
+	}
+	n := &Normalizer{
+		Method:  method,
+		ByItem:  byItem,
+		Offsets: make([]float64, mat.Rows()),
+		Scales:  make([]float64, mat.Rows()),
+	}
+
+	global, count := 0.0, 0.0
+	for r := 0; r < mat.Rows(); r++ {
+		for c, val := range mat.RowCopy(r) {
+			if mask.Get(r, c) != 0 {
+				global += val
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		global /= count
+	}
+
+	for r := 0; r < mat.Rows(); r++ {
+		sum, sumSq, num := 0.0, 0.0, 0.0
+		min, max := math.Inf(1), math.Inf(-1)
+		for c, val := range mat.RowCopy(r) {
+			if mask.Get(r, c) == 0 {
+				continue
+			}
+			sum += val
+			sumSq += val * val
+			num++
+			min = math.Min(min, val)
+			max = math.Max(max, val)
+		}
+		n.Offsets[r], n.Scales[r] = global, 1
+		if num == 0 {
+			continue
+		}
+		mean := sum / num
+		switch method {
+		case MeanCentering:
+			n.Offsets[r] = mean
+		case ZScore:
+			n.Offsets[r] = mean
+			if sd := math.Sqrt(sumSq/num - mean*mean); sd > 1e-12 {
+				n.Scales[r] = sd
+			}
+		case MinMax:
+			n.Offsets[r] = min
+			if max > min {
+				n.Scales[r] = max - min
+			}
+		}
+	}
+	return n
+}
+
+// offset and scale of a user/product pair. Pairs the normalizer has never seen are left as is.
+func (n *Normalizer) params(user, product int) (offset, scale float64) {
+	idx := user
+	if n.ByItem {
+		idx = product
+	}
+	if idx < 0 || idx >= len(n.Offsets) {
+		return 0, 1
+	}
+	return n.Offsets[idx], n.Scales[idx]
+}
+
+// maps a rating onto the normalized scale
+func (n *Normalizer) Normalize(user, product int, rating float64) float64 {
+	offset, scale := n.params(user, product)
+	return (rating - offset) / scale
+}
+
+// maps a prediction back onto the original rating scale
+func (n *Normalizer) Denormalize(user, product int, pred float64) float64 {
+	offset, scale := n.params(user, product)
+	return pred*scale + offset
+}
+
+// returns a normalized copy of the rating matrix at the entries that are nonzero in observed, 0 elsewhere.
+func (n *Normalizer) apply(Q, observed *DenseMatrix) *DenseMatrix {
+	P := Zeros(Q.Rows(), Q.Cols())
+	for u := 0; u < P.Rows(); u++ {
+		for i := 0; i < P.Cols(); i++ {
+			if observed.Get(u, i) != 0 {
+				P.Set(u, i, n.Normalize(u, i, Q.Get(u, i)))
+			}
+		}
+	}
+	return P
+}
+
+// adds the average offset and scale for a new user (or product)
+func (n *Normalizer) grow() {
+	offset, scale := 0.0, 0.0
+	for i := range n.Offsets {
+		offset += n.Offsets[i]
+		scale += n.Scales[i]
+	}
+	if len(n.Offsets) == 0 {
+		n.Offsets = append(n.Offsets, 0)
+		n.Scales = append(n.Scales, 1)
+		return
+	}
+	n.Offsets = append(n.Offsets, offset/float64(len(n.Offsets)))
+	n.Scales = append(n.Scales, scale/float64(len(n.Scales)))
+}
+
+// a deep copy of the normalizer, nil for nil, so models that share it can be updated separately
+func (n *Normalizer) copy() *Normalizer {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.Offsets = append([]float64(nil), n.Offsets...)
+	c.Scales = append([]float64(nil), n.Scales...)
+	return &c
+}
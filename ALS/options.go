@@ -0,0 +1,175 @@
+package ALS
+
+import (
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// seed used for the initial factors unless WithSeed or WithRand is given
+const defaultSeed = 47
+
+// Option configures optional behaviour of the ALS trainers.
+type Option func(*config)
+
+type config struct {
+	// number of conjugate gradient steps per factor vector. 0 uses the direct Cholesky solve.
+	cgSteps int
+	// number of goroutines solving factor vectors
+	parallelism int
+	// source of randomness for factor initialization
+	rng *rand.Rand
+	// caller supplied weight/confidence matrix, replacing the observed mask/confidences
+	weights *DenseMatrix
+	// save the model every checkpointEvery iterations
+	checkpointEvery int
+	checkpointFn    func(iteration int, m *Model) error
+	// partially trained model to continue from
+	resume *Model
+	// earlier model whose factors to start from
+	initial *Model
+	// called after every iteration with the training error
+	onIteration func(iter int, loss float64)
+	// held out ratings to stop early on, and how many iterations without improvement to allow
+	validation *DenseMatrix
+	patience   int
+	// where to stream the training history to, if anywhere
+	historyWriter io.Writer
+	historyFormat HistoryFormat
+	// rescaling of the ratings before explicit training
+	normalization   NormalizationMethod
+	normalizeByItem bool
+	// timestamps of the ratings, and how quickly their weight decays with age
+	timestamps *DenseMatrix
+	halfLife   float64
+	// scale lambda by each user's/product's number of ratings
+	weightedLambda bool
+	// start from a truncated SVD instead of random factors
+	svdInit bool
+	// clamp explicit predictions to the rating range
+	clip bool
+	// which entries of the rating matrix are observed: an explicit mask, or a sentinel value
+	observed     *DenseMatrix
+	missingValue float64
+	missingSet   bool
+	// held out entries of the validation set
+	validationMask *DenseMatrix
+	// steps applied to the prediction matrices returned by Train and TrainImplicit
+	postProcess []PostProcessor
+	// objective and negatives per observed entry of TrainSGD
+	loss      Loss
+	negatives int
+	// update rule and mini-batch size of TrainSGD
+	optimizer Optimizer
+	batchSize int
+	// constraints on the factor vectors, see WithNonNegative and WithMaxNorm
+	nonNegative bool
+	maxNorm     float64
+	// first error of the training run, see fail
+	err error
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{
+		rng:         rand.New(rand.NewSource(defaultSeed)),
+		parallelism: runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Solve each user/item factor vector with a few conjugate gradient steps (ALS-CG) instead of
+// an exact solve. Each step is warm started from the previous iteration's factors, so 2-3 steps
+// are usually enough, and the cost per user no longer grows with the square of n_factors.
+func WithConjugateGradient(steps int) Option {
+	return func(c *config) {
+		c.cgSteps = steps
+	}
+}
+
+// Number of goroutines solving the user and product factors of every ALS iteration. Defaults to
+// the number of CPUs; 1 solves everything on the calling goroutine. The results don't depend on it.
+func WithParallelism(n int) Option {
+	return func(c *config) {
+		c.parallelism = n
+	}
+}
+
+// Seed the random initialization of the factors. Training the same data with the same seed
+// and options always gives the same model.
+func WithSeed(seed int64) Option {
+	return func(c *config) {
+		c.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// Draw all random numbers used during training from r. Note that *rand.Rand is not safe
+// for concurrent use, so r should not be shared between trainers running in parallel.
+func WithRand(r *rand.Rand) Option {
+	return func(c *config) {
+		c.rng = r
+	}
+}
+
+// Calls fn after every ALS iteration with the number of completed iterations and the
+// weighted squared training error, e.g. to report progress to a metrics system.
+func OnIteration(fn func(iter int, loss float64)) Option {
+	return func(c *config) {
+		c.onIteration = fn
+	}
+}
+
+// Use weighted-lambda regularization as in the ALS-WR paper: the regularizer of every user and
+// product is lambda times its number of ratings (or interactions), instead of the same lambda for
+// all. Heavy raters are regularized more than users with a handful of ratings, which helps a lot on
+// skewed datasets. Smaller lambdas, around 0.05, work well with it. Kept by the model for Update.
+func WithWeightedLambda() Option {
+	return func(c *config) {
+		c.weightedLambda = true
+	}
+}
+
+// Clamp the predictions of an explicit model to the range of the ratings it was trained on
+// (e.g. 1 to 5), instead of returning raw reconstructed values that can fall outside of it.
+// Can also be switched on or off later through the model's Clip field.
+func WithClipping() Option {
+	return func(c *config) {
+		c.clip = true
+	}
+}
+
+// Train against a custom weight (explicit) or confidence (implicit) matrix instead of the
+// default 0/1 weights or 1 + 40*r confidences, e.g. to down-weight old or low intent events.
+// W must have the same shape as the rating matrix and contain no negative or NaN values.
+// Cells with a positive weight are fit even if unrated, so keep them 0 in the explicit case.
+func WithWeights(W *DenseMatrix) Option {
+	return func(c *config) {
+		c.weights = W
+	}
+}
+
+// Returns the weight matrix to train Q with: the custom weights if they are valid,
+// otherwise the given default.
+func (c *config) weightsFor(Q, defaults *DenseMatrix) *DenseMatrix {
+	if c.weights == nil {
+		return defaults
+	}
+	if c.weights.Rows() != Q.Rows() || c.weights.Cols() != Q.Cols() {
+		c.fail(wrap(ErrDimensionMismatch, "Weight matrix needs to be the same dimension as the rating matrix"))
+		return defaults
+	}
+	for i := 0; i < Q.Rows(); i++ {
+		for j := 0; j < Q.Cols(); j++ {
+			if w := c.weights.Get(i, j); w < 0 || math.IsNaN(w) {
+				c.fail(wrap(ErrInvalidArgument, "Weight matrix can only contain non-negative values"))
+				return defaults
+			}
+		}
+	}
+	return c.weights.Copy()
+}
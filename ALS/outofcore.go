@@ -0,0 +1,420 @@
+package ALS
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Rating files hold the ratings twice, grouped by user and grouped by product, so that both halves
+// of an ALS iteration read contiguous blocks. All numbers are little endian:
+//
+//	header: "ALSR", users (uint32), products (uint32), ratings (uint64)
+//	per user: offsets into the user block (users+1 uint64), products (uint32), ratings (float64)
+//	per product: offsets into the product block (products+1 uint64), users (uint32), ratings (float64)
+const (
+	ratingFileMagic  = "ALSR"
+	ratingFileHeader = 20
+)
+
+// byte offsets of the sections of a rating file
+type ratingLayout struct {
+	users, products, n                   int
+	userOffsets, userIndices, userValues int
+	itemOffsets, itemIndices, itemValues int
+	size                                 int
+}
+
+func newRatingLayout(users, products, n int) ratingLayout {
+	l := ratingLayout{users: users, products: products, n: n}
+	l.userOffsets = ratingFileHeader
+	l.userIndices = l.userOffsets + 8*(users+1)
+	l.userValues = l.userIndices + 4*n
+	l.itemOffsets = l.userValues + 8*n
+	l.itemIndices = l.itemOffsets + 8*(products+1)
+	l.itemValues = l.itemIndices + 4*n
+	l.size = l.itemValues + 8*n
+	return l
+}
+
+// Writes the ratings produced by each to a rating file at path, for training with TrainOutOfCore.
+// each is called twice and must emit the same ratings both times, e.g. by reading a CSV file from
+// the start; only per user/product counts are kept in memory, never the ratings themselves.
+// Ratings of 0 or NaN are skipped, and every user/product pair should be emitted at most once.
+func WriteRatingFile(path string, users, products int, each func(emit func(user, product int, rating float64)) error) error {
+	userCounts := make([]uint64, users+1)
+	itemCounts := make([]uint64, products+1)
+	n := 0
+	var bad error
+	err := each(func(user, product int, rating float64) {
+		if rating == 0 || math.IsNaN(rating) {
+			return
+		}
+		if user < 0 || user >= users || product < 0 || product >= products {
+			bad = wrap(ErrInvalidArgument, "User/Product index out of range")
+			return
+		}
+		userCounts[user+1]++
+		itemCounts[product+1]++
+		n++
+	})
+	if err != nil {
+		return err
+	}
+	if bad != nil {
+		return bad
+	}
+	// running sums give each user's/product's first slot
+	for u := 1; u <= users; u++ {
+		userCounts[u] += userCounts[u-1]
+	}
+	for i := 1; i <= products; i++ {
+		itemCounts[i] += itemCounts[i-1]
+	}
+
+	l := newRatingLayout(users, products, n)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(int64(l.size)); err != nil {
+		return err
+	}
+	m, err := mapFile(f, l.size, true)
+	if err != nil {
+		return err
+	}
+	data := m.data
+	copy(data, ratingFileMagic)
+	le := binary.LittleEndian
+	le.PutUint32(data[4:], uint32(users))
+	le.PutUint32(data[8:], uint32(products))
+	le.PutUint64(data[12:], uint64(n))
+	for u, offset := range userCounts {
+		le.PutUint64(data[l.userOffsets+8*u:], offset)
+	}
+	for i, offset := range itemCounts {
+		le.PutUint64(data[l.itemOffsets+8*i:], offset)
+	}
+
+	// copies of the offsets become the next free slot of every user/product
+	userNext := append([]uint64(nil), userCounts[:users]...)
+	itemNext := append([]uint64(nil), itemCounts[:products]...)
+	err = each(func(user, product int, rating float64) {
+		if rating == 0 || math.IsNaN(rating) || user < 0 || user >= users || product < 0 || product >= products {
+			return
+		}
+		if userNext[user] == userCounts[user+1] || itemNext[product] == itemCounts[product+1] {
+			bad = errors.New("Ratings changed between the two passes")
+			return
+		}
+		slot := int(userNext[user])
+		le.PutUint32(data[l.userIndices+4*slot:], uint32(product))
+		le.PutUint64(data[l.userValues+8*slot:], math.Float64bits(rating))
+		userNext[user]++
+		slot = int(itemNext[product])
+		le.PutUint32(data[l.itemIndices+4*slot:], uint32(user))
+		le.PutUint64(data[l.itemValues+8*slot:], math.Float64bits(rating))
+		itemNext[product]++
+	})
+	// fewer ratings the second time leave slots unwritten
+	for u := 0; u < users && bad == nil; u++ {
+		if userNext[u] != userCounts[u+1] {
+			bad = errors.New("Ratings changed between the two passes")
+		}
+	}
+	if cerr := m.close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = bad
+	}
+	return err
+}
+
+// A rating file mapped into memory. Only the parts being read are paged in, so it can be far
+// larger than the available RAM.
+type RatingFile struct {
+	file    *os.File
+	mapping *mapping
+	layout  ratingLayout
+}
+
+// Opens a rating file written by WriteRatingFile.
+func OpenRatingFile(path string) (*RatingFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	m, err := mapFile(f, int(info.Size()), false)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	data := m.data
+	if len(data) < ratingFileHeader || string(data[:4]) != ratingFileMagic {
+		m.close()
+		f.Close()
+		return nil, errors.New("Not a rating file")
+	}
+	le := binary.LittleEndian
+	l := newRatingLayout(int(le.Uint32(data[4:])), int(le.Uint32(data[8:])), int(le.Uint64(data[12:])))
+	if l.size != len(data) {
+		m.close()
+		f.Close()
+		return nil, errors.New("Rating file is truncated")
+	}
+	return &RatingFile{file: f, mapping: m, layout: l}, nil
+}
+
+// Number of users.
+func (r *RatingFile) Users() int {
+	return r.layout.users
+}
+
+// Number of products.
+func (r *RatingFile) Products() int {
+	return r.layout.products
+}
+
+// Number of ratings.
+func (r *RatingFile) Len() int {
+	return r.layout.n
+}
+
+// calls fn with every entry of row which of the block starting at the given offsets
+func (r *RatingFile) block(offsets, indices, values, which int, fn func(index int, rating float64)) {
+	le := binary.LittleEndian
+	data := r.mapping.data
+	start := int(le.Uint64(data[offsets+8*which:]))
+	end := int(le.Uint64(data[offsets+8*which+8:]))
+	for slot := start; slot < end; slot++ {
+		fn(int(le.Uint32(data[indices+4*slot:])), math.Float64frombits(le.Uint64(data[values+8*slot:])))
+	}
+}
+
+// Calls fn with every product the user rated and its rating. Fails with ErrInvalidArgument if
+// the user is out of range.
+func (r *RatingFile) EachUserRating(user int, fn func(product int, rating float64)) error {
+	if user < 0 || user >= r.Users() {
+		return wrap(ErrInvalidArgument, "User index out of range")
+	}
+	r.eachUser(user, fn)
+	return nil
+}
+
+// Calls fn with every user who rated the product and their rating. Fails with
+// ErrInvalidArgument if the product is out of range.
+func (r *RatingFile) EachProductRating(product int, fn func(user int, rating float64)) error {
+	if product < 0 || product >= r.Products() {
+		return wrap(ErrInvalidArgument, "Product index out of range")
+	}
+	r.eachProduct(product, fn)
+	return nil
+}
+
+// EachUserRating without the bounds check
+func (r *RatingFile) eachUser(user int, fn func(product int, rating float64)) {
+	l := r.layout
+	r.block(l.userOffsets, l.userIndices, l.userValues, user, fn)
+}
+
+// EachProductRating without the bounds check
+func (r *RatingFile) eachProduct(product int, fn func(user int, rating float64)) {
+	l := r.layout
+	r.block(l.itemOffsets, l.itemIndices, l.itemValues, product, fn)
+}
+
+// Unmaps and closes the file.
+func (r *RatingFile) Close() error {
+	err := r.mapping.close()
+	if ferr := r.file.Close(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// Params: a rating file, number of factors, iterations, and lambda value for ALS.
+// Trains explicit ALS like TrainModel, but streams the ratings from the file one user (and then
+// one product) at a time, so only the factors need to fit in memory while training. Of the
+// options, WithSeed, WithRand, WithWeightedLambda and OnIteration apply.
+// Returns the trained model. Like every Model it holds the known ratings as dense users x products
+// weights and targets, read from the file once training is done, so Predict, TopN and Save work.
+func TrainOutOfCore(r *RatingFile, n_factors, iterations int, lambda float64, opts ...Option) (*Model, error) {
+	return trainOutOfCoreModel(r, n_factors, iterations, lambda, false, opts)
+}
+
+// Like TrainOutOfCore, but trains implicit ALS like TrainImplicitModel.
+func TrainImplicitOutOfCore(r *RatingFile, n_factors, iterations int, lambda float64, opts ...Option) (*Model, error) {
+	return trainOutOfCoreModel(r, n_factors, iterations, lambda, true, opts)
+}
+
+// trains the factors and wraps them in a model with the ratings of the file as its known ones
+func trainOutOfCoreModel(r *RatingFile, n_factors, iterations int, lambda float64, implicit bool, opts []Option) (*Model, error) {
+	X, Y, err := trainOutOfCore(r, n_factors, iterations, lambda, implicit, opts)
+	if err != nil {
+		return nil, err
+	}
+	Q := Zeros(r.Users(), r.Products())
+	for u := 0; u < r.Users(); u++ {
+		r.eachUser(u, func(i int, rating float64) { Q.Set(u, i, rating) })
+	}
+	model, err := ModelFromFactors(X, Y.Transpose(), Q, lambda, implicit)
+	if err != nil {
+		return nil, err
+	}
+	cfg := newConfig(opts)
+	model.WeightedLambda, model.Iterations = cfg.weightedLambda, iterations
+	model.NonNegative, model.MaxNorm = cfg.nonNegative, cfg.maxNorm
+	algorithm := AlgorithmALS
+	if implicit {
+		algorithm = AlgorithmImplicitALS
+	}
+	model.trained(algorithm, Q, n_factors, iterations, cfg)
+	return model, nil
+}
+
+func trainOutOfCore(r *RatingFile, n_factors, iterations int, lambda float64, implicit bool, opts []Option) (X, Y *DenseMatrix, err error) {
+	cfg := newConfig(opts)
+	max_rating := 5.0
+	if !implicit {
+		max_rating = 0
+		for u := 0; u < r.Users(); u++ {
+			r.eachUser(u, func(_ int, rating float64) {
+				max_rating = math.Max(max_rating, rating)
+			})
+		}
+	}
+	X, Y = cfg.initialFactors(r.Users(), r.Products(), n_factors, max_rating)
+	if cfg.err != nil {
+		return nil, nil, cfg.err
+	}
+	Xr, Yt := X.Arrays(), Y.Transpose().Arrays()
+
+	for iter := 1; iter <= iterations; iter++ {
+		var base *DenseMatrix
+		if implicit {
+			base = gram(Yt)
+		}
+		for u := range Xr {
+			if Xr[u], err = solveSparse(Yt, base, r.eachUser, u, lambda, implicit, cfg); err != nil {
+				return nil, nil, err
+			}
+		}
+		if implicit {
+			base = gram(Xr)
+		}
+		for i := range Yt {
+			if Yt[i], err = solveSparse(Xr, base, r.eachProduct, i, lambda, implicit, cfg); err != nil {
+				return nil, nil, err
+			}
+		}
+		if cfg.onIteration != nil {
+			cfg.onIteration(iter, sparseError(r, Xr, Yt, implicit))
+		}
+	}
+	X = MakeDenseMatrixStacked(Xr)
+	Y = MakeDenseMatrixStacked(Yt).Transpose()
+	return X, Y, nil
+}
+
+// returns F^T * F
+func gram(F [][]float64) *DenseMatrix {
+	k := len(F[0])
+	G := Zeros(k, k)
+	for _, f := range F {
+		for a := 0; a < k; a++ {
+			for b := 0; b < k; b++ {
+				G.Set(a, b, G.Get(a, b)+f[a]*f[b])
+			}
+		}
+	}
+	return G
+}
+
+// Solves for the factors of one user (or product) from its ratings alone. Explicit:
+// A = sum y y^T + lambda I and b = sum r y over the rated entries. Implicit, with c = 1 + 40r, uses
+// the trick of Hu et al.: A = Y^T Y + sum (c - 1) y y^T + lambda I and b = sum c y, where
+// base = Y^T Y is shared by all users, so the unrated entries never have to be visited.
+func solveSparse(F [][]float64, base *DenseMatrix, each func(int, func(int, float64)), which int, lambda float64, implicit bool, cfg *config) ([]float64, error) {
+	k := len(F[0])
+	A := Zeros(k, k)
+	if base != nil {
+		A = base.Copy()
+	}
+	b := make([]float64, k)
+	n := 0
+	each(which, func(index int, rating float64) {
+		f := F[index]
+		// weight on top of the base, and the weighted target
+		extra, target := 1.0, rating
+		if implicit {
+			extra, target = 40*rating, 1+40*rating
+		}
+		for a := 0; a < k; a++ {
+			b[a] += target * f[a]
+			for c := 0; c < k; c++ {
+				A.Set(a, c, A.Get(a, c)+extra*f[a]*f[c])
+			}
+		}
+		n++
+	})
+	reg := lambda
+	if cfg.weightedLambda {
+		reg = lambda * math.Max(float64(n), 1)
+	}
+	for a := 0; a < k; a++ {
+		A.Set(a, a, A.Get(a, a)+reg)
+	}
+	chol, err := factorCholesky(A)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.constrain(chol.solve(b)), nil
+}
+
+// the squared weighted residuals, as in getErrorInline, computed without visiting unrated entries
+func sparseError(r *RatingFile, Xr, Yt [][]float64, implicit bool) float64 {
+	sum := 0.0
+	var G *DenseMatrix
+	if implicit {
+		G = gram(Yt)
+	}
+	for u, x := range Xr {
+		sum += rowError(x, Yt, G, func(fn func(int, float64)) { r.eachUser(u, fn) }, implicit)
+	}
+	return sum
+}
+
+// one user's share of sparseError, each visiting the user's ratings; G is the gram matrix of Yt
+// for implicit ratings
+func rowError(x []float64, Yt [][]float64, G *DenseMatrix, each func(func(int, float64)), implicit bool) float64 {
+	sum := 0.0
+	if implicit {
+		// every entry has a target of 0 and weight 1, corrected below for the rated ones
+		for a := range x {
+			for c := range x {
+				sum += x[a] * G.Get(a, c) * x[c]
+			}
+		}
+	}
+	each(func(i int, rating float64) {
+		pred := dot(x, Yt[i])
+		if implicit {
+			c := 1 + 40*rating
+			sum += c*c*(1-pred)*(1-pred) - pred*pred
+		} else {
+			sum += (rating - pred) * (rating - pred)
+		}
+	})
+	return sum
+}
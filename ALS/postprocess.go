@@ -0,0 +1,91 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// A post-processing step applied to one user's row of predictions, in place, after they are
+// mapped back onto the rating scale. Steps run in the order given, so they can be chained.
+type PostProcessor func(user int, scores []float64)
+
+// Rescales each user's predictions onto [0, 1]. Unlike rescaling the whole matrix at once, a user
+// whose predictions are all low still gets a full range, and the order within a row is kept.
+// Rows where all predictions are equal become all 0.
+func RowMinMax() PostProcessor {
+	return func(user int, scores []float64) {
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, s := range scores {
+			min, max = math.Min(min, s), math.Max(max, s)
+		}
+		for i, s := range scores {
+			if max > min {
+				scores[i] = (s - min) / (max - min)
+			} else {
+				scores[i] = 0
+			}
+		}
+	}
+}
+
+// Standardizes each user's predictions to mean 0 and standard deviation 1.
+func RowZScore() PostProcessor {
+	return func(user int, scores []float64) {
+		if len(scores) == 0 {
+			return
+		}
+		mean, sq := 0.0, 0.0
+		for _, s := range scores {
+			mean += s
+			sq += s * s
+		}
+		n := float64(len(scores))
+		mean /= n
+		sd := math.Sqrt(math.Max(sq/n-mean*mean, 0))
+		for i, s := range scores {
+			if sd > 1e-12 {
+				scores[i] = (s - mean) / sd
+			} else {
+				scores[i] = 0
+			}
+		}
+	}
+}
+
+// Clamps the predictions to [min, max].
+func Clamp(min, max float64) PostProcessor {
+	return func(user int, scores []float64) {
+		for i, s := range scores {
+			scores[i] = math.Max(min, math.Min(max, s))
+		}
+	}
+}
+
+// Post-process the prediction matrix returned by Train and TrainImplicit with the given steps.
+func WithPostProcessing(steps ...PostProcessor) Option {
+	return func(c *config) {
+		c.postProcess = append(c.postProcess, steps...)
+	}
+}
+
+// Returns the full user/product prediction matrix like Predictions, with every row passed
+// through the post-processing steps.
+func (m *Model) PredictionsWith(steps ...PostProcessor) *DenseMatrix {
+	return postProcess(m.Predictions(), steps)
+}
+
+// applies the steps to every row of Qhat, in place
+func postProcess(Qhat *DenseMatrix, steps []PostProcessor) *DenseMatrix {
+	if len(steps) == 0 {
+		return Qhat
+	}
+	for u := 0; u < Qhat.Rows(); u++ {
+		row := Qhat.RowCopy(u)
+		for _, step := range steps {
+			step(u, row)
+		}
+		Qhat = setRow(Qhat, u, row)
+	}
+	return Qhat
+}
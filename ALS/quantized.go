@@ -0,0 +1,194 @@
+package ALS
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"math"
+)
+
+// A read only copy of a trained model with 8-bit factors, for serving in less memory than a
+// Model32. Every factor vector v is stored as bytes q with its own scale and offset,
+// v[f] ~ Offset + Scale * q[f], so the rounding error of a vector is at most half its range / 255.
+// A vector of k factors takes k + 8 bytes (the bytes plus a float32 scale and offset) instead of
+// the 4k of a Model32, and 8 more in memory for the cached sum of its bytes.
+// Scores are computed from the bytes with an integer dot product, which ranks nearly the same as
+// the full model. X and Y hold the quantized user and product factors, row-major with one row
+// per user/product, and Rated the sorted products each user rated.
+type Model8 struct {
+	Users, Products, Factors int
+	X, Y                     []uint8
+	XScale, XOffset          []float32
+	YScale, YOffset          []float32
+	Rated                    [][]int32
+	Norm                     *Normalizer
+	MinRating, MaxRating     float64
+	Clip                     bool
+
+	// sums of the bytes of every user/product vector
+	xSums, ySums []float64
+}
+
+// Returns an 8-bit copy of the model for serving. Updates to m are not reflected in it.
+func (m *Model) Quantize() *Model8 {
+	compact := m.Float32()
+	q := &Model8{
+		Users:     compact.Users,
+		Products:  compact.Products,
+		Factors:   compact.Factors,
+		Rated:     compact.Rated,
+		Norm:      m.Norm,
+		MinRating: m.MinRating,
+		MaxRating: m.MaxRating,
+		Clip:      m.Clip,
+	}
+	q.X, q.XScale, q.XOffset = quantize(compact.X, q.Factors)
+	q.Y, q.YScale, q.YOffset = quantize(compact.Y, q.Factors)
+	q.sums()
+	return q
+}
+
+// quantizes every vector of length k of the row-major values to bytes, with its scale and offset
+func quantize(values []float32, k int) (q []uint8, scales, offsets []float32) {
+	n := 0
+	if k > 0 {
+		n = len(values) / k
+	}
+	q = make([]uint8, len(values))
+	scales, offsets = make([]float32, n), make([]float32, n)
+	for r := 0; r < n; r++ {
+		v := values[r*k : (r+1)*k]
+		min, max := v[0], v[0]
+		for _, x := range v {
+			min, max = float32(math.Min(float64(min), float64(x))), float32(math.Max(float64(max), float64(x)))
+		}
+		offsets[r] = min
+		if max > min {
+			scales[r] = (max - min) / 255
+			for f, x := range v {
+				q[r*k+f] = uint8(math.Round(float64((x - min) / scales[r])))
+			}
+		}
+	}
+	return q, scales, offsets
+}
+
+// computes the byte sums of the vectors
+func (m *Model8) sums() {
+	m.xSums, m.ySums = make([]float64, m.Users), make([]float64, m.Products)
+	for u := range m.xSums {
+		for _, b := range m.user(u) {
+			m.xSums[u] += float64(b)
+		}
+	}
+	for i := range m.ySums {
+		for _, b := range m.product(i) {
+			m.ySums[i] += float64(b)
+		}
+	}
+}
+
+func (m *Model8) user(u int) []uint8 {
+	return m.X[u*m.Factors : (u+1)*m.Factors]
+}
+
+func (m *Model8) product(i int) []uint8 {
+	return m.Y[i*m.Factors : (i+1)*m.Factors]
+}
+
+func dot8(a, b []uint8) uint32 {
+	var sum uint32
+	for f := range a {
+		sum += uint32(a[f]) * uint32(b[f])
+	}
+	return sum
+}
+
+// The dot product of the de-quantized vectors (ox + sx*qx) . (oy + sy*qy), expanded so only the
+// byte dot product depends on both.
+func (m *Model8) score(user, product int) float64 {
+	sx, ox := float64(m.XScale[user]), float64(m.XOffset[user])
+	sy, oy := float64(m.YScale[product]), float64(m.YOffset[product])
+	pred := float64(m.Factors)*ox*oy + ox*sy*m.ySums[product] + oy*sx*m.xSums[user] +
+		sx*sy*float64(dot8(m.user(user), m.product(product)))
+	if m.Norm != nil {
+		pred = m.Norm.Denormalize(user, product, pred)
+	}
+	if m.Clip {
+		pred = math.Max(m.MinRating, math.Min(m.MaxRating, pred))
+	}
+	return pred
+}
+
+// Returns the predicted value for a given user-product pair. Error if out of range.
+func (m *Model8) Predict(user, product int) (float64, error) {
+	if user < 0 || user >= m.Users || product < 0 || product >= m.Products {
+		return 0.0, wrap(ErrInvalidArgument, "User/Product index out of range")
+	}
+	return m.score(user, product), nil
+}
+
+// Returns the n products with the highest predicted values for the user that they haven't rated,
+// in descending order along with their predictions, like Model.TopN.
+func (m *Model8) TopN(user, n int) ([]int, []float64, error) {
+	if user < 0 || user >= m.Users {
+		return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	products := make([]int, 0, m.Products)
+	rated := m.Rated[user]
+	for i := 0; i < m.Products; i++ {
+		if len(rated) > 0 && int(rated[0]) == i {
+			rated = rated[1:]
+			continue
+		}
+		products = append(products, i)
+	}
+	ids, scores := rank(products, n, func(i int) float64 {
+		return m.score(user, i)
+	})
+	return ids, scores, nil
+}
+
+// Returns a float32 model with the de-quantized factors, for exact scoring of the quantized values,
+// e.g. to check the ranking of the integer dot products.
+func (m *Model8) Dequantize() *Model32 {
+	return &Model32{
+		Users:     m.Users,
+		Products:  m.Products,
+		Factors:   m.Factors,
+		X:         dequantize(m.X, m.XScale, m.XOffset, m.Factors),
+		Y:         dequantize(m.Y, m.YScale, m.YOffset, m.Factors),
+		Rated:     m.Rated,
+		Norm:      m.Norm,
+		MinRating: m.MinRating,
+		MaxRating: m.MaxRating,
+		Clip:      m.Clip,
+	}
+}
+
+func dequantize(q []uint8, scales, offsets []float32, k int) []float32 {
+	values := make([]float32, len(q))
+	for n, b := range q {
+		values[n] = offsets[n/k] + scales[n/k]*float32(b)
+	}
+	return values
+}
+
+// Writes the 8-bit model to w. Read it back with LoadModel8.
+func (m *Model8) Save(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(m)
+}
+
+// Reads an 8-bit model written by Model8.Save.
+func LoadModel8(r io.Reader) (*Model8, error) {
+	m := &Model8{}
+	if err := gob.NewDecoder(r).Decode(m); err != nil {
+		return nil, err
+	}
+	if len(m.X) != m.Users*m.Factors || len(m.Y) != m.Products*m.Factors || len(m.Rated) != m.Users ||
+		len(m.XScale) != m.Users || len(m.XOffset) != m.Users || len(m.YScale) != m.Products || len(m.YOffset) != m.Products {
+		return nil, errors.New("Saved model has inconsistent dimensions")
+	}
+	m.sums()
+	return m, nil
+}
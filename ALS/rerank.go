@@ -0,0 +1,86 @@
+package ALS
+
+import (
+	"errors"
+	"math"
+
+	"github.com/timkaye11/goRecommend/collabFilter"
+)
+
+// Re-ranks a candidate list (e.g. from TopN) with Maximal Marginal Relevance, picking n products
+// that are relevant but not too similar to each other. Each pick maximizes
+// lambda * relevance - (1 - lambda) * (largest cosine similarity to an already picked product),
+// where the relevance is the candidate's score rescaled to [0, 1] so it is comparable with the
+// similarities. lambda = 1 keeps the original order, lambda = 0 only cares about diversity.
+// Returns the picked products in order along with their original scores. Fails with
+// ErrInvalidArgument for a negative n or a NaN or infinite score.
+func (m *Model) RerankMMR(ids []int, scores []float64, n int, lambda float64) ([]int, []float64, error) {
+	if len(ids) != len(scores) {
+		return nil, nil, errors.New("Need a score for every candidate product")
+	}
+	if !(lambda >= 0 && lambda <= 1) {
+		return nil, nil, errors.New("MMR lambda needs to be between 0 and 1")
+	}
+	if n < 0 {
+		return nil, nil, wrap(ErrInvalidArgument, "Number of products to pick must not be negative")
+	}
+	for _, s := range scores {
+		if math.IsNaN(s) || math.IsInf(s, 0) {
+			return nil, nil, wrap(ErrInvalidArgument, "Candidate scores must be finite")
+		}
+	}
+	factors := make([][]float64, len(ids))
+	for c, i := range ids {
+		if i < 0 || i >= m.Y.Cols() {
+			return nil, nil, wrap(ErrInvalidArgument, "Product index out of range")
+		}
+		factors[c] = m.Y.ColCopy(i)
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range scores {
+		lo, hi = math.Min(lo, s), math.Max(hi, s)
+	}
+	relevance := make([]float64, len(scores))
+	for c, s := range scores {
+		if hi > lo {
+			relevance[c] = (s - lo) / (hi - lo)
+		} else {
+			relevance[c] = 1
+		}
+	}
+
+	if n > len(ids) {
+		n = len(ids)
+	}
+	// largest similarity of each candidate to the picked products so far
+	maxSim := make([]float64, len(ids))
+	for c := range maxSim {
+		maxSim[c] = math.Inf(-1)
+	}
+	picked := make([]bool, len(ids))
+	top, topScores := make([]int, 0, n), make([]float64, 0, n)
+	for len(top) < n {
+		best, bestValue := -1, math.Inf(-1)
+		for c := range ids {
+			if picked[c] {
+				continue
+			}
+			value := lambda * relevance[c]
+			if len(top) > 0 {
+				value -= (1 - lambda) * maxSim[c]
+			}
+			if value > bestValue {
+				best, bestValue = c, value
+			}
+		}
+		picked[best] = true
+		top, topScores = append(top, ids[best]), append(topScores, scores[best])
+		for c := range ids {
+			if !picked[c] {
+				maxSim[c] = math.Max(maxSim[c], collabFilter.CosineSim(factors[c], factors[best]))
+			}
+		}
+	}
+	return top, topScores, nil
+}
@@ -0,0 +1,295 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/sampling"
+)
+
+// Objective minimized by TrainSGD, per (user, product) entry. target is the rating for observed
+// entries and 0 for sampled negatives; prediction is the dot product of the factors.
+// Implement it for custom objectives.
+type Loss interface {
+	Loss(prediction, target float64) float64
+	// derivative of Loss with respect to the prediction
+	Gradient(prediction, target float64) float64
+}
+
+// Half the squared error, for predicting ratings.
+type SquaredLoss struct{}
+
+func (SquaredLoss) Loss(prediction, target float64) float64 {
+	return (prediction - target) * (prediction - target) / 2
+}
+
+func (SquaredLoss) Gradient(prediction, target float64) float64 {
+	return prediction - target
+}
+
+// Log loss for binary interaction prediction: a positive target is an interaction, 0 none.
+// Predictions are log-odds.
+type LogisticLoss struct{}
+
+// +1 for interactions, -1 otherwise
+func label(target float64) float64 {
+	if target > 0 {
+		return 1
+	}
+	return -1
+}
+
+func (LogisticLoss) Loss(prediction, target float64) float64 {
+	return math.Log1p(math.Exp(-label(target) * prediction))
+}
+
+func (LogisticLoss) Gradient(prediction, target float64) float64 {
+	y := label(target)
+	return -y / (1 + math.Exp(y*prediction))
+}
+
+// Hinge loss for binary interaction prediction, as in a linear SVM: only predictions on the wrong
+// side of a margin of 1 are penalized.
+type HingeLoss struct{}
+
+func (HingeLoss) Loss(prediction, target float64) float64 {
+	return math.Max(0, 1-label(target)*prediction)
+}
+
+func (HingeLoss) Gradient(prediction, target float64) float64 {
+	y := label(target)
+	if y*prediction < 1 {
+		return -y
+	}
+	return 0
+}
+
+// Objective of TrainSGD. Defaults to SquaredLoss.
+func WithLoss(loss Loss) Option {
+	return func(c *config) {
+		c.loss = loss
+	}
+}
+
+// Fit n products the user never interacted with, drawn uniformly, as negatives with target 0
+// alongside every observed entry in each TrainSGD epoch. Binary losses need negatives to learn anything.
+// For TrainWARP, n is the most negatives drawn per interaction.
+func WithNegativeSamples(n int) Option {
+	return func(c *config) {
+		c.negatives = n
+	}
+}
+
+// How TrainSGD turns gradients into updates of the factors.
+type Optimizer int
+
+const (
+	// the same learning rate for every parameter, throughout training
+	PlainSGD Optimizer = iota
+	// AdaGrad: each parameter's rate is divided by the root of the sum of its squared gradients,
+	// so frequently updated factors (popular products, heavy users) take smaller steps
+	AdaGrad
+	// Adam: steps follow running averages of each parameter's gradient and squared gradient,
+	// which smooths out noisy mini-batches. Use much smaller rates than for PlainSGD, e.g. 0.01.
+	Adam
+)
+
+// Adam's decay rates of the running averages, and the term keeping the adaptive rates finite
+const (
+	adamBeta1       = 0.9
+	adamBeta2       = 0.999
+	adaptiveEpsilon = 1e-8
+)
+
+// Update rule of TrainSGD. Defaults to PlainSGD.
+func WithOptimizer(o Optimizer) Option {
+	return func(c *config) {
+		c.optimizer = o
+	}
+}
+
+// Average the gradients of n entries (observed ones and sampled negatives alike) into one update,
+// instead of updating after every entry. Larger batches give smoother, more stable updates.
+// Defaults to 1.
+func WithBatchSize(n int) Option {
+	return func(c *config) {
+		c.batchSize = n
+	}
+}
+
+// per parameter state of the adaptive optimizers, one row per factor vector
+type optimizerState struct {
+	moment, squared [][]float64
+}
+
+func newOptimizerState(rows, k int) *optimizerState {
+	s := &optimizerState{moment: make([][]float64, rows), squared: make([][]float64, rows)}
+	for r := range s.moment {
+		s.moment[r], s.squared[r] = make([]float64, k), make([]float64, k)
+	}
+	return s
+}
+
+// Applies the gradient g of factor vector r to x. t counts the updates so far, starting at 1.
+func (o Optimizer) apply(x, g []float64, s *optimizerState, r int, rate float64, t int) {
+	for f := range x {
+		switch o {
+		case AdaGrad:
+			s.squared[r][f] += g[f] * g[f]
+			x[f] -= rate * g[f] / (math.Sqrt(s.squared[r][f]) + adaptiveEpsilon)
+		case Adam:
+			s.moment[r][f] = adamBeta1*s.moment[r][f] + (1-adamBeta1)*g[f]
+			s.squared[r][f] = adamBeta2*s.squared[r][f] + (1-adamBeta2)*g[f]*g[f]
+			m := s.moment[r][f] / (1 - math.Pow(adamBeta1, float64(t)))
+			v := s.squared[r][f] / (1 - math.Pow(adamBeta2, float64(t)))
+			x[f] -= rate * m / (math.Sqrt(v) + adaptiveEpsilon)
+		default:
+			x[f] -= rate * g[f]
+		}
+	}
+}
+
+// scale of the random initial factors of the gradient and coordinate descent trainers
+const smallInitScale = 0.1
+
+// Params: the user/product matrix, number of factors, number of passes over the ratings, lambda, and
+// learning rate. Factorizes Q with stochastic gradient descent on the loss chosen with WithLoss
+// (squared error by default), visiting the rated entries of Q in random order every epoch. With
+// LogisticLoss or HingeLoss and WithNegativeSamples, the same code learns binary interaction
+// prediction instead of ratings. WithBatchSize and WithOptimizer switch to mini-batches and AdaGrad or
+// Adam adaptive learning rates. Returns the trained model and its mean loss over the rated entries.
+// Fails with ErrDimensionMismatch or ErrInvalidArgument for bad options.
+func TrainSGD(Q *DenseMatrix, n_factors, epochs int, lambda, rate float64, opts ...Option) (*Model, float64, error) {
+	cfg := newConfig(opts)
+	loss := cfg.loss
+	if loss == nil {
+		loss = SquaredLoss{}
+	}
+	mask := cfg.observedMask(Q)
+	model := &Model{
+		W:            mask,
+		P:            maskedTargets(Q, mask),
+		Lambda:       lambda,
+		NonNegative:  cfg.nonNegative,
+		MaxNorm:      cfg.maxNorm,
+		MissingValue: cfg.missing(),
+	}
+	model.MinRating, model.MaxRating = ratingRange(Q, mask)
+	model.X, model.Y = cfg.initialFactors(Q.Rows(), Q.Cols(), n_factors, smallInitScale)
+	if cfg.err != nil {
+		return nil, 0, cfg.err
+	}
+	users, products := model.X.Arrays(), model.Y.Transpose().Arrays()
+
+	observed := make([][2]int, 0)
+	for u := 0; u < Q.Rows(); u++ {
+		for i := 0; i < Q.Cols(); i++ {
+			if model.W.Get(u, i) != 0 {
+				observed = append(observed, [2]int{u, i})
+			}
+		}
+	}
+	batch := cfg.batchSize
+	if batch < 1 {
+		batch = 1
+	}
+	var userState, productState *optimizerState
+	if cfg.optimizer != PlainSGD {
+		userState, productState = newOptimizerState(len(users), n_factors), newOptimizerState(len(products), n_factors)
+	}
+	// gradients of the current batch, by user and product, computed against the factors as of the last update
+	userGrads, productGrads := make(map[int][]float64), make(map[int][]float64)
+	pending, updates := 0, 0
+	accumulate := func(grads map[int][]float64, r int, g []float64) {
+		if sum, ok := grads[r]; ok {
+			for f := range sum {
+				sum[f] += g[f]
+			}
+			return
+		}
+		grads[r] = g
+	}
+	flush := func() {
+		if pending == 0 {
+			return
+		}
+		updates++
+		for r, g := range userGrads {
+			for f := range g {
+				g[f] /= float64(pending)
+			}
+			cfg.optimizer.apply(users[r], g, userState, r, rate, updates)
+			cfg.constrain(users[r])
+		}
+		for r, g := range productGrads {
+			for f := range g {
+				g[f] /= float64(pending)
+			}
+			cfg.optimizer.apply(products[r], g, productState, r, rate, updates)
+			cfg.constrain(products[r])
+		}
+		userGrads, productGrads = make(map[int][]float64), make(map[int][]float64)
+		pending = 0
+	}
+	step := func(u, i int, target float64) {
+		g := loss.Gradient(dot(users[u], products[i]), target)
+		if g != 0 || lambda != 0 {
+			x, y := users[u], products[i]
+			gx, gy := make([]float64, n_factors), make([]float64, n_factors)
+			for f := range x {
+				gx[f] = g*y[f] + lambda*x[f]
+				gy[f] = g*x[f] + lambda*y[f]
+			}
+			accumulate(userGrads, u, gx)
+			accumulate(productGrads, i, gy)
+		}
+		if pending++; pending == batch {
+			flush()
+		}
+	}
+	meanLoss := func() float64 {
+		total := 0.0
+		for _, e := range observed {
+			total += loss.Loss(dot(users[e[0]], products[e[1]]), model.P.Get(e[0], e[1]))
+		}
+		return total / math.Max(1, float64(len(observed)))
+	}
+
+	var sampler *sampling.Sampler
+	if cfg.negatives > 0 {
+		sampler = sampling.NewSampler(mask, sampling.Uniform, cfg.rng)
+	}
+
+	for epoch := 1; epoch <= epochs; epoch++ {
+		cfg.rng.Shuffle(len(observed), func(a, b int) { observed[a], observed[b] = observed[b], observed[a] })
+		for _, e := range observed {
+			u, i := e[0], e[1]
+			step(u, i, model.P.Get(u, i))
+			for n := 0; n < cfg.negatives; n++ {
+				// fails only for users who interacted with everything
+				j, err := sampler.Negative(u)
+				if err != nil {
+					break
+				}
+				step(u, j, 0)
+			}
+		}
+		flush()
+		model.Iterations = epoch
+		error_value := meanLoss()
+		cfg.record(&model.History, IterationStats{Iteration: epoch, TrainingError: error_value})
+		if cfg.err != nil {
+			return nil, 0, cfg.err
+		}
+		if cfg.onIteration != nil {
+			cfg.onIteration(epoch, error_value)
+		}
+	}
+	model.X, model.Y = MakeDenseMatrixStacked(users), MakeDenseMatrixStacked(products).Transpose()
+	model.trained(AlgorithmSGD, Q, n_factors, epochs, cfg)
+	model.meta.Hyperparameters["learning_rate"] = rate
+	model.meta.Hyperparameters["negatives"] = float64(cfg.negatives)
+	model.meta.Hyperparameters["batch_size"] = float64(batch)
+	model.meta.Hyperparameters["optimizer"] = float64(cfg.optimizer)
+	return model, meanLoss(), nil
+}
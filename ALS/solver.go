@@ -0,0 +1,289 @@
+package ALS
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Lower triangular factor L of a symmetric positive definite matrix A = L * L^T.
+// Once factored, any number of right hand sides can be solved by substitution.
+type cholesky struct {
+	n int
+	l []float64
+}
+
+// Factors the n x n matrix A. A must be symmetric positive definite, which holds
+// for the regularized normal equations of ALS whenever lambda > 0.
+func factorCholesky(A *DenseMatrix) (*cholesky, error) {
+	n := A.Rows()
+	if A.Cols() != n {
+		return nil, wrap(ErrDimensionMismatch, "Cholesky factorization needs a square matrix")
+	}
+	l := make([]float64, n*n)
+	for j := 0; j < n; j++ {
+		d := A.Get(j, j)
+		for k := 0; k < j; k++ {
+			d -= l[j*n+k] * l[j*n+k]
+		}
+		if d <= 0 || math.IsNaN(d) {
+			return nil, wrap(ErrSingularSystem, "Matrix is not positive definite")
+		}
+		d = math.Sqrt(d)
+		l[j*n+j] = d
+		for i := j + 1; i < n; i++ {
+			s := A.Get(i, j)
+			for k := 0; k < j; k++ {
+				s -= l[i*n+k] * l[j*n+k]
+			}
+			l[i*n+j] = s / d
+		}
+	}
+	return &cholesky{n: n, l: l}, nil
+}
+
+// solves A x = b using forward substitution with L followed by back substitution with L^T.
+func (c *cholesky) solve(b []float64) []float64 {
+	n := c.n
+	x := make([]float64, n)
+	copy(x, b)
+	for i := 0; i < n; i++ {
+		for k := 0; k < i; k++ {
+			x[i] -= c.l[i*n+k] * x[k]
+		}
+		x[i] /= c.l[i*n+i]
+	}
+	for i := n - 1; i >= 0; i-- {
+		for k := i + 1; k < n; k++ {
+			x[i] -= c.l[k*n+i] * x[k]
+		}
+		x[i] /= c.l[i*n+i]
+	}
+	return x
+}
+
+// Builds the regularized normal equations for a single row (user) or column (item) of ALS.
+// F holds the fixed factor vectors, so A = F^T * diag(w) * F + lambda*I and
+// b = F^T * diag(w) * q. Entries with zero weight are skipped, so NaN ratings are harmless.
+func normalEquations(F [][]float64, w, q []float64, lambda float64) (*DenseMatrix, []float64) {
+	k := len(F[0])
+	A := Zeros(k, k)
+	b := make([]float64, k)
+	for r, f := range F {
+		if w[r] == 0 {
+			continue
+		}
+		for i := 0; i < k; i++ {
+			wf := w[r] * f[i]
+			b[i] += wf * q[r]
+			for j := 0; j <= i; j++ {
+				A.Set(i, j, A.Get(i, j)+wf*f[j])
+			}
+		}
+	}
+	// mirror the lower triangle so A is exactly symmetric, then regularize
+	for i := 0; i < k; i++ {
+		for j := 0; j < i; j++ {
+			A.Set(j, i, A.Get(i, j))
+		}
+		A.Set(i, i, A.Get(i, i)+lambda)
+	}
+	return A, b
+}
+
+// Runs a fixed number of conjugate gradient steps on the normal equations, starting at x0.
+// A is never formed: A*v is computed as F^T * diag(w) * (F*v) + lambda*v, which costs
+// O(n*k) per step instead of the O(n*k^2) needed to build A for a direct solve.
+func conjugateGradient(F [][]float64, w, q, x0 []float64, lambda float64, steps int) []float64 {
+	k := len(x0)
+	x := make([]float64, k)
+	copy(x, x0)
+
+	// A*v for the current user/item
+	times := func(v []float64) []float64 {
+		out := make([]float64, k)
+		for r, f := range F {
+			if w[r] == 0 {
+				continue
+			}
+			fv := 0.0
+			for i := 0; i < k; i++ {
+				fv += f[i] * v[i]
+			}
+			fv *= w[r]
+			for i := 0; i < k; i++ {
+				out[i] += fv * f[i]
+			}
+		}
+		for i := 0; i < k; i++ {
+			out[i] += lambda * v[i]
+		}
+		return out
+	}
+
+	// residual r = b - A*x
+	res := times(x)
+	for i := 0; i < k; i++ {
+		res[i] = -res[i]
+	}
+	for r, f := range F {
+		if w[r] == 0 {
+			continue
+		}
+		for i := 0; i < k; i++ {
+			res[i] += w[r] * q[r] * f[i]
+		}
+	}
+	p := make([]float64, k)
+	copy(p, res)
+	rsold := dot(res, res)
+
+	for s := 0; s < steps; s++ {
+		if rsold < 1e-20 {
+			break
+		}
+		Ap := times(p)
+		alpha := rsold / dot(p, Ap)
+		for i := 0; i < k; i++ {
+			x[i] += alpha * p[i]
+			res[i] -= alpha * Ap[i]
+		}
+		rsnew := dot(res, res)
+		for i := 0; i < k; i++ {
+			p[i] = res[i] + (rsnew/rsold)*p[i]
+		}
+		rsold = rsnew
+	}
+	return x
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+// Solves the regularized least squares problem for a single user/item factor vector.
+// The current factor vector x0 is used as the starting point for iterative solvers.
+func (c *config) solveFactors(F [][]float64, w, q, x0 []float64, lambda float64) ([]float64, error) {
+	if c.cgSteps > 0 {
+		return c.constrain(conjugateGradient(F, w, q, x0, lambda, c.cgSteps)), nil
+	}
+	A, b := normalEquations(F, w, q, lambda)
+	chol, err := factorCholesky(A)
+	if err != nil {
+		return nil, err
+	}
+	return c.constrain(chol.solve(b)), nil
+}
+
+// F^T * diag(w) * q, the right hand side of the normal equations
+func rightHandSide(F [][]float64, w, q []float64) []float64 {
+	b := make([]float64, len(F[0]))
+	for r, f := range F {
+		if w[r] == 0 {
+			continue
+		}
+		for i := range b {
+			b[i] += w[r] * f[i] * q[r]
+		}
+	}
+	return b
+}
+
+// number of factor vectors handed to a worker at a time
+const solveChunk = 32
+
+// calls fn on consecutive chunks of [0, n), on at most workers goroutines
+func parallelFor(n, workers int, fn func(start, end int)) {
+	if workers <= 1 || n <= solveChunk {
+		fn(0, n)
+		return
+	}
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := start + solveChunk
+				if end > n {
+					end = n
+				}
+				fn(start, end)
+			}
+		}()
+	}
+	for start := 0; start < n; start += solveChunk {
+		chunks <- start
+	}
+	close(chunks)
+	wg.Wait()
+}
+
+// identifies the left hand side F^T * diag(w) * F + lambda*I of a system, given F
+func systemKey(w []float64, lambda float64) string {
+	key := make([]byte, 8*(len(w)+1))
+	for r, v := range w {
+		binary.LittleEndian.PutUint64(key[8*r:], math.Float64bits(v))
+	}
+	binary.LittleEndian.PutUint64(key[8*len(w):], math.Float64bits(lambda))
+	return string(key)
+}
+
+// Solves the regularized least squares problems of many users/items against the same fixed factors F,
+// one per row of W and Q, like solveFactors does for a single one. Rows with the same weights and lambda
+// share their left hand side, which is factored only once; all right hand sides are then solved in parallel
+// batches. A row whose system can't be solved gets a nil solution and its error.
+func (c *config) solveAll(F [][]float64, W, Q, X0 [][]float64, lambdas []float64) ([][]float64, []error) {
+	solutions := make([][]float64, len(W))
+	errs := make([]error, len(W))
+	if c.cgSteps > 0 {
+		parallelFor(len(W), c.parallelism, func(start, end int) {
+			for r := start; r < end; r++ {
+				solutions[r] = c.constrain(conjugateGradient(F, W[r], Q[r], X0[r], lambdas[r], c.cgSteps))
+			}
+		})
+		return solutions, errs
+	}
+
+	// group the rows by their left hand side, then factor every distinct one
+	system := make([]int, len(W))
+	var first []int
+	seen := make(map[string]int)
+	for r := range W {
+		key := systemKey(W[r], lambdas[r])
+		idx, ok := seen[key]
+		if !ok {
+			idx = len(first)
+			seen[key] = idx
+			first = append(first, r)
+		}
+		system[r] = idx
+	}
+	factors := make([]*cholesky, len(first))
+	factorErrs := make([]error, len(first))
+	parallelFor(len(first), c.parallelism, func(start, end int) {
+		for s := start; s < end; s++ {
+			r := first[s]
+			A, _ := normalEquations(F, W[r], Q[r], lambdas[r])
+			factors[s], factorErrs[s] = factorCholesky(A)
+		}
+	})
+
+	parallelFor(len(W), c.parallelism, func(start, end int) {
+		for r := start; r < end; r++ {
+			if err := factorErrs[system[r]]; err != nil {
+				errs[r] = err
+				continue
+			}
+			solutions[r] = c.constrain(factors[system[r]].solve(rightHandSide(F, W[r], Q[r])))
+		}
+	})
+	return solutions, errs
+}
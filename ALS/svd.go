@@ -0,0 +1,95 @@
+package ALS
+
+import (
+	"math"
+	"math/rand"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Start training from a truncated SVD of the rating matrix, with unrated entries filled in with
+// the user's mean rating, instead of random factors. The starting point is already close to a
+// good solution, so far fewer iterations are needed to converge. The SVD is of the full dense
+// matrix, so it is only worth it when that fits comfortably in memory.
+func WithSVDInit() Option {
+	return func(c *config) {
+		c.svdInit = true
+	}
+}
+
+// returns the model's targets with the entries without weight replaced by the user's mean target
+// (or the global mean for users without any)
+func meanFilled(m *Model) *DenseMatrix {
+	filled := m.P.Copy()
+	global, count := 0.0, 0.0
+	for u := 0; u < m.P.Rows(); u++ {
+		for i := 0; i < m.P.Cols(); i++ {
+			if m.W.Get(u, i) != 0 {
+				global += m.P.Get(u, i)
+				count++
+			}
+		}
+	}
+	if count > 0 {
+		global /= count
+	}
+	for u := 0; u < m.P.Rows(); u++ {
+		sum, n := 0.0, 0.0
+		for i := 0; i < m.P.Cols(); i++ {
+			if m.W.Get(u, i) != 0 {
+				sum += m.P.Get(u, i)
+				n++
+			}
+		}
+		mean := global
+		if n > 0 {
+			mean = sum / n
+		}
+		for i := 0; i < m.P.Cols(); i++ {
+			if m.W.Get(u, i) == 0 {
+				filled.Set(u, i, mean)
+			}
+		}
+	}
+	return filled
+}
+
+// Factors the mean filled targets as U*S*V^T and returns X = U_k*sqrt(S_k) and Y = sqrt(S_k)*V_k^T
+// for the k = n_factors largest singular values. Factors beyond the rank of the matrix are
+// drawn from rng at a small scale, so ALS can still make use of them.
+func svdFactors(m *Model, n_factors int, rng *rand.Rand) (X, Y *DenseMatrix, err error) {
+	A := meanFilled(m)
+	// the SVD needs at least as many rows as columns
+	transposed := A.Rows() < A.Cols()
+	if transposed {
+		A = A.Transpose()
+	}
+	U, S, V, err := A.SVD()
+	if err != nil {
+		return nil, nil, err
+	}
+	if transposed {
+		U, V = V, U
+	}
+	rows, cols := m.P.Rows(), m.P.Cols()
+	X, Y = Zeros(rows, n_factors), Zeros(n_factors, cols)
+	for f := 0; f < n_factors; f++ {
+		if f < S.Rows() && f < U.Cols() && f < V.Cols() {
+			scale := math.Sqrt(S.Get(f, f))
+			for u := 0; u < rows; u++ {
+				X.Set(u, f, U.Get(u, f)*scale)
+			}
+			for i := 0; i < cols; i++ {
+				Y.Set(f, i, V.Get(i, f)*scale)
+			}
+			continue
+		}
+		for u := 0; u < rows; u++ {
+			X.Set(u, f, 0.01*rng.Float64())
+		}
+		for i := 0; i < cols; i++ {
+			Y.Set(f, i, 0.01*rng.Float64())
+		}
+	}
+	return X, Y, nil
+}
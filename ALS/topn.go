@@ -0,0 +1,178 @@
+package ALS
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/timkaye11/goRecommend/ann"
+	"github.com/timkaye11/goRecommend/collabFilter"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// Builds an approximate nearest neighbor index over the product factors, which TopN then uses
+// instead of scoring every product. More tables give better recall, more bits faster queries;
+// see ann.NewIndex. The index is dropped by Update, since it changes the product factors.
+func (m *Model) BuildIndex(tables, bits int) error {
+	idx, err := ann.NewIndex(m.Y.Transpose().Arrays(), tables, bits, rand.New(rand.NewSource(defaultSeed)))
+	if err != nil {
+		return err
+	}
+	m.index = idx
+	return nil
+}
+
+// the user's row of observed
+func (m *Model) observedRow(user int) []float64 {
+	return m.observed().RowCopy(user)
+}
+
+// products the user has rated (or interacted with)
+func (m *Model) rated(user int) map[int]bool {
+	rated := make(map[int]bool)
+	if user < m.P.Rows() {
+		for i, o := range m.observedRow(user) {
+			if o != 0 {
+				rated[i] = true
+			}
+		}
+	}
+	return rated
+}
+
+// Returns the indices of the n products with the highest predicted values for the user, in
+// descending order along with their predictions. Products the user already rated are skipped,
+// as are products rejected by any of the filters, before ranking, so n products are returned
+// whenever that many pass. Uses the approximate index if one was built with BuildIndex.
+func (m *Model) TopN(user, n int, filters ...Filter) ([]int, []float64, error) {
+	return m.topN(user, n, 0, filters)
+}
+
+// Like TopN, but discounts the predictions by product popularity so long tail products get
+// surfaced: a positive prediction is divided by (1 + number of users who rated the product)^beta,
+// a negative one multiplied by it. beta = 0 is the same as TopN; around 0.5 is a good start.
+// The returned scores are the discounted ones.
+func (m *Model) TopNDebiased(user, n int, beta float64, filters ...Filter) ([]int, []float64, error) {
+	return m.topN(user, n, beta, filters)
+}
+
+// how many more candidates than needed to take from the index when re-scoring them changes the order
+const rerankCandidates = 4
+
+func (m *Model) topN(user, n int, beta float64, filters []Filter) ([]int, []float64, error) {
+	if user < 0 || user >= m.X.Rows() {
+		return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	// products that can't be recommended
+	rated := m.rated(user)
+	if len(filters) > 0 {
+		for i := 0; i < m.Y.Cols(); i++ {
+			if !rated[i] && !keep(filters, i) {
+				rated[i] = true
+			}
+		}
+	}
+	userFactors := m.X.RowCopy(user)
+	var discount func(i int) float64
+	if beta != 0 {
+		pop := m.productPopularity()
+		discount = func(i int) float64 {
+			return math.Pow(1+pop[i], beta)
+		}
+	}
+	score := func(i int) float64 {
+		s := m.denormalize(user, i, dot(userFactors, m.Y.ColCopy(i)))
+		if discount == nil {
+			return s
+		}
+		if s < 0 {
+			return s * discount(i)
+		}
+		return s / discount(i)
+	}
+
+	if m.index != nil {
+		if m.Norm == nil && discount == nil && !m.Clip {
+			ids, scores := m.index.Query(userFactors, n, rated)
+			return ids, scores, nil
+		}
+		// per product offsets and discounts can reorder the index's results, so re-score extra candidates
+		ids, _ := m.index.Query(userFactors, rerankCandidates*n, rated)
+		ids, scores := rank(ids, n, score)
+		return ids, scores, nil
+	}
+
+	products := make([]int, 0, m.Y.Cols())
+	for i := 0; i < m.Y.Cols(); i++ {
+		if !rated[i] {
+			products = append(products, i)
+		}
+	}
+	ids, scores := rank(products, n, score)
+	return ids, scores, nil
+}
+
+// number of users who rated (or interacted with) each product, cached until the next Update
+func (m *Model) productPopularity() []float64 {
+	m.popMu.Lock()
+	defer m.popMu.Unlock()
+	if m.popularity == nil {
+		pop := make([]float64, m.P.Cols())
+		for u := 0; u < m.P.Rows(); u++ {
+			for i, o := range m.observedRow(u) {
+				if o != 0 {
+					pop[i]++
+				}
+			}
+		}
+		m.popularity = pop
+	}
+	return m.popularity
+}
+
+// Returns the k products with the largest cosine similarity to the given product in
+// latent factor space ("customers who liked this also liked"), in descending order of similarity.
+// Uses the approximate index if one was built with BuildIndex.
+func (m *Model) SimilarItems(product, k int) ([]int, []float64, error) {
+	if product < 0 || product >= m.Y.Cols() {
+		return nil, nil, wrap(ErrInvalidArgument, "Product index out of range")
+	}
+	products := m.Y.Transpose().Arrays()
+	if m.index != nil {
+		// the index ranks by inner product, so re-score extra candidates by cosine similarity
+		ids, _ := m.index.Query(products[product], rerankCandidates*k, map[int]bool{product: true})
+		ids, sims := rank(ids, k, func(i int) float64 {
+			return collabFilter.CosineSim(products[product], products[i])
+		})
+		return ids, sims, nil
+	}
+	ids, sims := mostSimilar(products, product, k)
+	return ids, sims, nil
+}
+
+// Returns the k users with the largest cosine similarity to the given user in latent factor
+// space ("people with taste like yours"), in descending order of similarity.
+func (m *Model) SimilarUsers(user, k int) ([]int, []float64, error) {
+	if user < 0 || user >= m.X.Rows() {
+		return nil, nil, wrap(ErrInvalidArgument, "User index out of range")
+	}
+	ids, sims := mostSimilar(m.X.Arrays(), user, k)
+	return ids, sims, nil
+}
+
+// the k factor vectors most similar to factors[which], excluding itself
+func mostSimilar(factors [][]float64, which, k int) ([]int, []float64) {
+	others := make([]int, 0, len(factors)-1)
+	for i := range factors {
+		if i != which {
+			others = append(others, i)
+		}
+	}
+	return rank(others, k, func(i int) float64 {
+		return collabFilter.CosineSim(factors[which], factors[i])
+	})
+}
+
+// scores the candidates and returns the n best in descending order of score, ties by index
+func rank(candidates []int, n int, score func(int) float64) ([]int, []float64) {
+	return topk.Split(topk.Select(candidates, n, score))
+}
@@ -1,7 +1,6 @@
 package ALS
 
 import (
-	"fmt"
 	"io/ioutil"
 	"strconv"
 	"strings"
@@ -31,10 +30,13 @@ func min(vals []int) int {
 
 // read file with separator and load into a matrix.
 // If user/product ID's start at 1, set first product/user at row/col index 0.
-func Load(path, sep string) *DenseMatrix {
+// Fails if the file can't be read, or a line has fewer than three fields.
+func Load(path, sep string) (*DenseMatrix, error) {
 	// read in the file
 	f, err := ioutil.ReadFile(path)
-	errcheck(err)
+	if err != nil {
+		return nil, err
+	}
 	lines := strings.Split(string(f), "\n")
 
 	// determine the number of rows and columns
@@ -43,6 +45,9 @@ func Load(path, sep string) *DenseMatrix {
 	for _, line := range lines {
 		values := strings.Split(line, sep)
 		if line != "" {
+			if len(values) < 3 {
+				return nil, wrap(ErrInvalidArgument, "Line needs a user, product and rating: "+line)
+			}
 			row, _ := strconv.Atoi(values[0])
 			col, _ := strconv.Atoi(values[1])
 			row_count = append(row_count, row)
@@ -54,7 +59,6 @@ func Load(path, sep string) *DenseMatrix {
 	// and set the values accordingly
 	for _, line := range lines {
 		values := strings.Split(line, sep)
-		fmt.Println(values)
 		if line != "" {
 			row, _ := strconv.Atoi(values[0])
 			col, _ := strconv.Atoi(values[1])
@@ -66,5 +70,5 @@ func Load(path, sep string) *DenseMatrix {
 			}
 		}
 	}
-	return mat
+	return mat, nil
 }
@@ -0,0 +1,144 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Monitor a validation set of held out ratings after every iteration, and stop training once the
+// validation error has not improved for patience iterations (patience <= 0 never stops early).
+// The factors of the best iteration are returned rather than the last.
+// V must have the same shape as the rating matrix, with 0 (or NaN) for pairs that are not held out,
+// or the missing value of WithMissingValue; with WithObserved alone only NaN.
+// Explicit models are scored by RMSE on V; implicit models by the expected percentile ranking of
+// the held out products (0 means ranked first, 0.5 is no better than random), weighted by V.
+// Training fails with ErrInvalidArgument if V holds no ratings.
+func WithValidation(V *DenseMatrix, patience int) Option {
+	return func(c *config) {
+		c.validation = V
+		c.patience = patience
+	}
+}
+
+// drops a validation set that does not match the rating matrix or holds no ratings, whose
+// error would be NaN
+func (c *config) checkValidation(Q *DenseMatrix) {
+	if c.validation == nil {
+		return
+	}
+	if c.validation.Rows() != Q.Rows() || c.validation.Cols() != Q.Cols() {
+		c.fail(wrap(ErrDimensionMismatch, "Validation matrix needs to be the same dimension as the rating matrix"))
+		c.validation = nil
+		return
+	}
+	c.validationMask = c.missingMask(c.validation)
+	for _, o := range c.validationMask.Array() {
+		if o != 0 {
+			return
+		}
+	}
+	c.fail(wrap(ErrInvalidArgument, "Validation matrix holds no ratings"))
+	c.validation, c.validationMask = nil, nil
+}
+
+// Returns the validation error of the model on V: RMSE for explicit models, expected percentile
+// ranking for implicit ones. Lower is better for both. NaN if V holds no ratings.
+// Entries of V equal to the model's MissingValue (or NaN) are not held out.
+func (m *Model) ValidationError(V *DenseMatrix) float64 {
+	return m.validationError(V, notMissing(V, m.MissingValue))
+}
+
+// validation error on the entries of V that are nonzero in observed
+func (m *Model) validationError(V, observed *DenseMatrix) float64 {
+	Qhat := m.Predictions()
+	if m.Implicit {
+		return expectedPercentileRank(V, observed, Qhat)
+	}
+	return rmse(V, observed, Qhat)
+}
+
+// root mean squared error of the predictions over the observed entries of V
+func rmse(V, observed, Qhat *DenseMatrix) float64 {
+	sum, n := 0.0, 0.0
+	for u := 0; u < V.Rows(); u++ {
+		for i := 0; i < V.Cols(); i++ {
+			if observed.Get(u, i) != 0 {
+				diff := V.Get(u, i) - Qhat.Get(u, i)
+				sum += diff * diff
+				n++
+			}
+		}
+	}
+	return math.Sqrt(sum / n)
+}
+
+// Hu, Koren & Volinsky's expected percentile ranking of the held out products in V: the percentile
+// of each product in its user's list of predictions, averaged with the values of V as weights.
+// Only the entries that are nonzero in observed are held out.
+func expectedPercentileRank(V, observed, Qhat *DenseMatrix) float64 {
+	sum, n := 0.0, 0.0
+	cols := float64(V.Cols())
+	for u := 0; u < V.Rows(); u++ {
+		scores := Qhat.RowCopy(u)
+		for i := 0; i < V.Cols(); i++ {
+			val := V.Get(u, i)
+			if observed.Get(u, i) == 0 || math.IsNaN(val) {
+				continue
+			}
+			// number of products scored higher than i
+			above := 0.0
+			for _, s := range scores {
+				if s > scores[i] {
+					above++
+				}
+			}
+			rank := 0.0
+			if cols > 1 {
+				rank = above / (cols - 1)
+			}
+			sum += val * rank
+			n += val
+		}
+	}
+	return sum / n
+}
+
+// tracks the best iteration on the validation set
+type earlyStopping struct {
+	patience int
+
+	best       float64
+	bestX      *DenseMatrix
+	bestY      *DenseMatrix
+	bestIter   int
+	sinceBest  int
+	monitoring bool
+}
+
+func (c *config) earlyStopping() *earlyStopping {
+	return &earlyStopping{patience: c.patience, monitoring: c.validation != nil}
+}
+
+// records the model's validation error score, returning true once training should stop
+func (e *earlyStopping) done(m *Model, score float64) bool {
+	if !e.monitoring {
+		return false
+	}
+	if e.bestX == nil || score < e.best {
+		e.best = score
+		e.bestX, e.bestY, e.bestIter = m.X.Copy(), m.Y.Copy(), m.Iterations
+		e.sinceBest = 0
+		return false
+	}
+	e.sinceBest++
+	return e.patience > 0 && e.sinceBest >= e.patience
+}
+
+// puts the best factors seen back into the model
+func (e *earlyStopping) restoreBest(m *Model) {
+	if e.bestX == nil {
+		return
+	}
+	m.X, m.Y, m.Iterations = e.bestX, e.bestY, e.bestIter
+}
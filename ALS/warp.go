@@ -0,0 +1,107 @@
+package ALS
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/sampling"
+)
+
+// negatives tried per interaction by TrainWARP unless WithNegativeSamples is given
+const warpMaxSampled = 10
+
+// Params: the interaction matrix (0 or NaN for no interaction), number of factors, number of passes
+// over the interactions, lambda, and learning rate. Learns to rank with the WARP loss (weighted
+// approximate-rank pairwise, as in LightFM): for every interaction, products the user never
+// interacted with are drawn until one scores within a margin of 1 of it, and the pair is updated
+// with a weight that grows with the number of draws it took, estimating how far down the list the
+// interacted product is. This focuses on the top of the ranking, so it usually beats pairwise
+// (BPR style) objectives on Precision@K. WithNegativeSamples sets the maximum number of draws.
+func TrainWARP(R *DenseMatrix, n_factors, epochs int, lambda, rate float64, opts ...Option) (*Model, error) {
+	cfg := newConfig(opts)
+	maxSampled := cfg.negatives
+	if maxSampled <= 0 {
+		maxSampled = warpMaxSampled
+	}
+	mask := cfg.observedMask(R)
+	model := &Model{
+		W:            confidences(R, mask),
+		P:            mask,
+		Lambda:       lambda,
+		Implicit:     true,
+		NonNegative:  cfg.nonNegative,
+		MaxNorm:      cfg.maxNorm,
+		MissingValue: cfg.missing(),
+	}
+	model.X, model.Y = cfg.initialFactors(R.Rows(), R.Cols(), n_factors, smallInitScale)
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	users, products := model.X.Arrays(), model.Y.Transpose().Arrays()
+	sampler := sampling.NewSampler(mask, sampling.Uniform, cfg.rng)
+
+	observed := make([][2]int, 0)
+	interactions := make([]int, R.Rows())
+	for u := 0; u < R.Rows(); u++ {
+		for i := 0; i < R.Cols(); i++ {
+			if model.P.Get(u, i) != 0 {
+				observed = append(observed, [2]int{u, i})
+				interactions[u]++
+			}
+		}
+	}
+	// rank weights: harmonic numbers, so mistakes at the top of the list cost the most
+	weights := make([]float64, len(products)+1)
+	for k := 1; k < len(weights); k++ {
+		weights[k] = weights[k-1] + 1/float64(k)
+	}
+
+	for epoch := 1; epoch <= epochs; epoch++ {
+		cfg.rng.Shuffle(len(observed), func(a, b int) { observed[a], observed[b] = observed[b], observed[a] })
+		violations := 0
+		for _, e := range observed {
+			u, i := e[0], e[1]
+			x := users[u]
+			positive := dot(x, products[i])
+			negatives := len(products) - interactions[u]
+			if negatives == 0 {
+				continue
+			}
+			for sampled := 1; sampled <= maxSampled; sampled++ {
+				j, err := sampler.Negative(u)
+				if err != nil || dot(x, products[j]) <= positive-1 {
+					continue
+				}
+				// estimated rank of the positive among the negatives
+				weight := rate * weights[(negatives-1)/sampled+1]
+				yi, yj := products[i], products[j]
+				for f := range x {
+					xf := x[f]
+					x[f] += weight*(yi[f]-yj[f]) - rate*lambda*xf
+					yi[f] += weight*xf - rate*lambda*yi[f]
+					yj[f] -= weight*xf + rate*lambda*yj[f]
+				}
+				cfg.constrain(x)
+				cfg.constrain(yi)
+				cfg.constrain(yj)
+				violations++
+				break
+			}
+		}
+		model.Iterations = epoch
+		// fraction of interactions with a margin violating negative found
+		error_value := float64(violations) / math.Max(1, float64(len(observed)))
+		cfg.record(&model.History, IterationStats{Iteration: epoch, TrainingError: error_value})
+		if cfg.err != nil {
+			return nil, cfg.err
+		}
+		if cfg.onIteration != nil {
+			cfg.onIteration(epoch, error_value)
+		}
+	}
+	model.X, model.Y = MakeDenseMatrixStacked(users), MakeDenseMatrixStacked(products).Transpose()
+	model.trained(AlgorithmWARP, R, n_factors, epochs, cfg)
+	model.meta.Hyperparameters["learning_rate"] = rate
+	model.meta.Hyperparameters["max_sampled"] = float64(maxSampled)
+	return model, nil
+}
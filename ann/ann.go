@@ -0,0 +1,153 @@
+// Approximate nearest neighbor search over latent factor vectors in Go
+package ann
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// Index answers approximate maximum inner product queries over a fixed set of vectors
+// (e.g. the product factors of a matrix factorization model) using random hyperplane LSH.
+//
+// Vectors are hashed into buckets in several independent tables; a query is only scored
+// against the vectors sharing a bucket with it in some table. More tables find more of the
+// true neighbors (recall), more bits per table make buckets smaller (speed).
+type Index struct {
+	vectors [][]float64
+	maxNorm float64
+	bits    int
+	planes  [][][]float64
+	tables  []map[uint64][]int
+}
+
+// Builds an index over vectors with the given number of hash tables and bits per table (at most 64).
+// The vectors are referenced, not copied, so they should not change while the index is in use.
+func NewIndex(vectors [][]float64, tables, bits int, rng *rand.Rand) (*Index, error) {
+	if len(vectors) == 0 {
+		return nil, errors.New("Cannot index an empty set of vectors")
+	}
+	if tables < 1 || bits < 1 || bits > 64 {
+		return nil, errors.New("Need at least one table, and between 1 and 64 bits per table")
+	}
+	dim := len(vectors[0])
+	idx := &Index{vectors: vectors, bits: bits}
+	for _, v := range vectors {
+		if len(v) != dim {
+			return nil, errors.New("All vectors need to have the same dimension")
+		}
+		idx.maxNorm = math.Max(idx.maxNorm, norm(v))
+	}
+
+	idx.planes = make([][][]float64, tables)
+	idx.tables = make([]map[uint64][]int, tables)
+	for t := 0; t < tables; t++ {
+		idx.planes[t] = make([][]float64, bits)
+		for b := 0; b < bits; b++ {
+			plane := make([]float64, dim+1)
+			for i := range plane {
+				plane[i] = rng.NormFloat64()
+			}
+			idx.planes[t][b] = plane
+		}
+		idx.tables[t] = make(map[uint64][]int)
+		for id, v := range vectors {
+			key := idx.hash(t, idx.itemPoint(v))
+			idx.tables[t][key] = append(idx.tables[t][key], id)
+		}
+	}
+	return idx, nil
+}
+
+// Returns the number of indexed vectors.
+func (idx *Index) Len() int {
+	return len(idx.vectors)
+}
+
+// Returns the ids (positions in the indexed set) of approximately the k vectors with the largest
+// inner product with vector, in descending order, along with their exact inner products.
+// The ids in skip are never returned. If the buckets probed hold fewer than k candidates,
+// all vectors are scored so that k results are always returned when possible.
+func (idx *Index) Query(vector []float64, k int, skip map[int]bool) ([]int, []float64) {
+	q := idx.queryPoint(vector)
+	keys := make([]uint64, len(idx.tables))
+	candidates := make(map[int]bool)
+	for t, table := range idx.tables {
+		keys[t] = idx.hash(t, q)
+		for _, id := range table[keys[t]] {
+			if !skip[id] {
+				candidates[id] = true
+			}
+		}
+	}
+	// multi-probe: look in the buckets one bit flip away
+	for b := 0; b < idx.bits && len(candidates) < k; b++ {
+		for t, table := range idx.tables {
+			for _, id := range table[keys[t]^(1<<uint(b))] {
+				if !skip[id] {
+					candidates[id] = true
+				}
+			}
+		}
+	}
+	if len(candidates) < k {
+		for id := range idx.vectors {
+			if !skip[id] {
+				candidates[id] = true
+			}
+		}
+	}
+
+	ids := make([]int, 0, len(candidates))
+	for id := range candidates {
+		ids = append(ids, id)
+	}
+	return topk.Split(topk.Select(ids, k, func(id int) float64 {
+		return dot(vector, idx.vectors[id])
+	}))
+}
+
+// Maximum inner product search reduces to cosine similarity by appending a coordinate that gives
+// every indexed vector the same norm (Bachrach et al. 2014), with 0 appended to the query.
+func (idx *Index) itemPoint(v []float64) []float64 {
+	p := make([]float64, len(v)+1)
+	if idx.maxNorm == 0 {
+		return p
+	}
+	for i, x := range v {
+		p[i] = x / idx.maxNorm
+	}
+	p[len(v)] = math.Sqrt(math.Max(0, 1-dot(p[:len(v)], p[:len(v)])))
+	return p
+}
+
+func (idx *Index) queryPoint(v []float64) []float64 {
+	p := make([]float64, len(v)+1)
+	copy(p, v)
+	return p
+}
+
+// sign pattern of the point against the table's hyperplanes
+func (idx *Index) hash(table int, p []float64) uint64 {
+	var key uint64
+	for b, plane := range idx.planes[table] {
+		if dot(plane, p) >= 0 {
+			key |= 1 << uint(b)
+		}
+	}
+	return key
+}
+
+func dot(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func norm(a []float64) float64 {
+	return math.Sqrt(dot(a, a))
+}
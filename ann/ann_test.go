@@ -0,0 +1,80 @@
+package ann
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func randomVectors(rng *rand.Rand, n, dim int) [][]float64 {
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		vectors[i] = make([]float64, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.NormFloat64()
+		}
+	}
+	return vectors
+}
+
+// exact top k by inner product
+func bruteForce(vectors [][]float64, q []float64, k int) []int {
+	ids := make([]int, len(vectors))
+	for i := range ids {
+		ids[i] = i
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		return dot(q, vectors[ids[a]]) > dot(q, vectors[ids[b]])
+	})
+	return ids[:k]
+}
+
+func TestQuery(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 500, 8)
+	idx, err := NewIndex(vectors, 16, 6, rng)
+	Assert(t, err == nil, err)
+
+	found, total := 0, 0
+	for q := 0; q < 20; q++ {
+		query := randomVectors(rng, 1, 8)[0]
+		ids, scores := idx.Query(query, 10, nil)
+		Assert(t, len(ids) == 10 && len(scores) == 10)
+		Assert(t, sort.IsSorted(sort.Reverse(sort.Float64Slice(scores))), scores)
+		exact := make(map[int]bool)
+		for _, id := range bruteForce(vectors, query, 10) {
+			exact[id] = true
+		}
+		for _, id := range ids {
+			if exact[id] {
+				found++
+			}
+		}
+		total += 10
+	}
+	recall := float64(found) / float64(total)
+	Assert(t, recall > 0.7, recall)
+}
+
+func TestQuerySkipAndFallback(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	vectors := randomVectors(rng, 20, 4)
+	// lots of bits makes for tiny buckets, so the query falls back to scoring everything
+	idx, _ := NewIndex(vectors, 1, 32, rng)
+	ids, _ := idx.Query(vectors[0], 19, map[int]bool{3: true})
+	Assert(t, len(ids) == 19)
+	for _, id := range ids {
+		Assert(t, id != 3)
+	}
+
+	_, err := NewIndex(nil, 1, 1, rng)
+	Assert(t, err != nil)
+	_, err = NewIndex(vectors, 1, 65, rng)
+	Assert(t, err != nil)
+}
@@ -0,0 +1,132 @@
+// Baseline (bias and popularity) recommenders in Go
+package baseline
+
+import (
+	"errors"
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// Wrapper for MakeDenseMatrix. Returns rating matrix
+func MakeRatingMatrix(ratings []float64, rows, cols int) *DenseMatrix {
+	return MakeDenseMatrix(ratings, rows, cols)
+}
+
+// Baseline predictor r_ui = mean + b_u + b_i. The biases are damped towards 0 by lambda
+// so users/products with few ratings stay close to the global mean.
+// Popularity holds the number of ratings each product received. Observed is the mask of rated
+// entries the model was trained with, nil when 0 and NaN mean not rated.
+type Model struct {
+	Q          *DenseMatrix
+	Observed   *DenseMatrix
+	Mean       float64
+	UserBias   []float64
+	ItemBias   []float64
+	Popularity []float64
+}
+
+func missing(val float64) bool {
+	return val == 0.0 || math.IsNaN(val)
+}
+
+// whether the user rated the product: nonzero in the observed mask if there is one, otherwise
+// neither 0 nor NaN
+func (m *Model) rated(user, product int) bool {
+	if m.Observed != nil {
+		return m.Observed.Get(user, product) != 0 && !math.IsNaN(m.Q.Get(user, product))
+	}
+	return !missing(m.Q.Get(user, product))
+}
+
+// Params: the user/product matrix (0 or NaN means not rated) and the damping term lambda.
+// Returns the trained baseline model.
+func Train(Q *DenseMatrix, lambda float64) (*Model, error) {
+	return TrainObserved(Q, nil, lambda)
+}
+
+// Like Train, but the entries that are nonzero in observed (of the same shape as Q, e.g. from
+// data.Dataset's Observed) are the ratings, so ratings of 0 count like any other.
+func TrainObserved(Q, observed *DenseMatrix, lambda float64) (*Model, error) {
+	rows, cols := Q.Rows(), Q.Cols()
+	if observed != nil && (observed.Rows() != rows || observed.Cols() != cols) {
+		return nil, errors.New("Observed mask needs to be the same dimension as the rating matrix")
+	}
+	m := &Model{
+		Q:          Q,
+		Observed:   observed,
+		UserBias:   make([]float64, rows),
+		ItemBias:   make([]float64, cols),
+		Popularity: make([]float64, cols),
+	}
+	// global mean over the observed ratings
+	count := 0.0
+	for u := 0; u < rows; u++ {
+		for i := 0; i < cols; i++ {
+			if m.rated(u, i) {
+				m.Mean += Q.Get(u, i)
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return nil, errors.New("Rating matrix has no observed ratings")
+	}
+	m.Mean /= count
+
+	// product biases first, then user biases on the remaining residuals
+	for i := 0; i < cols; i++ {
+		sum := 0.0
+		for u := 0; u < rows; u++ {
+			if m.rated(u, i) {
+				sum += Q.Get(u, i) - m.Mean
+				m.Popularity[i]++
+			}
+		}
+		// products nobody rated keep a bias of 0 when lambda is 0
+		if m.Popularity[i]+lambda > 0 {
+			m.ItemBias[i] = sum / (lambda + m.Popularity[i])
+		}
+	}
+	for u := 0; u < rows; u++ {
+		sum, n := 0.0, 0.0
+		for i := 0; i < cols; i++ {
+			if m.rated(u, i) {
+				sum += Q.Get(u, i) - m.Mean - m.ItemBias[i]
+				n++
+			}
+		}
+		if n+lambda > 0 {
+			m.UserBias[u] = sum / (lambda + n)
+		}
+	}
+	return m, nil
+}
+
+// Returns the baseline prediction for a user/product pair. Users or products the model
+// has never seen get a bias of 0, so this can be used as a fallback for cold starts.
+func (m *Model) Predict(user, product int) float64 {
+	pred := m.Mean
+	if user >= 0 && user < len(m.UserBias) {
+		pred += m.UserBias[user]
+	}
+	if product >= 0 && product < len(m.ItemBias) {
+		pred += m.ItemBias[product]
+	}
+	return pred
+}
+
+// Returns the indices of the n most rated products in descending order of popularity,
+// skipping products the user already rated. Unknown users get the overall most popular products.
+func (m *Model) MostPopular(user, n int) []int {
+	candidates := make([]int, 0)
+	for i := range m.Popularity {
+		if user >= 0 && user < m.Q.Rows() && m.rated(user, i) {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+	top, _ := topk.Split(topk.Select(candidates, n, func(i int) float64 { return m.Popularity[i] }))
+	return top
+}
@@ -0,0 +1,151 @@
+package baseline
+
+import (
+	"math"
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestBaseline(t *testing.T) {
+	Q := MakeRatingMatrix([]float64{
+		5, 4, 0, 1,
+		4, 0, 0, 1,
+		5, 5, 1, 0,
+		0, 4, 0, 2}, 4, 4)
+
+	model, err := Train(Q, 0)
+	Assert(t, err == nil)
+	Assert(t, math.Abs(model.Mean-3.2) < 1e-9, model.Mean)
+	// product 0 is rated above the mean, product 3 below
+	Assert(t, model.ItemBias[0] > 0 && model.ItemBias[3] < 0, model.ItemBias)
+	Assert(t, model.Predict(0, 0) > model.Predict(0, 3))
+	// unknown users and products fall back to the mean
+	Assert(t, model.Predict(10, 10) == model.Mean)
+
+	// damping pulls biases towards 0
+	damped, _ := Train(Q, 10)
+	Assert(t, math.Abs(damped.ItemBias[2]) < math.Abs(model.ItemBias[2]))
+
+	// a product nobody rated keeps a bias of 0 without damping
+	unrated := MakeRatingMatrix([]float64{
+		4, 0, 2,
+		5, 0, 1}, 2, 3)
+	model, err = Train(unrated, 0)
+	Assert(t, err == nil && model.ItemBias[1] == 0, model.ItemBias)
+	Assert(t, model.Predict(0, 1) == model.Mean+model.UserBias[0], model.Predict(0, 1))
+
+	// with an observed mask, a rating of 0 counts
+	observed := MakeRatingMatrix([]float64{
+		1, 1, 1,
+		1, 0, 1}, 2, 3)
+	model, err = TrainObserved(unrated, observed, 0)
+	Assert(t, err == nil && model.Popularity[1] == 1 && math.Abs(model.Mean-2.4) < 1e-9, model.Popularity, model.Mean)
+	Assert(t, math.Abs(model.ItemBias[1]+2.4) < 1e-9, model.ItemBias)
+	Assert(t, len(model.MostPopular(0, 3)) == 0 && model.MostPopular(1, 3)[0] == 1)
+	_, err = TrainObserved(unrated, observed.Transpose(), 0)
+	Assert(t, err != nil)
+}
+
+func TestMostPopular(t *testing.T) {
+	Q := MakeRatingMatrix([]float64{
+		5, 4, 0, 1,
+		4, 0, 0, 1,
+		5, 5, 1, 0,
+		0, 4, 0, 2}, 4, 4)
+
+	model, _ := Train(Q, 0)
+	popular := model.MostPopular(1, 2)
+	Assert(t, len(popular) == 2 && popular[0] == 1 && popular[1] == 2, popular)
+	// cold start user gets everything in order of popularity
+	popular = model.MostPopular(-1, 4)
+	Assert(t, popular[0] == 0 && popular[3] == 2, popular)
+
+	_, err := Train(MakeRatingMatrix([]float64{0, 0, 0, 0}, 2, 2), 0)
+	Assert(t, err != nil)
+}
+
+func TestCohorts(t *testing.T) {
+	// German users like product 0, French users product 2; everybody rated product 3
+	Q := MakeRatingMatrix([]float64{
+		5, 0, 0, 1,
+		4, 0, 0, 1,
+		5, 1, 0, 1,
+		0, 0, 4, 2,
+		0, 0, 5, 2,
+		1, 0, 0, 2}, 6, 4)
+	users := []Attributes{
+		{"country": "DE", "age": "18-24"},
+		{"country": "DE", "age": "25-34"},
+		{"country": "DE", "age": "18-24"},
+		{"country": "FR", "age": "18-24"},
+		{"country": "FR", "age": "25-34"},
+		{"country": "US", "age": "18-24"},
+	}
+	_, err := TrainCohorts(Q, users[:2], "country")
+	Assert(t, err != nil)
+	c, err := TrainCohorts(Q, users, "country", "age")
+	Assert(t, err == nil, err)
+	c.MinUsers = 2
+
+	top, counts := c.Recommend(Attributes{"country": "DE", "age": "18-24"}, 2, nil)
+	Assert(t, len(top) == 2 && top[0] == 0 && top[1] == 3 && counts[0] == 2, top, counts)
+	// too few 35-44s in France, so all of France
+	top, _ = c.Recommend(Attributes{"country": "FR", "age": "35-44"}, 1, nil)
+	Assert(t, top[0] == 2, top)
+	// nobody from Spain: everybody
+	top, counts = c.Recommend(Attributes{"country": "ES"}, 1, nil)
+	Assert(t, top[0] == 3 && counts[0] == 6, top, counts)
+	top, _ = c.Recommend(Attributes{"country": "DE"}, 1, func(i int) bool { return i != 0 })
+	Assert(t, top[0] == 3, top)
+
+	top, _ = c.ColdStart(3, 1, nil)
+	Assert(t, top[0] == 2, top)
+	top, _ = c.ColdStart(42, 1, nil)
+	Assert(t, top[0] == 3, top)
+}
+
+func TestTemporal(t *testing.T) {
+	// product 0 is loved early on and falls out of favour, product 1 the other way round;
+	// ratings in period 0 (t < 10) and period 1 (t >= 10)
+	Q := MakeRatingMatrix([]float64{
+		5, 1, 3,
+		5, 2, 3,
+		1, 5, 3,
+		2, 5, 3}, 4, 3)
+	T := MakeRatingMatrix([]float64{
+		1, 2, 3,
+		2, 3, 4,
+		12, 13, 14,
+		13, 14, 15}, 4, 3)
+
+	_, err := TrainTemporal(Q, MakeRatingMatrix([]float64{1, 2}, 1, 2), 10, 1, 5)
+	Assert(t, err != nil)
+	_, err = TrainTemporal(Q, T, 0, 1, 5)
+	Assert(t, err != nil)
+
+	model, err := TrainTemporal(Q, T, 10, 0.1, 10)
+	Assert(t, err == nil, err)
+	Assert(t, len(model.ItemBinBias[0]) == 2, model.ItemBinBias)
+	Assert(t, model.ItemBinBias[0][0] > 0 && model.ItemBinBias[0][1] < 0, model.ItemBinBias)
+	Assert(t, model.Predict(0, 0, 1) > model.Predict(0, 0, 15), model.Predict(0, 0, 1), model.Predict(0, 0, 15))
+	Assert(t, model.Predict(0, 1, 1) < model.Predict(0, 1, 15))
+
+	// fits better than the static baseline
+	static, _ := Train(Q, 0.1)
+	staticErr, temporalErr := 0.0, 0.0
+	for u := 0; u < 4; u++ {
+		for i := 0; i < 3; i++ {
+			staticErr += math.Pow(Q.Get(u, i)-static.Predict(u, i), 2)
+			temporalErr += math.Pow(Q.Get(u, i)-model.Predict(u, i, T.Get(u, i)), 2)
+		}
+	}
+	Assert(t, temporalErr < staticErr/2, temporalErr, staticErr)
+
+	// unknown users/products and times past the last bin still get a prediction
+	Assert(t, model.Predict(10, 10, 0) == model.Mean && !math.IsNaN(model.Predict(0, 0, 100)))
+}
@@ -0,0 +1,103 @@
+package baseline
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// Attributes describing a user, e.g. {"age": "25-34", "country": "DE", "channel": "ads"}.
+type Attributes map[string]string
+
+// Cold start recommender for users without any ratings: recommends the most popular products among
+// the users sharing their attributes (their cohort). Cohorts are defined by Keys, most important first;
+// when a user's full cohort has fewer than MinUsers users the last key is dropped, down to the
+// overall most popular products. Users holds the attributes of every user by index.
+type Cohorts struct {
+	Keys     []string
+	MinUsers int
+	Users    []Attributes
+
+	// number of users in, and popularity of every product within, every cohort on every level
+	sizes      map[string]int
+	popularity map[string][]float64
+}
+
+// Params: the user/product matrix (0 or NaN means not rated), the attributes of its users (one per
+// row), and the attributes defining cohorts, most important first, e.g. "country", "age", "channel".
+// Cohorts with fewer than 5 users are not used; change MinUsers to adjust.
+func TrainCohorts(Q *DenseMatrix, users []Attributes, keys ...string) (*Cohorts, error) {
+	if len(users) != Q.Rows() {
+		return nil, errors.New("Need the attributes of every user of the rating matrix")
+	}
+	c := &Cohorts{
+		Keys:       keys,
+		MinUsers:   5,
+		Users:      users,
+		sizes:      make(map[string]int),
+		popularity: make(map[string][]float64),
+	}
+	for u, attrs := range users {
+		for level := 0; level <= len(keys); level++ {
+			cohort := c.cohort(attrs, level)
+			pop, ok := c.popularity[cohort]
+			if !ok {
+				pop = make([]float64, Q.Cols())
+				c.popularity[cohort] = pop
+			}
+			c.sizes[cohort]++
+			for i := range pop {
+				if !missing(Q.Get(u, i)) {
+					pop[i]++
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// name of the cohort made of the first level keys of the attributes; level 0 is everybody
+func (c *Cohorts) cohort(attrs Attributes, level int) string {
+	parts := make([]string, level)
+	for k := 0; k < level; k++ {
+		parts[k] = c.Keys[k] + "=" + attrs[c.Keys[k]]
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// Returns the products most popular in the cohort of a user with the given attributes, along with
+// how many users of the cohort rated them, best first. Only products keep accepts are returned;
+// a nil keep accepts all. Nil attributes get the overall most popular products.
+func (c *Cohorts) Recommend(attrs Attributes, n int, keep func(product int) bool) ([]int, []float64) {
+	level := len(c.Keys)
+	if attrs == nil {
+		level = 0
+	}
+	for ; level >= 0; level-- {
+		cohort := c.cohort(attrs, level)
+		if c.sizes[cohort] < c.MinUsers && level > 0 {
+			continue
+		}
+		pop := c.popularity[cohort]
+		candidates := make([]int, 0, len(pop))
+		for i := range pop {
+			if keep == nil || keep(i) {
+				candidates = append(candidates, i)
+			}
+		}
+		return topk.Split(topk.Select(candidates, n, func(i int) float64 { return pop[i] }))
+	}
+	return nil, nil
+}
+
+// Like Recommend, for the user with the given index in Users. Users without attributes, e.g. ones
+// that signed up after training, get the overall most popular products. Fits ALS.Hybrid's ColdStart.
+func (c *Cohorts) ColdStart(user, n int, keep func(product int) bool) ([]int, []float64) {
+	var attrs Attributes
+	if user >= 0 && user < len(c.Users) {
+		attrs = c.Users[user]
+	}
+	return c.Recommend(attrs, n, keep)
+}
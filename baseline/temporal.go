@@ -0,0 +1,189 @@
+package baseline
+
+import (
+	"errors"
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// exponent of the user drift term dev_u(t), as in the timeSVD++ paper
+const driftExponent = 0.4
+
+// Time aware baseline predictor in the style of timeSVD++ (Koren, "Collaborative Filtering with
+// Temporal Dynamics"):
+//
+//	r_ui(t) = mean + b_i + b_i,bin(t) + b_u + alpha_u * dev_u(t) + b_u,bin(t)
+//
+// Time is cut into bins of Period starting at Start. The product bins capture products getting more
+// or less popular, the user bins sudden changes of a user's rating scale, and alpha_u a gradual
+// drift: dev_u(t) = sign(t - t_u) * |t - t_u|^0.4, with t - t_u the number of periods since the
+// user's mean rating time t_u.
+type TemporalModel struct {
+	Mean        float64
+	UserBias    []float64
+	ItemBias    []float64
+	UserDrift   []float64
+	UserBinBias [][]float64
+	ItemBinBias [][]float64
+	// mean rating time of every user
+	UserTime []float64
+	Start    float64
+	Period   float64
+}
+
+// an observed rating, with its time bin and user drift
+type timedRating struct {
+	user, item, bin int
+	value, dev      float64
+}
+
+// Params: the user/product matrix (0 or NaN means not rated), the time of every rating (same shape,
+// in any unit such as unix seconds), the length of a time bin in the same unit, the damping term
+// lambda and the number of passes over the bias terms. Returns the trained model.
+func TrainTemporal(Q, T *DenseMatrix, period, lambda float64, iterations int) (*TemporalModel, error) {
+	rows, cols := Q.Rows(), Q.Cols()
+	if T.Rows() != rows || T.Cols() != cols {
+		return nil, errors.New("Timestamp matrix needs to be the same dimension as the rating matrix")
+	}
+	if period <= 0 {
+		return nil, errors.New("Time bin period must be positive")
+	}
+	m := &TemporalModel{
+		UserBias:  make([]float64, rows),
+		ItemBias:  make([]float64, cols),
+		UserDrift: make([]float64, rows),
+		UserTime:  make([]float64, rows),
+		Period:    period,
+	}
+
+	// observed ratings, the time span and every user's mean rating time
+	var ratings []timedRating
+	start, end := math.Inf(1), math.Inf(-1)
+	counts := make([]float64, rows)
+	for u := 0; u < rows; u++ {
+		for i := 0; i < cols; i++ {
+			val, t := Q.Get(u, i), T.Get(u, i)
+			if missing(val) || math.IsNaN(t) {
+				continue
+			}
+			ratings = append(ratings, timedRating{user: u, item: i, value: val})
+			m.Mean += val
+			m.UserTime[u] += t
+			counts[u]++
+			start, end = math.Min(start, t), math.Max(end, t)
+		}
+	}
+	if len(ratings) == 0 {
+		return nil, errors.New("Rating matrix has no observed ratings")
+	}
+	m.Mean /= float64(len(ratings))
+	for u := range m.UserTime {
+		if counts[u] > 0 {
+			m.UserTime[u] /= counts[u]
+		}
+	}
+	m.Start = start
+	bins := m.bin(end) + 1
+	m.UserBinBias = make([][]float64, rows)
+	for u := range m.UserBinBias {
+		m.UserBinBias[u] = make([]float64, bins)
+	}
+	m.ItemBinBias = make([][]float64, cols)
+	for i := range m.ItemBinBias {
+		m.ItemBinBias[i] = make([]float64, bins)
+	}
+	for n := range ratings {
+		r := &ratings[n]
+		t := T.Get(r.user, r.item)
+		r.bin = m.bin(t)
+		r.dev = m.dev(r.user, t)
+	}
+
+	// every pass refits each term on the residuals of all the others, damped towards 0 by lambda
+	residuals := make([]float64, len(ratings))
+	for iter := 0; iter < iterations; iter++ {
+		m.fitTerm(ratings, residuals, lambda, func(r *timedRating) (*float64, float64) {
+			return &m.ItemBias[r.item], 1
+		})
+		m.fitTerm(ratings, residuals, lambda, func(r *timedRating) (*float64, float64) {
+			return &m.ItemBinBias[r.item][r.bin], 1
+		})
+		m.fitTerm(ratings, residuals, lambda, func(r *timedRating) (*float64, float64) {
+			return &m.UserBias[r.user], 1
+		})
+		m.fitTerm(ratings, residuals, lambda, func(r *timedRating) (*float64, float64) {
+			return &m.UserDrift[r.user], r.dev
+		})
+		m.fitTerm(ratings, residuals, lambda, func(r *timedRating) (*float64, float64) {
+			return &m.UserBinBias[r.user][r.bin], 1
+		})
+	}
+	return m, nil
+}
+
+// Refits the parameter term returns for every rating, which enters the prediction multiplied by
+// the returned feature, to the ratings' residuals by damped least squares.
+func (m *TemporalModel) fitTerm(ratings []timedRating, residuals []float64, lambda float64, term func(r *timedRating) (*float64, float64)) {
+	for n := range ratings {
+		r := &ratings[n]
+		residuals[n] = r.value - m.predict(r.user, r.item, r.bin, r.dev)
+	}
+	num := make(map[*float64]float64)
+	den := make(map[*float64]float64)
+	for n := range ratings {
+		p, x := term(&ratings[n])
+		// residual of the rating without this term
+		num[p] += x * (residuals[n] + *p*x)
+		den[p] += x * x
+	}
+	for p := range num {
+		if den[p]+lambda > 0 {
+			*p = num[p] / (den[p] + lambda)
+		}
+	}
+}
+
+// time bin of t; times before Start fall into the first bin
+func (m *TemporalModel) bin(t float64) int {
+	if t <= m.Start {
+		return 0
+	}
+	return int((t - m.Start) / m.Period)
+}
+
+// drift of the user at time t, see TemporalModel
+func (m *TemporalModel) dev(user int, t float64) float64 {
+	d := (t - m.UserTime[user]) / m.Period
+	if d < 0 {
+		return -math.Pow(-d, driftExponent)
+	}
+	return math.Pow(d, driftExponent)
+}
+
+func (m *TemporalModel) predict(user, product, bin int, dev float64) float64 {
+	pred := m.Mean
+	if product >= 0 && product < len(m.ItemBias) {
+		pred += m.ItemBias[product]
+		if bin < len(m.ItemBinBias[product]) {
+			pred += m.ItemBinBias[product][bin]
+		}
+	}
+	if user >= 0 && user < len(m.UserBias) {
+		pred += m.UserBias[user] + m.UserDrift[user]*dev
+		if bin < len(m.UserBinBias[user]) {
+			pred += m.UserBinBias[user][bin]
+		}
+	}
+	return pred
+}
+
+// Returns the predicted rating of a user/product pair at time t. Times after the last bin get the
+// static biases and user drift only; unknown users/products get biases of 0, like Model.Predict.
+func (m *TemporalModel) Predict(user, product int, t float64) float64 {
+	dev := 0.0
+	if user >= 0 && user < len(m.UserBias) {
+		dev = m.dev(user, t)
+	}
+	return m.predict(user, product, m.bin(t), dev)
+}
@@ -0,0 +1,42 @@
+// Command recommend trains a recommender as described by a training config file, e.g.
+//
+//	recommend -config train.yaml
+//
+// See the training package for the config format.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/timkaye11/goRecommend/training"
+)
+
+func main() {
+	path := flag.String("config", "train.yaml", "JSON or YAML training config")
+	flag.Parse()
+
+	cfg, err := training.LoadConfig(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if cfg.Float32 {
+		model, err := training.Run32(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Trained float32 als model with %d users and %d products\n", model.Users, model.Products)
+		return
+	}
+	model, err := training.Run(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	meta := model.Metadata()
+	fmt.Printf("Trained %s model with %d users and %d products (fingerprint %s)\n",
+		meta.Algorithm, model.X.Rows(), model.Y.Cols(), meta.Fingerprint)
+}
@@ -3,7 +3,6 @@ package collabFilter
 
 import (
 	"errors"
-	"fmt"
 	"math"
 	"sort"
 	"strconv"
@@ -11,12 +10,6 @@ import (
 	. "github.com/skelterjohn/go.matrix"
 )
 
-func errcheck(err error) {
-	if err != nil {
-		fmt.Printf("\nError:  %v occured", err)
-	}
-}
-
 // Find the dot product between two vectors
 func DotProduct(a, b []float64) (float64, error) {
 	if len(a) != len(b) {
@@ -39,12 +32,18 @@ func NormSquared(a []float64) float64 {
 }
 
 // Cosine Similarity between two vectors
-// Returns cos similarity on a scale from 0 to 1.
+// Returns cos similarity on a scale from -1 to 1 (0 to 1 for non-negative ratings).
+// Vectors of different length, or without any non-zero values, have a similarity of 0.
 func CosineSim(a, b []float64) float64 {
 	dp, err := DotProduct(a, b)
-	errcheck(err)
+	if err != nil {
+		return 0
+	}
 	a_squared := NormSquared(a)
 	b_sqaured := NormSquared(b)
+	if a_squared == 0 || b_sqaured == 0 {
+		return 0
+	}
 	return dp / (a_squared * b_sqaured)
 }
 
@@ -77,7 +76,7 @@ func replaceNA(prefs *DenseMatrix) *DenseMatrix {
 
 // Gets Recommendations for a user (row index) based on the prefs matrix.
 // Uses cosine similarity for rating scale, and jaccard similarity if binary
-func GetRecommendations(prefs *DenseMatrix, user int, products []string) ([]string, []float64, error) {
+func GetRecommendations(prefs *DenseMatrix, user int, products []string, opts ...Option) ([]string, []float64, error) {
 	// make sure user is in the preference matrix
 	if user >= prefs.Rows() {
 		return nil, nil, errors.New("user index out of range")
@@ -88,12 +87,13 @@ func GetRecommendations(prefs *DenseMatrix, user int, products []string) ([]stri
 	sims := make(map[int]float64, 0)
 	// Get user row from prefs matrix
 	user_ratings := prefs.GetRowVector(user).Array()
+	// cosine similarity to every other user, computed in parallel
+	cos_sims := similarities(prefs.Arrays(), user, nil, newConfig(opts))
 	for i := 0; i < prefs.Rows(); i++ {
 		// don't compare row to itself.
 		if i != user {
-			// get cosine similarity for other scores.
 			other := prefs.GetRowVector(i).Array()
-			cos_sim := CosineSim(user_ratings, other)
+			cos_sim := cos_sims[i]
 			// get product recs for neighbors
 			for idx, val := range other {
 				if (user_ratings[idx] == 0 || math.IsNaN(user_ratings[idx])) && val != 0 {
@@ -118,7 +118,7 @@ func sum(x []float64) float64 {
 
 // Gets Recommendations for a user (row index) based on the prefs matrix.
 // Uses cosine similarity for rating scale, and jaccard similarity if binary
-func GetBinaryRecommendations(prefs *DenseMatrix, user int, products []string) ([]string, []float64, error) {
+func GetBinaryRecommendations(prefs *DenseMatrix, user int, products []string, opts ...Option) ([]string, []float64, error) {
 	// make sure user is in the preference matrix
 	if user >= prefs.Rows() {
 		return nil, nil, errors.New("user index out of range")
@@ -128,6 +128,8 @@ func GetBinaryRecommendations(prefs *DenseMatrix, user int, products []string) (
 	ratings := make(map[float64]string)
 	// Get user row from prefs matrix
 	user_ratings := prefs.GetRowVector(user).Array()
+	// jaccard similarity to every other user, computed in parallel
+	jaccards := similarities(prefs.Arrays(), user, Jaccard, newConfig(opts))
 
 	for ii := 0; ii < prefs.Cols(); ii++ {
 		if user_ratings[ii] == float64(0) {
@@ -137,11 +139,10 @@ func GetBinaryRecommendations(prefs *DenseMatrix, user int, products []string) (
 			jaccard_disliked := make([]float64, 0)
 			for i := 0; i < prefs.Rows(); i++ {
 				if i != user {
-					other := prefs.GetRowVector(i).Array()
-					if other[ii] == float64(0) {
-						jaccard_disliked = append(jaccard_disliked, Jaccard(user_ratings, other))
+					if prefs.Get(i, ii) == float64(0) {
+						jaccard_disliked = append(jaccard_disliked, jaccards[i])
 					} else {
-						jaccard_liked = append(jaccard_liked, Jaccard(user_ratings, other))
+						jaccard_liked = append(jaccard_liked, jaccards[i])
 					}
 				}
 			}
@@ -161,7 +162,6 @@ func calculateWeightedMean(ratings, sims map[int]float64, products []string) (re
 	recommendations := make(map[float64]string, 0)
 	for k, v := range ratings {
 		mean_product_rating := v / sims[k]
-		fmt.Println(mean_product_rating)
 		if products != nil {
 			recommendations[mean_product_rating] = products[k]
 		} else {
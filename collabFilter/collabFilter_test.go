@@ -2,6 +2,8 @@ package collabFilter
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"testing"
 )
 
@@ -27,6 +29,10 @@ func TestCosineSim(t *testing.T) {
 	cosine_sim := CosineSim(x, y)
 	// should be like 0.688.... Checked using R
 	Assert(t, cosine_sim > 0.687, cosine_sim < 0.689)
+
+	// no NaN for empty vectors or mismatched lengths
+	Assert(t, CosineSim(x, []float64{0, 0, 0, 0}) == 0)
+	Assert(t, CosineSim(x, []float64{1, 2}) == 0)
 }
 
 func TestJaccard(t *testing.T) {
@@ -76,3 +82,88 @@ func TestBinaryRecommendations(t *testing.T) {
 	Assert(t, scores[0] > 0.4, scores[1] < 0.3)
 
 }
+
+func TestItemNeighbors(t *testing.T) {
+	prefs := MakeRatingMatrix([]float64{
+		2, 3, 4, 1, 5,
+		3, 0, 3, 3, 0,
+		4, 4, 1, 2, 3,
+		2, 4, 0, 3, 4,
+		3, 1, 3, 0, 4}, 5, 5)
+
+	neighbors := ComputeItemNeighbors(prefs, 2, CosineSim)
+	for i := 0; i < 5; i++ {
+		items, sims := neighbors.Neighbors(i)
+		Assert(t, len(items) == 2 && sims[0] >= sims[1])
+
+		// the kept neighbors are the two most similar columns
+		best := make([]float64, 0)
+		for j := 0; j < 5; j++ {
+			if j != i {
+				best = append(best, CosineSim(prefs.ColCopy(i), prefs.ColCopy(j)))
+			}
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(best)))
+		Assert(t, sims[0] == best[0] && sims[1] == best[1], sims, best)
+		Assert(t, neighbors.Similarity(i, items[0]) == sims[0])
+	}
+
+	// user 1 only rated items 0, 2 and 3, all with a 3
+	pred := neighbors.Predict(prefs.RowCopy(1), 4)
+	Assert(t, pred == 0 || pred == 3, pred)
+}
+
+func TestSlopeOne(t *testing.T) {
+	prefs := MakeRatingMatrix([]float64{
+		5, 3, 2,
+		3, 4, 0,
+		0, 2, 5}, 3, 3)
+	s := NewSlopeOne(prefs)
+	Assert(t, s.Counts[0][1] == 2 && s.Diffs[0][1] == 1, s.Counts, s.Diffs)
+
+	// item 0 is rated 0.5 above item 1 by 2 users, and 3 above item 2 by 1 user:
+	// ((2 + 0.5) * 2 + (5 + 3) * 1) / 3
+	pred := s.Predict(prefs.RowCopy(2), 0)
+	Assert(t, math.Abs(pred-13.0/3) < 1e-12, pred)
+
+	// an online update gives the same deviations as recomputing from scratch
+	s.Update(prefs.RowCopy(1), 2, 4)
+	prefs.Set(1, 2, 4)
+	s.Update(prefs.RowCopy(0), 1, 1)
+	prefs.Set(0, 1, 1)
+	fresh := NewSlopeOne(prefs)
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 3; i++ {
+			Assert(t, s.Diffs[j][i] == fresh.Diffs[j][i] && s.Counts[j][i] == fresh.Counts[j][i], s.Diffs, fresh.Diffs)
+		}
+	}
+	Assert(t, s.Predict([]float64{0, 0, 0}, 0) == 0)
+}
+
+func TestParallelNeighbors(t *testing.T) {
+	// enough items to be split over several chunks
+	data := make([]float64, 10*200)
+	for i := range data {
+		data[i] = float64((i*7919)%5) * float64((i*31)%3)
+	}
+	prefs := MakeRatingMatrix(data, 10, 200)
+
+	serial := ComputeItemNeighbors(prefs, 5, CosineSim, Parallelism(1))
+	parallel := ComputeItemNeighbors(prefs, 5, nil, Parallelism(4))
+	for i := 0; i < 200; i++ {
+		Assert(t, len(serial.Sims[i]) == len(parallel.Sims[i]), i)
+		for n := range serial.Sims[i] {
+			Assert(t, math.Abs(serial.Sims[i][n]-parallel.Sims[i][n]) < 1e-12, i, serial.Sims[i], parallel.Sims[i])
+		}
+	}
+
+	users := ComputeUserNeighbors(prefs, 3, nil)
+	items, sims := users.Neighbors(0)
+	Assert(t, len(items) == 3 && items[0] != 0, items)
+	Assert(t, math.Abs(sims[0]-CosineSim(prefs.RowCopy(0), prefs.RowCopy(items[0]))) < 1e-12, sims)
+
+	prods, scores, _ := GetRecommendations(prefs, 1, nil, Parallelism(1))
+	prods2, scores2, _ := GetRecommendations(prefs, 1, nil, Parallelism(8))
+	// products with equal scores can come back in any order
+	Assert(t, len(prods) == len(prods2) && fmt.Sprint(scores) == fmt.Sprint(scores2), scores, scores2)
+}
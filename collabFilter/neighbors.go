@@ -0,0 +1,95 @@
+package collabFilter
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// Pruned item-item similarity matrix: only the k most similar neighbors of every item are kept,
+// in descending order of similarity, so memory grows with items*k instead of items^2.
+type ItemNeighbors struct {
+	K     int
+	Items [][]int
+	Sims  [][]float64
+}
+
+// Computes the similarity between every pair of items (columns of prefs) with the given similarity
+// function, e.g. CosineSim or Jaccard, keeping only the top k neighbors of each item. A nil similarity
+// is cosine similarity on pre-normalized columns, which is faster than passing CosineSim.
+// The work is spread over all CPUs, see Parallelism. Items with a similarity of 0 (or NaN) are never
+// kept as neighbors.
+func ComputeItemNeighbors(prefs *DenseMatrix, k int, similarity func(a, b []float64) float64, opts ...Option) *ItemNeighbors {
+	cfg := newConfig(opts)
+	prefs = replaceNA(prefs.Copy())
+	cols := make([][]float64, prefs.Cols())
+	for i := range cols {
+		cols[i] = prefs.ColCopy(i)
+	}
+	cols, similarity = prepare(cols, similarity)
+	neighbors := &ItemNeighbors{
+		K:     k,
+		Items: make([][]int, len(cols)),
+		Sims:  make([][]float64, len(cols)),
+	}
+	parallelFor(len(cols), cfg.parallelism, func(start, end int) {
+		for i := start; i < end; i++ {
+			neighbors.Items[i], neighbors.Sims[i] = topNeighbors(cols, i, k, similarity)
+		}
+	})
+	return neighbors
+}
+
+// Like ComputeItemNeighbors, but between users (rows of prefs): Items then holds user indices.
+// Predict with a product's column of ratings gives the user-based KNN prediction for a user,
+// e.g. neighbors.Predict(prefs.ColCopy(product), user).
+func ComputeUserNeighbors(prefs *DenseMatrix, k int, similarity func(a, b []float64) float64, opts ...Option) *ItemNeighbors {
+	return ComputeItemNeighbors(prefs.Transpose(), k, similarity, opts...)
+}
+
+// the k items most similar to item i
+func topNeighbors(cols [][]float64, i, k int, similarity func(a, b []float64) float64) ([]int, []float64) {
+	sims := make([]float64, len(cols))
+	for j := range cols {
+		sims[j] = similarity(cols[i], cols[j])
+		// never a neighbor of itself, or of items it has nothing in common with
+		if j == i || sims[j] == 0 {
+			sims[j] = math.NaN()
+		}
+	}
+	return topk.Split(topk.TopK(sims, k))
+}
+
+// Returns the kept neighbors of an item and their similarities, most similar first.
+func (n *ItemNeighbors) Neighbors(item int) ([]int, []float64) {
+	return n.Items[item], n.Sims[item]
+}
+
+// Returns the similarity between two items, or 0 if b is not among a's top k neighbors.
+func (n *ItemNeighbors) Similarity(a, b int) float64 {
+	for idx, item := range n.Items[a] {
+		if item == b {
+			return n.Sims[a][idx]
+		}
+	}
+	return 0
+}
+
+// Item-based KNN prediction of a user's rating for item: the similarity weighted mean of the user's
+// ratings (0 or NaN for not rated) of the item's neighbors. Returns 0 if the user has rated none of them.
+func (n *ItemNeighbors) Predict(ratings []float64, item int) float64 {
+	weighted, sims := 0.0, 0.0
+	for idx, other := range n.Items[item] {
+		r := ratings[other]
+		if r == 0 || math.IsNaN(r) {
+			continue
+		}
+		weighted += n.Sims[item][idx] * r
+		sims += math.Abs(n.Sims[item][idx])
+	}
+	if sims == 0 {
+		return 0
+	}
+	return weighted / sims
+}
@@ -0,0 +1,95 @@
+package collabFilter
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Option configures optional behaviour of the neighborhood methods.
+type Option func(*config)
+
+type config struct {
+	// number of goroutines computing similarities
+	parallelism int
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{parallelism: runtime.NumCPU()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Number of goroutines used to compute similarities. Defaults to the number of CPUs;
+// 1 computes everything on the calling goroutine.
+func Parallelism(n int) Option {
+	return func(c *config) {
+		c.parallelism = n
+	}
+}
+
+// number of vectors handed to a worker at a time
+const similarityChunk = 64
+
+// calls fn on consecutive chunks of [0, n), on at most workers goroutines
+func parallelFor(n, workers int, fn func(start, end int)) {
+	if workers <= 1 || n <= similarityChunk {
+		fn(0, n)
+		return
+	}
+	chunks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := start + similarityChunk
+				if end > n {
+					end = n
+				}
+				fn(start, end)
+			}
+		}()
+	}
+	for start := 0; start < n; start += similarityChunk {
+		chunks <- start
+	}
+	close(chunks)
+	wg.Wait()
+}
+
+// Returns the vectors and similarity function to compare them with. A nil similarity means cosine
+// similarity, computed as the dot product of unit length copies of the vectors so the norms are
+// only computed once per vector instead of once per pair.
+func prepare(vectors [][]float64, similarity func(a, b []float64) float64) ([][]float64, func(a, b []float64) float64) {
+	if similarity != nil {
+		return vectors, similarity
+	}
+	unit := make([][]float64, len(vectors))
+	for i, v := range vectors {
+		unit[i] = make([]float64, len(v))
+		if norm := NormSquared(v); norm != 0 {
+			for j, x := range v {
+				unit[i][j] = x / norm
+			}
+		}
+	}
+	return unit, func(a, b []float64) float64 {
+		dp, _ := DotProduct(a, b)
+		return dp
+	}
+}
+
+// similarity of vectors[which] to every vector, itself included
+func similarities(vectors [][]float64, which int, similarity func(a, b []float64) float64, cfg *config) []float64 {
+	vectors, similarity = prepare(vectors, similarity)
+	sims := make([]float64, len(vectors))
+	parallelFor(len(vectors), cfg.parallelism, func(start, end int) {
+		for i := start; i < end; i++ {
+			sims[i] = similarity(vectors[which], vectors[i])
+		}
+	})
+	return sims
+}
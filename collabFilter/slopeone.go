@@ -0,0 +1,87 @@
+package collabFilter
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Weighted Slope One predictor for explicit ratings. For every pair of items it keeps the sum of
+// rating differences over the users who rated both, and how many did, so predictions are just
+// averages of "item j is rated this much higher than item i" and new ratings can be absorbed online.
+// Memory grows with the square of the number of items.
+type SlopeOne struct {
+	// Diffs[j][i] is the sum of r_j - r_i and Counts[j][i] the number of users who rated both
+	Diffs, Counts [][]float64
+}
+
+func rated(r float64) bool {
+	return r != 0 && !math.IsNaN(r)
+}
+
+// Computes the deviations from a user/product rating matrix. 0 or NaN means not rated.
+func NewSlopeOne(prefs *DenseMatrix) *SlopeOne {
+	n := prefs.Cols()
+	s := &SlopeOne{Diffs: make([][]float64, n), Counts: make([][]float64, n)}
+	for j := range s.Diffs {
+		s.Diffs[j] = make([]float64, n)
+		s.Counts[j] = make([]float64, n)
+	}
+	for u := 0; u < prefs.Rows(); u++ {
+		ratings := prefs.RowCopy(u)
+		for j, rj := range ratings {
+			if !rated(rj) {
+				continue
+			}
+			for i, ri := range ratings {
+				if i != j && rated(ri) {
+					s.Diffs[j][i] += rj - ri
+					s.Counts[j][i]++
+				}
+			}
+		}
+	}
+	return s
+}
+
+// Absorbs a user's new rating of an item. ratings are the user's ratings before the change, so a
+// previous rating of the item is replaced; a rating of 0 just removes it. The caller still needs to
+// store the rating wherever the user's ratings are kept.
+func (s *SlopeOne) Update(ratings []float64, item int, rating float64) {
+	old := ratings[item]
+	for i, ri := range ratings {
+		if i == item || !rated(ri) {
+			continue
+		}
+		if rated(old) {
+			s.Diffs[item][i] -= old - ri
+			s.Diffs[i][item] -= ri - old
+			s.Counts[item][i]--
+			s.Counts[i][item]--
+		}
+		if rated(rating) {
+			s.Diffs[item][i] += rating - ri
+			s.Diffs[i][item] += ri - rating
+			s.Counts[item][i]++
+			s.Counts[i][item]++
+		}
+	}
+}
+
+// Predicts a user's rating of item from their ratings (0 or NaN for not rated): the mean of
+// rating + deviation over the items the user rated, weighted by how many users rated both.
+// Returns 0 if no rated item was ever rated together with it.
+func (s *SlopeOne) Predict(ratings []float64, item int) float64 {
+	total, count := 0.0, 0.0
+	for i, ri := range ratings {
+		if i == item || !rated(ri) || s.Counts[item][i] == 0 {
+			continue
+		}
+		total += s.Diffs[item][i] + ri*s.Counts[item][i]
+		count += s.Counts[item][i]
+	}
+	if count == 0 {
+		return 0
+	}
+	return total / count
+}
@@ -1,7 +1,7 @@
 package collabFilter
 
 import (
-	"fmt"
+	"errors"
 	"io/ioutil"
 	"strconv"
 	"strings"
@@ -32,10 +32,12 @@ func min(vals []int) int {
 // read file with separator and load into a matrix.
 // If user/product ID's start at 1, set first product/user at row/col index 0.
 // Already tested in ALS package
-func Load(path, sep string) *DenseMatrix {
+func Load(path, sep string) (*DenseMatrix, error) {
 	// read in the file
 	f, err := ioutil.ReadFile(path)
-	errcheck(err)
+	if err != nil {
+		return nil, err
+	}
 	lines := strings.Split(string(f), "\n")
 
 	// determine the number of rows and columns
@@ -44,6 +46,9 @@ func Load(path, sep string) *DenseMatrix {
 	for _, line := range lines {
 		values := strings.Split(line, sep)
 		if line != "" {
+			if len(values) < 3 {
+				return nil, errors.New("Line needs a user, product and rating: " + line)
+			}
 			row, _ := strconv.Atoi(values[0])
 			col, _ := strconv.Atoi(values[1])
 			row_count = append(row_count, row)
@@ -55,7 +60,6 @@ func Load(path, sep string) *DenseMatrix {
 	// and set the values accordingly
 	for _, line := range lines {
 		values := strings.Split(line, sep)
-		fmt.Println(values)
 		if line != "" {
 			row, _ := strconv.Atoi(values[0])
 			col, _ := strconv.Atoi(values[1])
@@ -67,5 +71,5 @@ func Load(path, sep string) *DenseMatrix {
 			}
 		}
 	}
-	return mat
+	return mat, nil
 }
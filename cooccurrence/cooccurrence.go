@@ -0,0 +1,137 @@
+// Co-occurrence and association rule recommendations in Go
+package cooccurrence
+
+import (
+	"math"
+	"sort"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// An association rule "baskets with Antecedent also contain Consequent".
+// Support is the fraction of baskets containing both items, Confidence the fraction of
+// baskets with the antecedent that also have the consequent, and Lift the confidence
+// divided by the consequent's own frequency (above 1 means they go together more than by chance).
+type Rule struct {
+	Antecedent, Consequent int
+	Support                float64
+	Confidence             float64
+	Lift                   float64
+}
+
+// Item and item pair counts over a set of baskets (orders, sessions, or users' histories).
+// Rules below any of the thresholds are not used for recommendations.
+type Model struct {
+	MinSupport, MinConfidence, MinLift float64
+
+	baskets float64
+	counts  map[int]float64
+	pairs   map[int]map[int]float64
+}
+
+// Counts the items and item pairs of the baskets. Repeated items within a basket count once.
+func Fit(baskets [][]int) *Model {
+	m := &Model{counts: make(map[int]float64), pairs: make(map[int]map[int]float64)}
+	for _, b := range baskets {
+		m.Add(b)
+	}
+	return m
+}
+
+// Adds the counts of a single basket, so the model can be kept up to date as orders come in.
+func (m *Model) Add(basket []int) {
+	seen := make(map[int]bool, len(basket))
+	items := make([]int, 0, len(basket))
+	for _, i := range basket {
+		if !seen[i] {
+			seen[i] = true
+			items = append(items, i)
+		}
+	}
+	m.baskets++
+	for _, a := range items {
+		m.counts[a]++
+		if m.pairs[a] == nil {
+			m.pairs[a] = make(map[int]float64)
+		}
+		for _, b := range items {
+			if a != b {
+				m.pairs[a][b]++
+			}
+		}
+	}
+}
+
+// Returns one basket per row of R, holding the columns with a non-zero (and non-NaN) value,
+// e.g. to mine rules from the same user/product matrix the other recommenders train on.
+func BasketsFromMatrix(R *DenseMatrix) [][]int {
+	baskets := make([][]int, R.Rows())
+	for u := range baskets {
+		for i, r := range R.RowCopy(u) {
+			if r != 0 && !math.IsNaN(r) {
+				baskets[u] = append(baskets[u], i)
+			}
+		}
+	}
+	return baskets
+}
+
+// Returns the rule a => b, whether or not it passes the thresholds.
+func (m *Model) Rule(a, b int) Rule {
+	rule := Rule{Antecedent: a, Consequent: b}
+	both := m.pairs[a][b]
+	if both == 0 {
+		return rule
+	}
+	rule.Support = both / m.baskets
+	rule.Confidence = both / m.counts[a]
+	rule.Lift = rule.Confidence / (m.counts[b] / m.baskets)
+	return rule
+}
+
+func (m *Model) passes(r Rule) bool {
+	return r.Support > 0 && r.Support >= m.MinSupport && r.Confidence >= m.MinConfidence && r.Lift >= m.MinLift
+}
+
+// Returns the rules with the item as antecedent that pass the thresholds, in descending order
+// of confidence (ties by consequent).
+func (m *Model) Rules(item int) []Rule {
+	var rules []Rule
+	for b := range m.pairs[item] {
+		if r := m.Rule(item, b); m.passes(r) {
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(x, y int) bool {
+		if rules[x].Confidence != rules[y].Confidence {
+			return rules[x].Confidence > rules[y].Confidence
+		}
+		return rules[x].Consequent < rules[y].Consequent
+	})
+	return rules
+}
+
+// Returns up to n items to recommend for a basket (or a user's history), in descending order
+// of score: the highest confidence of any passing rule from an item of the basket. Items already in
+// the basket are skipped. Being cheap, this makes a good candidate generator whose results are then
+// re-scored by a matrix factorization model, e.g. with ALS's PredictPairs.
+func (m *Model) Recommend(basket []int, n int) ([]int, []float64) {
+	in := make(map[int]bool, len(basket))
+	for _, i := range basket {
+		in[i] = true
+	}
+	scores := make(map[int]float64)
+	for a := range in {
+		for _, r := range m.Rules(a) {
+			if !in[r.Consequent] && r.Confidence > scores[r.Consequent] {
+				scores[r.Consequent] = r.Confidence
+			}
+		}
+	}
+	items := make([]int, 0, len(scores))
+	for i := range scores {
+		items = append(items, i)
+	}
+	return topk.Split(topk.Select(items, n, func(i int) float64 { return scores[i] }))
+}
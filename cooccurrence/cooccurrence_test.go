@@ -0,0 +1,51 @@
+package cooccurrence
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestRules(t *testing.T) {
+	R := MakeDenseMatrixStacked([][]float64{
+		{1, 1, 0, 0},
+		{1, 1, 1, 0},
+		{1, 0, 0, 1},
+		{0, 1, 1, 0},
+	})
+	baskets := BasketsFromMatrix(R)
+	Assert(t, len(baskets) == 4 && len(baskets[1]) == 3, baskets)
+
+	m := Fit(baskets)
+	r := m.Rule(0, 1)
+	Assert(t, r.Support == 0.5, r)
+	Assert(t, math.Abs(r.Confidence-2.0/3) < 1e-12, r)
+	Assert(t, math.Abs(r.Lift-(2.0/3)/0.75) < 1e-12, r)
+	Assert(t, m.Rule(0, 0).Support == 0 && m.Rule(2, 3).Confidence == 0)
+
+	rules := m.Rules(1)
+	Assert(t, len(rules) == 2 && rules[0].Confidence >= rules[1].Confidence, rules)
+
+	items, scores := m.Recommend([]int{0}, 5)
+	Assert(t, len(items) == 3 && items[0] == 1, items, scores)
+
+	// thresholds prune the weak rules
+	m.MinSupport = 0.5
+	items, _ = m.Recommend([]int{0}, 5)
+	Assert(t, len(items) == 1 && items[0] == 1, items)
+	m.MinSupport, m.MinLift = 0, 1.1
+	for _, r := range m.Rules(0) {
+		Assert(t, r.Lift >= 1.1, r)
+	}
+
+	// a basket counts each item once
+	m = Fit([][]int{{0, 0, 1}})
+	Assert(t, m.Rule(0, 1).Confidence == 1, m.Rule(0, 1))
+}
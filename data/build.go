@@ -0,0 +1,84 @@
+package data
+
+// How NewDataset resolves several ratings of the same item by the same user.
+type Duplicates int
+
+const (
+	// keep the last rating in the input, e.g. when a user changed their mind
+	KeepLatest Duplicates = iota
+	// keep the first rating in the input
+	KeepFirst
+	// the mean of all the ratings
+	KeepMean
+	// the sum of all the ratings, e.g. for interaction counts
+	KeepSum
+)
+
+// Option configures optional behaviour of NewDataset.
+type Option func(*config)
+
+type config struct {
+	duplicates Duplicates
+}
+
+// Resolve duplicate ratings of a user/item pair with d instead of keeping the latest one.
+func WithDuplicates(d Duplicates) Option {
+	return func(c *config) {
+		c.duplicates = d
+	}
+}
+
+// Builds a dataset from ratings already in memory, with exactly one entry per user/item pair:
+// duplicates are resolved as set with WithDuplicates (the latest rating wins by default). Users and
+// items are indexed in the order they are first seen, and entries are in the order their pair is.
+func NewDataset(triples []Rating, opts ...Option) *Dataset {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	type pair struct{ user, item int }
+	d := &Dataset{}
+	entries := make(map[pair]int)
+	counts := make([]float64, 0)
+	for _, r := range triples {
+		p := pair{d.users.add(r.User), d.items.add(r.Item)}
+		n, ok := entries[p]
+		if !ok {
+			entries[p] = len(d.Entries)
+			d.Entries = append(d.Entries, Entry{User: p.user, Item: p.item, Value: r.Value})
+			counts = append(counts, 1)
+			continue
+		}
+		counts[n]++
+		switch cfg.duplicates {
+		case KeepLatest:
+			d.Entries[n].Value = r.Value
+		case KeepMean, KeepSum:
+			d.Entries[n].Value += r.Value
+		}
+	}
+	if cfg.duplicates == KeepMean {
+		for n := range d.Entries {
+			d.Entries[n].Value /= counts[n]
+		}
+	}
+	return d
+}
+
+// Returns the indices into Entries of every user's ratings, one slice per user.
+func (d *Dataset) ByUser() [][]int {
+	byUser := make([][]int, d.Users())
+	for n, e := range d.Entries {
+		byUser[e.User] = append(byUser[e.User], n)
+	}
+	return byUser
+}
+
+// Returns the indices into Entries of every item's ratings, one slice per item.
+func (d *Dataset) ByItem() [][]int {
+	byItem := make([][]int, d.Items())
+	for n, e := range d.Entries {
+		byItem[e.Item] = append(byItem[e.Item], n)
+	}
+	return byItem
+}
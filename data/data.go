@@ -0,0 +1,108 @@
+// Sparse rating datasets keyed by external user/item IDs, for building the matrices the recommenders train on
+package data
+
+import (
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// A single rating (or interaction count) of an item by a user, keyed by their external IDs.
+type Rating struct {
+	User, Item string
+	Value      float64
+}
+
+// A rating of an item by a user, by their indices in the Dataset.
+type Entry struct {
+	User, Item int
+	Value      float64
+}
+
+// Maps external IDs to dense indices 0, 1, 2, ... in the order they were first seen.
+type index struct {
+	ids     []string
+	indices map[string]int
+}
+
+func (ix *index) add(id string) int {
+	if i, ok := ix.indices[id]; ok {
+		return i
+	}
+	if ix.indices == nil {
+		ix.indices = make(map[string]int)
+	}
+	ix.indices[id] = len(ix.ids)
+	ix.ids = append(ix.ids, id)
+	return len(ix.ids) - 1
+}
+
+func (ix *index) lookup(id string) (int, bool) {
+	i, ok := ix.indices[id]
+	return i, ok
+}
+
+// A sparse set of ratings, built up incrementally with Add. Users and items get the rows and
+// columns of the rating matrix in the order they are first seen. The zero value is an empty dataset.
+type Dataset struct {
+	Entries      []Entry
+	users, items index
+}
+
+// Adds a rating, registering its user and item if they are new.
+func (d *Dataset) Add(r Rating) {
+	d.Entries = append(d.Entries, Entry{
+		User:  d.users.add(r.User),
+		Item:  d.items.add(r.Item),
+		Value: r.Value,
+	})
+}
+
+// Number of distinct users.
+func (d *Dataset) Users() int {
+	return len(d.users.ids)
+}
+
+// Number of distinct items.
+func (d *Dataset) Items() int {
+	return len(d.items.ids)
+}
+
+// Returns the row index of a user ID, and whether the user is known.
+func (d *Dataset) UserIndex(id string) (int, bool) {
+	return d.users.lookup(id)
+}
+
+// Returns the column index of an item ID, and whether the item is known.
+func (d *Dataset) ItemIndex(id string) (int, bool) {
+	return d.items.lookup(id)
+}
+
+// Returns the external ID of the user in the given row.
+func (d *Dataset) UserID(user int) string {
+	return d.users.ids[user]
+}
+
+// Returns the external ID of the item in the given column.
+func (d *Dataset) ItemID(item int) string {
+	return d.items.ids[item]
+}
+
+// Builds the dense user/item rating matrix the trainers take, with 0 for unrated items.
+// If a user rated an item more than once, the last rating wins. When 0 is a legitimate rating,
+// pass Observed to the trainer as well (ALS.WithObserved), so rated 0s are not taken as missing.
+func (d *Dataset) Matrix() *DenseMatrix {
+	Q := Zeros(d.Users(), d.Items())
+	for _, e := range d.Entries {
+		Q.Set(e.User, e.Item, e.Value)
+	}
+	return Q
+}
+
+// Returns a user/item matrix with a 1 for every rated (user, item) pair and 0 elsewhere, which says
+// explicitly what Matrix leaves ambiguous: whether a 0 is a rating or no rating at all.
+func (d *Dataset) Observed() *DenseMatrix {
+	mask := Zeros(d.Users(), d.Items())
+	for _, e := range d.Entries {
+		mask.Set(e.User, e.Item, 1)
+	}
+	return mask
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"math"
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestDataset(t *testing.T) {
+	var d Dataset
+	d.Add(Rating{"alice", "spoon", 5})
+	d.Add(Rating{"bob", "kanye", 3})
+	d.Add(Rating{"alice", "kanye", 4})
+	d.Add(Rating{"alice", "spoon", 2})
+
+	Assert(t, d.Users() == 2 && d.Items() == 2 && len(d.Entries) == 4)
+	u, ok := d.UserIndex("bob")
+	Assert(t, ok && u == 1 && d.UserID(u) == "bob")
+	i, ok := d.ItemIndex("kanye")
+	Assert(t, ok && i == 1 && d.ItemID(i) == "kanye")
+	_, ok = d.ItemIndex("macy gray")
+	Assert(t, !ok)
+
+	Q := d.Matrix()
+	Assert(t, Q.Get(0, 0) == 2 && Q.Get(0, 1) == 4 && Q.Get(1, 0) == 0 && Q.Get(1, 1) == 3, Q)
+
+	// a rating of 0 is observed, an unrated pair is not
+	d.Add(Rating{"bob", "macy gray", 0})
+	mask := d.Observed()
+	Assert(t, mask.Get(1, 2) == 1 && mask.Get(0, 2) == 0 && mask.Get(1, 0) == 0 && mask.Get(0, 0) == 1, mask)
+}
+
+func TestEventWeights(t *testing.T) {
+	events := []Event{
+		{"alice", "spoon", "view"},
+		{"alice", "spoon", "add-to-cart"},
+		{"bob", "kanye", "purchase"},
+		{"alice", "spoon", "purchase"},
+	}
+	for n := 0; n < 20; n++ {
+		events = append(events, Event{"bob", "spoon", "view"})
+	}
+	w := DefaultEventWeights()
+	d, err := w.Dataset(events)
+	Assert(t, err == nil, err)
+	Assert(t, d.Users() == 2 && d.Items() == 2 && len(d.Entries) == 3, d.Entries)
+	Q := d.Matrix()
+	// 1 + 3 + 5, a purchase, and 5 capped views
+	Assert(t, Q.Get(0, 0) == 9 && Q.Get(1, 1) == 5 && Q.Get(1, 0) == 5, Q)
+
+	w.MaxRepeats, w.LogScale = 0, true
+	d, _ = w.Dataset(events)
+	Assert(t, math.Abs(d.Matrix().Get(1, 0)-math.Log(21)) < 1e-12, d.Matrix())
+
+	_, err = w.Dataset([]Event{{"alice", "spoon", "wishlist"}})
+	Assert(t, err != nil)
+}
+
+func TestNewDataset(t *testing.T) {
+	triples := []Rating{
+		{"alice", "spoon", 5},
+		{"bob", "kanye", 3},
+		{"alice", "spoon", 1},
+		{"bob", "spoon", 4},
+		{"alice", "spoon", 3},
+	}
+	d := NewDataset(triples)
+	Assert(t, d.Users() == 2 && d.Items() == 2 && len(d.Entries) == 3, d.Entries)
+	Assert(t, d.Entries[0] == Entry{0, 0, 3} && d.Entries[2] == Entry{1, 0, 4}, d.Entries)
+
+	for policy, want := range map[Duplicates]float64{KeepFirst: 5, KeepMean: 3, KeepSum: 9} {
+		d = NewDataset(triples, WithDuplicates(policy))
+		Assert(t, d.Entries[0].Value == want && len(d.Entries) == 3, policy, d.Entries)
+	}
+
+	byUser, byItem := d.ByUser(), d.ByItem()
+	Assert(t, len(byUser) == 2 && len(byUser[1]) == 2 && byUser[1][0] == 1, byUser)
+	Assert(t, len(byItem) == 2 && len(byItem[0]) == 2 && byItem[0][1] == 2, byItem)
+	Assert(t, len(NewDataset(nil).Entries) == 0)
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"errors"
+	"math"
+)
+
+// A raw interaction from an event log, e.g. {User: "alice", Item: "spoon", Type: "purchase"}.
+type Event struct {
+	User, Item, Type string
+}
+
+// Turns raw events into implicit ratings (confidences). Every event type has a weight, and the
+// weights of all events of a user/item pair are added up, counting each type at most MaxRepeats
+// times (0 for no cap), so a bot viewing an item a thousand times doesn't drown out a purchase.
+// With LogScale, the sum s becomes log(1 + s), which flattens heavy users further.
+type EventWeights struct {
+	Weights    map[string]float64
+	MaxRepeats int
+	LogScale   bool
+}
+
+// Common weights for e-commerce logs: view = 1, add-to-cart = 3, purchase = 5, with at most 5
+// repeats of each type counted and no log scaling.
+func DefaultEventWeights() *EventWeights {
+	return &EventWeights{
+		Weights:    map[string]float64{"view": 1, "add-to-cart": 3, "purchase": 5},
+		MaxRepeats: 5,
+	}
+}
+
+// Builds a dataset with one implicit rating per user/item pair from the events, users and items
+// indexed in the order they are first seen. Fails on event types without a weight.
+func (w *EventWeights) Dataset(events []Event) (*Dataset, error) {
+	type pair struct{ user, item int }
+	d := &Dataset{}
+	entries := make(map[pair]int)
+	counts := make(map[pair]map[string]int)
+	for _, e := range events {
+		weight, ok := w.Weights[e.Type]
+		if !ok {
+			return nil, errors.New("No weight for event type " + e.Type)
+		}
+		p := pair{d.users.add(e.User), d.items.add(e.Item)}
+		n, ok := entries[p]
+		if !ok {
+			n = len(d.Entries)
+			entries[p] = n
+			counts[p] = make(map[string]int)
+			d.Entries = append(d.Entries, Entry{User: p.user, Item: p.item})
+		}
+		if w.MaxRepeats > 0 && counts[p][e.Type] >= w.MaxRepeats {
+			continue
+		}
+		counts[p][e.Type]++
+		d.Entries[n].Value += weight
+	}
+	if w.LogScale {
+		for n := range d.Entries {
+			d.Entries[n].Value = math.Log1p(d.Entries[n].Value)
+		}
+	}
+	return d, nil
+}
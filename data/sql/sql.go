@@ -0,0 +1,38 @@
+// Loads ratings from a database/sql source
+package sql
+
+import (
+	dbsql "database/sql"
+
+	"github.com/timkaye11/goRecommend/data"
+)
+
+// Runs the query against db and streams the resulting rows into a new Dataset.
+// The query must return three columns: the user ID, the item ID, and the rating, e.g.
+// "SELECT user_id, product_id, rating FROM ratings WHERE created_at > ?". IDs can be of any
+// type that scans into a string. Rows are added one by one, so only the sparse ratings are kept in memory.
+func Load(db *dbsql.DB, query string, args ...interface{}) (*data.Dataset, error) {
+	d := &data.Dataset{}
+	if err := LoadInto(d, db, query, args...); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Like Load, but adds the rows to an existing Dataset, e.g. to combine several tables or
+// to fetch only ratings newer than the last load.
+func LoadInto(d *data.Dataset, db *dbsql.DB, query string, args ...interface{}) error {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r data.Rating
+		if err := rows.Scan(&r.User, &r.Item, &r.Value); err != nil {
+			return err
+		}
+		d.Add(r)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,64 @@
+package sql
+
+import (
+	dbsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+// a minimal driver serving a fixed ratings table for any query
+type fakeDriver struct{}
+type fakeConn struct{}
+type fakeStmt struct{}
+type fakeRows struct{ next int }
+
+var table = [][]driver.Value{
+	{int64(1), "spoon", 5.0},
+	{int64(2), "kanye", int64(3)},
+	{int64(1), "kanye", 4.0},
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error)         { return fakeConn{}, nil }
+func (fakeConn) Prepare(query string) (driver.Stmt, error)       { return fakeStmt{}, nil }
+func (fakeConn) Close() error                                    { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                       { return nil, errors.New("no transactions") }
+func (fakeStmt) Close() error                                    { return nil }
+func (fakeStmt) NumInput() int                                   { return -1 }
+func (fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, errors.New("read only") }
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error)  { return &fakeRows{}, nil }
+func (*fakeRows) Columns() []string                              { return []string{"user_id", "product_id", "rating"} }
+func (*fakeRows) Close() error                                   { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next == len(table) {
+		return io.EOF
+	}
+	copy(dest, table[r.next])
+	r.next++
+	return nil
+}
+
+func init() {
+	dbsql.Register("fake", fakeDriver{})
+}
+
+func TestLoad(t *testing.T) {
+	db, _ := dbsql.Open("fake", "")
+	d, err := Load(db, "SELECT user_id, product_id, rating FROM ratings")
+	Assert(t, err == nil, err)
+	Assert(t, d.Users() == 2 && d.Items() == 2)
+
+	u, _ := d.UserIndex("1")
+	i, _ := d.ItemIndex("kanye")
+	Assert(t, d.Matrix().Get(u, i) == 4)
+
+	Assert(t, LoadInto(d, db, "SELECT user_id, product_id, rating FROM ratings") == nil)
+	Assert(t, len(d.Entries) == 6 && d.Users() == 2)
+}
@@ -0,0 +1,46 @@
+package evaluation
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Returns the (user, product) pairs the user has not rated in R (0 or NaN), ready for
+// Model.PredictPairs, e.g. to score the full catalog for top-N evaluation. If limit > 0 and the
+// user has more unrated products, limit of them are sampled uniformly without replacement using
+// rng, which keeps huge catalogs tractable. The pairs are in product order.
+func UserAntiTestset(R *DenseMatrix, user, limit int, rng *rand.Rand) [][2]int {
+	unrated := make([]int, 0)
+	for i, val := range R.RowCopy(user) {
+		if val == 0 || math.IsNaN(val) {
+			unrated = append(unrated, i)
+		}
+	}
+	if limit > 0 && len(unrated) > limit {
+		// partial Fisher-Yates shuffle, then put the sample back in product order
+		for n := 0; n < limit; n++ {
+			j := n + rng.Intn(len(unrated)-n)
+			unrated[n], unrated[j] = unrated[j], unrated[n]
+		}
+		unrated = unrated[:limit]
+		sort.Ints(unrated)
+	}
+	pairs := make([][2]int, len(unrated))
+	for n, i := range unrated {
+		pairs[n] = [2]int{user, i}
+	}
+	return pairs
+}
+
+// Returns the unrated (user, product) pairs of every user of R, with at most limit per user
+// if limit > 0, see UserAntiTestset.
+func AntiTestset(R *DenseMatrix, limit int, rng *rand.Rand) [][2]int {
+	pairs := make([][2]int, 0)
+	for u := 0; u < R.Rows(); u++ {
+		pairs = append(pairs, UserAntiTestset(R, u, limit, rng)...)
+	}
+	return pairs
+}
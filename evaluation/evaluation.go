@@ -0,0 +1,100 @@
+// Evaluation metrics for recommendation algorithms in Go
+//
+// The rating matrices taken here only say which pairs were rated, by being nonzero and not NaN.
+// When 0 is a valid rating, pass the observed mask (e.g. data.Dataset's Observed) in their place:
+// LeaveOneOut then splits the mask, and the training matrix it returns is the mask to train on
+// with ALS.WithObserved.
+package evaluation
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Beyond-accuracy metrics of top-N lists. A low RMSE says nothing about whether a model only
+// ever recommends the same handful of blockbusters; these metrics do.
+// lists[u] holds the products recommended to user u.
+
+// Returns the fraction of users that interacted with each product of the user/product matrix R,
+// where 0 or NaN means no interaction.
+func Popularity(R *DenseMatrix) []float64 {
+	pop := make([]float64, R.Cols())
+	if R.Rows() == 0 {
+		return pop
+	}
+	for u := 0; u < R.Rows(); u++ {
+		for i, val := range R.RowCopy(u) {
+			if val != 0 && !math.IsNaN(val) {
+				pop[i]++
+			}
+		}
+	}
+	for i := range pop {
+		pop[i] /= float64(R.Rows())
+	}
+	return pop
+}
+
+// Mean self-information -log2(popularity) of the recommended products, in bits, with popularity
+// measured on the training matrix R. Higher means less obvious recommendations. Products nobody
+// interacted with count as if a single user had.
+func Novelty(lists [][]int, R *DenseMatrix) float64 {
+	pop := Popularity(R)
+	floor := 1 / float64(R.Rows())
+	sum, count := 0.0, 0
+	for _, list := range lists {
+		for _, i := range list {
+			sum -= math.Log2(math.Max(pop[i], floor))
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return sum / float64(count)
+}
+
+// Fraction of the recommendations that are both relevant (nonzero in the held out matrix T) and
+// unexpected (missing from the primitive recommender's list for that user, e.g. the most
+// popular products from baseline.Model.MostPopular), averaged over users with recommendations.
+func Serendipity(lists, expected [][]int, T *DenseMatrix) float64 {
+	sum, users := 0.0, 0
+	for u, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
+		obvious := make(map[int]bool)
+		if u < len(expected) {
+			for _, i := range expected[u] {
+				obvious[i] = true
+			}
+		}
+		hits := 0
+		for _, i := range list {
+			if val := T.Get(u, i); val != 0 && !math.IsNaN(val) && !obvious[i] {
+				hits++
+			}
+		}
+		sum += float64(hits) / float64(len(list))
+		users++
+	}
+	if users == 0 {
+		return 0
+	}
+	return sum / float64(users)
+}
+
+// Fraction of the catalog of n_products that shows up in at least one list.
+func Coverage(lists [][]int, n_products int) float64 {
+	if n_products == 0 {
+		return 0
+	}
+	seen := make(map[int]bool)
+	for _, list := range lists {
+		for _, i := range list {
+			seen[i] = true
+		}
+	}
+	return float64(len(seen)) / float64(n_products)
+}
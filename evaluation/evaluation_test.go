@@ -0,0 +1,152 @@
+package evaluation
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/sampling"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+var R = MakeDenseMatrix([]float64{
+	5, 4, 0, 0,
+	3, 0, 0, 0,
+	4, 5, 1, 0,
+	2, 0, 0, 0}, 4, 4)
+
+func TestNovelty(t *testing.T) {
+	pop := Popularity(R)
+	Assert(t, pop[0] == 1 && pop[1] == 0.5 && pop[3] == 0, pop)
+
+	// everybody has product 0: no information
+	Assert(t, Novelty([][]int{{0}, {0}}, R) == 0)
+	// 1 bit for product 1, 2 bits each for products 2 and 3
+	Assert(t, math.Abs(Novelty([][]int{{1, 2}, {3}}, R)-5.0/3) < 1e-9)
+}
+
+func TestSerendipity(t *testing.T) {
+	T := MakeDenseMatrix([]float64{
+		0, 0, 4, 5,
+		0, 3, 0, 0}, 2, 4)
+	lists := [][]int{{2, 3}, {1, 2}}
+	expected := [][]int{{2}, {0}}
+	// user 0: only product 3 is relevant and unexpected, user 1: product 1
+	Assert(t, Serendipity(lists, expected, T) == 0.5)
+	Assert(t, Serendipity(lists, nil, T) == 0.75)
+}
+
+func TestCoverage(t *testing.T) {
+	Assert(t, Coverage([][]int{{0, 1}, {1, 0}}, 4) == 0.5)
+	Assert(t, Coverage(nil, 4) == 0)
+}
+
+func TestAntiTestset(t *testing.T) {
+	pairs := UserAntiTestset(R, 1, 0, nil)
+	Assert(t, len(pairs) == 3 && pairs[0] == [2]int{1, 1} && pairs[2] == [2]int{1, 3}, pairs)
+	Assert(t, len(AntiTestset(R, 0, nil)) == 9)
+
+	rng := rand.New(rand.NewSource(1))
+	pairs = UserAntiTestset(R, 1, 2, rng)
+	Assert(t, len(pairs) == 2 && pairs[0][1] < pairs[1][1] && R.Get(1, pairs[0][1]) == 0, pairs)
+	Assert(t, len(AntiTestset(R, 2, rng)) == 7)
+}
+
+func TestLeaveOneOut(t *testing.T) {
+	T := MakeDenseMatrix([]float64{
+		1, 2, 0, 0,
+		1, 0, 0, 0,
+		3, 1, 2, 0,
+		1, 0, 0, 0}, 4, 4)
+	train, held := LeaveOneOut(R, T, nil)
+	Assert(t, held[0] == 1 && held[1] == -1 && held[2] == 0 && held[3] == -1, held)
+	Assert(t, train.Get(0, 1) == 0 && train.Get(2, 0) == 0 && train.Get(0, 0) == 5 && R.Get(0, 1) == 4)
+
+	rng := rand.New(rand.NewSource(1))
+	_, held = LeaveOneOut(R, nil, rng)
+	Assert(t, held[0] == 0 || held[0] == 1, held)
+
+	// a perfect scorer ranks the held out product first
+	oracle := func(pairs [][2]int) []float64 {
+		scores := make([]float64, len(pairs))
+		for n, p := range pairs {
+			if R.Get(p[0], p[1]) != 0 {
+				scores[n] = 1
+			}
+		}
+		return scores
+	}
+	train, held = LeaveOneOut(R, T, nil)
+	hr, ndcg := HitRateNDCG(oracle, train, held, 2, 1, rng)
+	Assert(t, hr == 1 && ndcg == 1, hr, ndcg)
+
+	// a constant scorer ties with every negative: user 0 has 2 negatives (rank 3),
+	// user 2 has 1 (rank 2)
+	constant := func(pairs [][2]int) []float64 { return make([]float64, len(pairs)) }
+	hr, ndcg = HitRateNDCG(constant, train, held, 5, 2, rng)
+	Assert(t, hr == 0.5 && math.Abs(ndcg-0.5/math.Log2(3)) < 1e-12, hr, ndcg)
+
+	// with 0 as a valid rating, the observed mask stands in for the ratings
+	observed := MakeDenseMatrix([]float64{
+		1, 1, 1, 0,
+		1, 0, 0, 0,
+		1, 1, 1, 0,
+		1, 0, 0, 0}, 4, 4)
+	trainMask, held := LeaveOneOut(observed, T, nil)
+	Assert(t, held[0] == 1 && trainMask.Get(0, 2) == 1 && trainMask.Get(0, 1) == 0, held, trainMask)
+	Assert(t, len(UserAntiTestset(trainMask, 0, 0, nil)) == 2 && Popularity(observed)[2] == 0.5)
+}
+
+func TestSampledRanking(t *testing.T) {
+	rng := rand.New(rand.NewSource(47))
+	// 200 users, 50 products; every user's held out positive is product u % 50
+	users, products := 200, 50
+	train := Zeros(users, products)
+	for u := 0; u < users; u++ {
+		train.Set(u, (u+1)%products, 1)
+	}
+	test := Zeros(users, products)
+	for u := 0; u < users; u++ {
+		test.Set(u, u%products, 1)
+	}
+	positives := Positives(test)
+	Assert(t, len(positives) == users, len(positives))
+
+	oracle := func(pairs [][2]int) []float64 {
+		scores := make([]float64, len(pairs))
+		for n, p := range pairs {
+			scores[n] = test.Get(p[0], p[1])
+		}
+		return scores
+	}
+	m, err := SampledRanking(oracle, train, positives, 10, 5, sampling.Uniform, rng)
+	Assert(t, err == nil && m.Positives == users, err)
+	Assert(t, m.HitRate.Mean == 1 && m.NDCG.Mean == 1 && m.MRR.Mean == 1 && m.AUC.Mean == 1, m)
+	Assert(t, m.HitRate.StdErr == 0 && m.HitRate.Lower == 1, m.HitRate)
+
+	// random scores: AUC around 0.5, HitRate@5 among 11 candidates around 5/11, within the intervals
+	random := func(pairs [][2]int) []float64 {
+		scores := make([]float64, len(pairs))
+		for n := range scores {
+			scores[n] = rng.Float64()
+		}
+		return scores
+	}
+	m, err = SampledRanking(random, train, positives, 10, 5, sampling.Popularity, rng)
+	Assert(t, err == nil, err)
+	Assert(t, m.AUC.Lower < 0.5 && 0.5 < m.AUC.Upper, m.AUC)
+	Assert(t, m.HitRate.Lower < 5.0/11 && 5.0/11 < m.HitRate.Upper, m.HitRate)
+	lower, upper := m.AUC.Interval(0.99)
+	Assert(t, lower < m.AUC.Lower && upper > m.AUC.Upper, lower, upper)
+
+	_, err = SampledRanking(oracle, train, positives, 0, 5, sampling.Uniform, rng)
+	Assert(t, err != nil)
+	_, err = SampledRanking(oracle, Ones(1, 2), [][2]int{{0, 1}}, 5, 5, sampling.Uniform, rng)
+	Assert(t, err != nil)
+}
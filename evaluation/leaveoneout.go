@@ -0,0 +1,80 @@
+package evaluation
+
+import (
+	"math"
+	"math/rand"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// Leave-one-out protocol for implicit feedback: hold out one interaction per user, train on the
+// rest, and check how high the held out product ranks among sampled products the user never touched.
+
+// Splits R for leave-one-out evaluation. Every user with at least two interactions (nonzero, non-NaN)
+// has one of them held out: the most recent one according to the timestamps T (same shape as R), or a
+// random one if T is nil. Returns the training matrix, which is R without the held out interactions,
+// and the held out product of every user, -1 for users that kept all of theirs.
+func LeaveOneOut(R, T *DenseMatrix, rng *rand.Rand) (*DenseMatrix, []int) {
+	train := R.Copy()
+	held := make([]int, R.Rows())
+	for u := range held {
+		held[u] = -1
+		rated := make([]int, 0)
+		for i, val := range R.RowCopy(u) {
+			if val != 0 && !math.IsNaN(val) {
+				rated = append(rated, i)
+			}
+		}
+		if len(rated) < 2 {
+			continue
+		}
+		if T == nil {
+			held[u] = rated[rng.Intn(len(rated))]
+		} else {
+			held[u] = rated[0]
+			for _, i := range rated[1:] {
+				if T.Get(u, i) > T.Get(u, held[u]) {
+					held[u] = i
+				}
+			}
+		}
+		train.Set(u, held[u], 0)
+	}
+	return train, held
+}
+
+// Ranks every held out product of LeaveOneOut against a number of negatives sampled uniformly from the
+// ones the user has no interaction with in train, and returns HitRate@k (the fraction of users whose
+// held out product ranks in the top k) and NDCG@k (1/log2(rank+1), 0 outside the top k, averaged).
+// score predicts (user, product) pairs, e.g. an ALS model's PredictPairs. Negatives scoring the same
+// as the held out product count as ranked above it.
+func HitRateNDCG(score func(pairs [][2]int) []float64, train *DenseMatrix, held []int, negatives, k int, rng *rand.Rand) (hitRate, ndcg float64) {
+	users := 0
+	for u, product := range held {
+		if product < 0 {
+			continue
+		}
+		pairs := [][2]int{{u, product}}
+		for _, pair := range UserAntiTestset(train, u, negatives+1, rng) {
+			if pair[1] != product && len(pairs) <= negatives {
+				pairs = append(pairs, pair)
+			}
+		}
+		scores := score(pairs)
+		rank := 1
+		for _, s := range scores[1:] {
+			if s >= scores[0] {
+				rank++
+			}
+		}
+		users++
+		if rank <= k {
+			hitRate++
+			ndcg += 1 / math.Log2(float64(rank)+1)
+		}
+	}
+	if users == 0 {
+		return 0, 0
+	}
+	return hitRate / float64(users), ndcg / float64(users)
+}
@@ -0,0 +1,129 @@
+package evaluation
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/sampling"
+)
+
+// A metric averaged over the ranked positives, with its standard error and 95% confidence
+// interval (normal approximation). Interval gives other levels.
+type Estimate struct {
+	Mean, StdErr float64
+	Lower, Upper float64
+}
+
+// Returns the bounds of the confidence interval at the given level, e.g. 0.99.
+func (e Estimate) Interval(level float64) (lower, upper float64) {
+	z := math.Sqrt2 * math.Erfinv(level)
+	return e.Mean - z*e.StdErr, e.Mean + z*e.StdErr
+}
+
+// mean and standard error of the values
+func estimate(values []float64) Estimate {
+	n := float64(len(values))
+	e := Estimate{}
+	for _, v := range values {
+		e.Mean += v
+	}
+	e.Mean /= n
+	if n > 1 {
+		variance := 0.0
+		for _, v := range values {
+			variance += (v - e.Mean) * (v - e.Mean)
+		}
+		e.StdErr = math.Sqrt(variance / (n - 1) / n)
+	}
+	e.Lower, e.Upper = e.Interval(0.95)
+	return e
+}
+
+// Metrics of a sampled ranking evaluation, averaged over the ranked positives: HitRate@k, NDCG@k,
+// mean reciprocal rank, and AUC (the fraction of sampled negatives scored below the positive).
+type SampledMetrics struct {
+	Positives int
+	HitRate   Estimate
+	NDCG      Estimate
+	MRR       Estimate
+	AUC       Estimate
+}
+
+// Returns the (user, product) pairs of the nonzero, non-NaN entries of T, e.g. a test split, as
+// positives for SampledRanking.
+func Positives(T *DenseMatrix) [][2]int {
+	pairs := make([][2]int, 0)
+	for u := 0; u < T.Rows(); u++ {
+		for i, val := range T.RowCopy(u) {
+			if val != 0 && !math.IsNaN(val) {
+				pairs = append(pairs, [2]int{u, i})
+			}
+		}
+	}
+	return pairs
+}
+
+// Sampled evaluation for catalogs too large to rank in full: every held out positive (user, product)
+// pair is ranked against a number of negatives drawn from the products the user has no interaction
+// with, neither in train nor among the positives. Uniform negatives are cheap to beat; popularity
+// weighted ones (sampling.Popularity) are closer to what a full ranking has to get right. score
+// predicts (user, product) pairs, e.g. an ALS model's PredictPairs, and negatives scoring the same as
+// the positive count as ranked above it. Positives whose user has nothing left to sample are skipped;
+// error if that leaves none.
+func SampledRanking(score func(pairs [][2]int) []float64, train *DenseMatrix, positives [][2]int, negatives, k int, strategy sampling.Strategy, rng *rand.Rand) (*SampledMetrics, error) {
+	if negatives <= 0 || k <= 0 {
+		return nil, errors.New("Number of negatives and k must be positive")
+	}
+	sampler := sampling.NewSampler(train, strategy, rng)
+	held := make(map[int]map[int]bool)
+	for _, p := range positives {
+		if held[p[0]] == nil {
+			held[p[0]] = make(map[int]bool)
+		}
+		held[p[0]][p[1]] = true
+	}
+	var hits, ndcgs, rrs, aucs []float64
+	for _, p := range positives {
+		u := p[0]
+		drawn, err := sampler.Negatives(u, negatives+len(held[u]))
+		if err != nil {
+			continue
+		}
+		pairs := [][2]int{p}
+		for _, i := range drawn {
+			if !held[u][i] && len(pairs) <= negatives {
+				pairs = append(pairs, [2]int{u, i})
+			}
+		}
+		if len(pairs) == 1 {
+			continue
+		}
+		scores := score(pairs)
+		rank := 1
+		for _, s := range scores[1:] {
+			if s >= scores[0] {
+				rank++
+			}
+		}
+		hit, ndcg := 0.0, 0.0
+		if rank <= k {
+			hit, ndcg = 1, 1/math.Log2(float64(rank)+1)
+		}
+		hits = append(hits, hit)
+		ndcgs = append(ndcgs, ndcg)
+		rrs = append(rrs, 1/float64(rank))
+		aucs = append(aucs, 1-float64(rank-1)/float64(len(pairs)-1))
+	}
+	if len(hits) == 0 {
+		return nil, errors.New("No held out positive could be ranked")
+	}
+	return &SampledMetrics{
+		Positives: len(hits),
+		HitRate:   estimate(hits),
+		NDCG:      estimate(ndcgs),
+		MRR:       estimate(rrs),
+		AUC:       estimate(aucs),
+	}, nil
+}
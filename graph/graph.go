@@ -0,0 +1,177 @@
+// Graph based recommendations with personalized PageRank in Go
+package graph
+
+import (
+	"math"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// a weighted edge to a user or an item
+type edge struct {
+	node   int
+	weight float64
+}
+
+// Bipartite user/item graph, with an edge between every user and every item they rated, weighted
+// by the rating. Items are ranked for a user by personalized PageRank: the probability that a random
+// walk from the user, following edges in proportion to their weight and jumping back to the user
+// with probability Restart at every step, is at an item. Needs no training and copes well with
+// sparse data, as items a few hops away get scores too.
+type Graph struct {
+	// probability of jumping back to the start at every step; lower values reach further
+	Restart float64
+	// power iterations computing the scores
+	Iterations int
+
+	users [][]edge
+	items [][]edge
+}
+
+// Returns the graph of the user/product matrix R; zero, negative and NaN entries are not edges.
+// Restart is 0.15 and Iterations 20; change them to adjust.
+func FromMatrix(R *DenseMatrix) *Graph {
+	g := &Graph{
+		Restart:    0.15,
+		Iterations: 20,
+		users:      make([][]edge, R.Rows()),
+		items:      make([][]edge, R.Cols()),
+	}
+	for u := range g.users {
+		for i, r := range R.RowCopy(u) {
+			if r > 0 && !math.IsNaN(r) {
+				g.users[u] = append(g.users[u], edge{i, r})
+				g.items[i] = append(g.items[i], edge{u, r})
+			}
+		}
+	}
+	return g
+}
+
+// total weight of the edges
+func degree(edges []edge) float64 {
+	d := 0.0
+	for _, e := range edges {
+		d += e.weight
+	}
+	return d
+}
+
+// Personalized PageRank restarting at the given users and items (each with equal probability).
+// Returns the scores of all users and all items.
+func (g *Graph) rank(startUsers, startItems []int) ([]float64, []float64) {
+	restartUsers := make([]float64, len(g.users))
+	restartItems := make([]float64, len(g.items))
+	start := float64(len(startUsers) + len(startItems))
+	for _, u := range startUsers {
+		restartUsers[u] += 1 / start
+	}
+	for _, i := range startItems {
+		restartItems[i] += 1 / start
+	}
+	userScores := append([]float64(nil), restartUsers...)
+	itemScores := append([]float64(nil), restartItems...)
+	for iter := 0; iter < g.Iterations; iter++ {
+		nextUsers := make([]float64, len(g.users))
+		nextItems := make([]float64, len(g.items))
+		for u, edges := range g.users {
+			spread(userScores[u], edges, nextItems)
+		}
+		for i, edges := range g.items {
+			spread(itemScores[i], edges, nextUsers)
+		}
+		for u := range nextUsers {
+			nextUsers[u] = g.Restart*restartUsers[u] + (1-g.Restart)*nextUsers[u]
+		}
+		for i := range nextItems {
+			nextItems[i] = g.Restart*restartItems[i] + (1-g.Restart)*nextItems[i]
+		}
+		userScores, itemScores = nextUsers, nextItems
+	}
+	return userScores, itemScores
+}
+
+// adds the score of a node to its neighbours in proportion to the edge weights
+func spread(score float64, edges []edge, to []float64) {
+	if score == 0 {
+		return
+	}
+	d := degree(edges)
+	for _, e := range edges {
+		to[e.node] += score * e.weight / d
+	}
+}
+
+// Returns up to n items the user hasn't rated, in descending order of personalized PageRank.
+// Users without ratings (or unknown ones) get nothing; use a popularity baseline for them.
+func (g *Graph) Recommend(user, n int) ([]int, []float64) {
+	if user < 0 || user >= len(g.users) || len(g.users[user]) == 0 {
+		return nil, nil
+	}
+	_, scores := g.rank([]int{user}, nil)
+	rated := make(map[int]bool, len(g.users[user]))
+	for _, e := range g.users[user] {
+		rated[e.node] = true
+	}
+	return g.top(scores, n, rated)
+}
+
+// Returns up to n items related to the given ones (e.g. a basket, or the items of an anonymous
+// session), in descending order of PageRank restarting at them. The given items are skipped.
+func (g *Graph) Similar(items []int, n int) ([]int, []float64) {
+	start := make([]int, 0, len(items))
+	skip := make(map[int]bool, len(items))
+	for _, i := range items {
+		if i >= 0 && i < len(g.items) {
+			start = append(start, i)
+			skip[i] = true
+		}
+	}
+	if len(start) == 0 {
+		return nil, nil
+	}
+	_, scores := g.rank(nil, start)
+	return g.top(scores, n, skip)
+}
+
+func (g *Graph) top(scores []float64, n int, skip map[int]bool) ([]int, []float64) {
+	candidates := make([]int, 0, len(scores))
+	for i, s := range scores {
+		if s > 0 && !skip[i] {
+			candidates = append(candidates, i)
+		}
+	}
+	return topk.Split(topk.Select(candidates, n, func(i int) float64 { return scores[i] }))
+}
+
+// Explains why an item is recommended to a user: returns up to n of the user's own items, and
+// how much of the walk reaches the item through them in three steps (user -> rated item ->
+// another user who rated it -> the item), most important first. "Because you liked ...".
+func (g *Graph) Explain(user, item, n int) ([]int, []float64) {
+	if user < 0 || user >= len(g.users) || item < 0 || item >= len(g.items) {
+		return nil, nil
+	}
+	// weight of the edge from every user to the item
+	toItem := make(map[int]float64, len(g.items[item]))
+	for _, e := range g.items[item] {
+		toItem[e.node] = e.weight
+	}
+	through := make(map[int]float64)
+	du := degree(g.users[user])
+	for _, e := range g.users[user] {
+		di := degree(g.items[e.node])
+		for _, v := range g.items[e.node] {
+			w, ok := toItem[v.node]
+			if !ok || v.node == user {
+				continue
+			}
+			through[e.node] += e.weight / du * v.weight / di * w / degree(g.users[v.node])
+		}
+	}
+	items := make([]int, 0, len(through))
+	for i := range through {
+		items = append(items, i)
+	}
+	return topk.Split(topk.Select(items, n, func(i int) float64 { return through[i] }))
+}
@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestPageRank(t *testing.T) {
+	// users 0-2 like items 0-2, users 3-4 like items 3-4; user 0 hasn't seen item 2 yet
+	R := MakeDenseMatrixStacked([][]float64{
+		{5, 4, 0, 0, 0},
+		{4, 5, 5, 0, 0},
+		{0, 5, 4, 0, 1},
+		{0, 0, 0, 5, 4},
+		{0, 0, 0, 4, 5},
+	})
+	g := FromMatrix(R)
+
+	// scores are probabilities
+	users, items := g.rank([]int{0}, nil)
+	total := 0.0
+	for _, s := range append(users, items...) {
+		total += s
+	}
+	Assert(t, math.Abs(total-1) < 1e-9, total)
+
+	recs, scores := g.Recommend(0, 3)
+	Assert(t, len(recs) == 3 && recs[0] == 2 && scores[0] > scores[1], recs, scores)
+	// the other cluster is only reachable through user 2's weak edge to item 4
+	Assert(t, recs[1] == 4 && recs[2] == 3, recs)
+
+	// unknown users and users without ratings get nothing
+	recs, _ = g.Recommend(10, 3)
+	Assert(t, recs == nil)
+	g2 := FromMatrix(MakeDenseMatrixStacked([][]float64{{0, 0}, {1, 1}}))
+	recs, _ = g2.Recommend(0, 3)
+	Assert(t, recs == nil)
+
+	similar, _ := g.Similar([]int{3}, 2)
+	Assert(t, len(similar) == 2 && similar[0] == 4, similar)
+	similar, _ = g.Similar([]int{10}, 2)
+	Assert(t, similar == nil)
+
+	// item 2 is recommended to user 0 because of items 1 and 0, 1 being rated by more of item 2's fans
+	because, weights := g.Explain(0, 2, 5)
+	Assert(t, len(because) == 2 && because[0] == 1 && weights[0] > weights[1], because, weights)
+}
@@ -0,0 +1,175 @@
+// Item embeddings from interaction sequences (item2vec) in Go
+package item2vec
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/collabFilter"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// Option configures optional behaviour of Train.
+type Option func(*config)
+
+type config struct {
+	window    int
+	negatives int
+	rate      float64
+	rng       *rand.Rand
+}
+
+// Items within window positions of each other in a sequence are treated as context of each other.
+// A window of 0 (the default) uses the whole sequence, as in the item2vec paper, which suits
+// baskets and sessions where the order doesn't matter.
+func WithWindow(window int) Option {
+	return func(c *config) {
+		c.window = window
+	}
+}
+
+// Number of negative items drawn for every positive pair. Defaults to 5.
+func WithNegatives(n int) Option {
+	return func(c *config) {
+		c.negatives = n
+	}
+}
+
+// Starting learning rate, decayed linearly over training. Defaults to 0.025.
+func WithLearningRate(rate float64) Option {
+	return func(c *config) {
+		c.rate = rate
+	}
+}
+
+// Seed the initialization and sampling, so training is reproducible.
+func WithSeed(seed int64) Option {
+	return func(c *config) {
+		c.rng = rand.New(rand.NewSource(seed))
+	}
+}
+
+// Item embeddings learned by skip-gram with negative sampling. Items that show up in the same
+// sequences get similar vectors.
+type Model struct {
+	Vectors [][]float64
+}
+
+// Params: one sequence of item indices (0 to n_items-1) per user or session, e.g. in the order
+// they were viewed, the embedding dimension, and the number of passes over the data.
+// Negative items are drawn proportionally to their frequency^0.75, as in word2vec.
+func Train(sequences [][]int, n_items, dim, epochs int, opts ...Option) (*Model, error) {
+	cfg := &config{negatives: 5, rate: 0.025, rng: rand.New(rand.NewSource(47))}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	counts := make([]float64, n_items)
+	total := 0
+	for _, seq := range sequences {
+		for _, i := range seq {
+			if i < 0 || i >= n_items {
+				return nil, errors.New("Item index out of range")
+			}
+			counts[i]++
+		}
+		total += len(seq)
+	}
+	// cumulative unigram^0.75 distribution for drawing negatives
+	cumulative := make([]float64, n_items)
+	sum := 0.0
+	for i, c := range counts {
+		sum += math.Pow(c, 0.75)
+		cumulative[i] = sum
+	}
+	if sum == 0 {
+		return nil, errors.New("No interactions to train on")
+	}
+	negative := func() int {
+		return sort.SearchFloat64s(cumulative, cfg.rng.Float64()*sum)
+	}
+
+	vectors := make([][]float64, n_items)
+	contexts := make([][]float64, n_items)
+	for i := range vectors {
+		vectors[i] = make([]float64, dim)
+		contexts[i] = make([]float64, dim)
+		for f := range vectors[i] {
+			vectors[i][f] = (cfg.rng.Float64() - 0.5) / float64(dim)
+		}
+	}
+
+	grad := make([]float64, dim)
+	processed, work := 0, epochs*total
+	for epoch := 0; epoch < epochs; epoch++ {
+		for _, seq := range sequences {
+			for pos, item := range seq {
+				rate := cfg.rate * math.Max(1-float64(processed)/float64(work), 1e-4)
+				processed++
+				for other, ctx := range seq {
+					if other == pos || ctx == item || (cfg.window > 0 && abs(other-pos) > cfg.window) {
+						continue
+					}
+					for f := range grad {
+						grad[f] = 0
+					}
+					update(vectors[item], contexts[ctx], 1, rate, grad)
+					for n := 0; n < cfg.negatives; n++ {
+						if neg := negative(); neg != ctx {
+							update(vectors[item], contexts[neg], 0, rate, grad)
+						}
+					}
+					for f := range grad {
+						vectors[item][f] += grad[f]
+					}
+				}
+			}
+		}
+	}
+	return &Model{Vectors: vectors}, nil
+}
+
+// one logistic regression step on the pair, accumulating the gradient of v and updating u
+func update(v, u []float64, label, rate float64, grad []float64) {
+	score := 0.0
+	for f := range v {
+		score += v[f] * u[f]
+	}
+	g := rate * (label - 1/(1+math.Exp(-score)))
+	for f := range v {
+		grad[f] += g * u[f]
+		u[f] += g * v[f]
+	}
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// Returns the k items with the largest cosine similarity to the given item, in descending order.
+func (m *Model) SimilarItems(item, k int) ([]int, []float64, error) {
+	if item < 0 || item >= len(m.Vectors) {
+		return nil, nil, errors.New("Item index out of range")
+	}
+	others := make([]int, 0, len(m.Vectors)-1)
+	for i := range m.Vectors {
+		if i != item {
+			others = append(others, i)
+		}
+	}
+	ids, sims := topk.Split(topk.Select(others, k, func(i int) float64 {
+		return collabFilter.CosineSim(m.Vectors[item], m.Vectors[i])
+	}))
+	return ids, sims, nil
+}
+
+// Returns the embeddings as a matrix with one row per item, e.g. as content features for a
+// hybrid recommender, or as the product factors of ALS.ModelFromFactors.
+func (m *Model) Factors() *DenseMatrix {
+	return MakeDenseMatrixStacked(m.Vectors)
+}
@@ -0,0 +1,34 @@
+package item2vec
+
+import (
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestItem2Vec(t *testing.T) {
+	// two groups of items that are consumed together: 0-2 and 3-5
+	sequences := [][]int{
+		{0, 1, 2}, {1, 2, 0}, {2, 0}, {0, 1},
+		{3, 4, 5}, {5, 4}, {4, 3, 5}, {3, 5},
+	}
+	model, err := Train(sequences, 6, 8, 200, WithSeed(1))
+	Assert(t, err == nil, err)
+	for _, item := range []int{0, 4} {
+		similar, sims, _ := model.SimilarItems(item, 2)
+		for _, i := range similar {
+			Assert(t, (i < 3) == (item < 3), item, similar, sims)
+		}
+		Assert(t, sims[0] >= sims[1])
+	}
+	Assert(t, model.Factors().Rows() == 6 && model.Factors().Cols() == 8)
+
+	_, err = Train([][]int{{0, 7}}, 6, 8, 1)
+	Assert(t, err != nil)
+	_, _, err = model.SimilarItems(6, 2)
+	Assert(t, err != nil)
+}
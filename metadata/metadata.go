@@ -0,0 +1,134 @@
+// Metadata (titles, attributes, features) of the users and items of recommendation models in Go
+package metadata
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Metadata of a single user or item. Features are numeric content features (genre indicators,
+// price, ...) for content based and hybrid recommenders.
+type Entry struct {
+	ID         string
+	Title      string
+	Attributes map[string]string
+	Features   []float64
+}
+
+// A recommended (or similar) item together with its metadata.
+type Result struct {
+	Index int
+	Score float64
+	Entry
+}
+
+// Maps the internal indices of a model's items (or users) to their metadata.
+// Safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[int]Entry
+}
+
+// Returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: make(map[int]Entry)}
+}
+
+// Sets the metadata of an index.
+func (r *Registry) Set(index int, e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[index] = e
+}
+
+// Returns the metadata of an index, and whether there is any.
+func (r *Registry) Get(index int) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[index]
+	return e, ok
+}
+
+// Number of indices with metadata.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.entries)
+}
+
+// Attaches the metadata to results such as those of TopN or SimilarItems. Indices without
+// metadata get an empty Entry.
+func (r *Registry) Enrich(indices []int, scores []float64) []Result {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	results := make([]Result, len(indices))
+	for n, i := range indices {
+		results[n] = Result{Index: i, Entry: r.entries[i]}
+		if n < len(scores) {
+			results[n].Score = scores[n]
+		}
+	}
+	return results
+}
+
+// Returns a filter (usable as an ALS.Filter) keeping the indices whose attribute is one of the
+// given values, e.g. Where("category", "books", "music") for a category allow-list.
+func (r *Registry) Where(attribute string, values ...string) func(index int) bool {
+	allowed := make(map[string]bool, len(values))
+	for _, v := range values {
+		allowed[v] = true
+	}
+	return func(index int) bool {
+		e, ok := r.Get(index)
+		return ok && allowed[e.Attributes[attribute]]
+	}
+}
+
+// Returns the feature vectors of indices 0 to n-1, nil for indices without features.
+func (r *Registry) Features(n int) [][]float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	features := make([][]float64, n)
+	for i := range features {
+		features[i] = r.entries[i].Features
+	}
+	return features
+}
+
+// Reads metadata from CSV with a header row. The columns "index", "id" and "title" fill in
+// the index, ID and title, and any other column becomes an attribute. Without an "index"
+// column, rows are numbered from 0 in order.
+func LoadCSV(reader io.Reader) (*Registry, error) {
+	records, err := csv.NewReader(reader).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("Metadata CSV needs a header row")
+	}
+	header := records[0]
+	r := NewRegistry()
+	for row, record := range records[1:] {
+		index := row
+		e := Entry{Attributes: make(map[string]string)}
+		for col, value := range record {
+			switch header[col] {
+			case "index":
+				if index, err = strconv.Atoi(value); err != nil {
+					return nil, err
+				}
+			case "id":
+				e.ID = value
+			case "title":
+				e.Title = value
+			default:
+				e.Attributes[header[col]] = value
+			}
+		}
+		r.Set(index, e)
+	}
+	return r, nil
+}
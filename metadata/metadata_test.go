@@ -0,0 +1,44 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r, err := LoadCSV(strings.NewReader(`id,title,genre
+sp,Spoon,indie
+bk,The Black Keys,blues
+kw,Kanye West,hip hop
+`))
+	Assert(t, err == nil && r.Len() == 3, err)
+	e, ok := r.Get(1)
+	Assert(t, ok && e.ID == "bk" && e.Title == "The Black Keys" && e.Attributes["genre"] == "blues", e)
+
+	results := r.Enrich([]int{2, 0, 7}, []float64{4.5, 4, 3})
+	Assert(t, results[0].Title == "Kanye West" && results[0].Score == 4.5, results)
+	Assert(t, results[2].Index == 7 && results[2].Title == "", results)
+
+	indie := r.Where("genre", "indie", "blues")
+	Assert(t, indie(0) && indie(1) && !indie(2) && !indie(7))
+
+	r.Set(0, Entry{ID: "sp", Features: []float64{1, 0}})
+	features := r.Features(2)
+	Assert(t, len(features) == 2 && features[0][0] == 1 && features[1] == nil, features)
+}
+
+func TestLoadCSVIndex(t *testing.T) {
+	r, err := LoadCSV(strings.NewReader("index,title\n5,Macy Gray\n"))
+	Assert(t, err == nil, err)
+	e, ok := r.Get(5)
+	Assert(t, ok && e.Title == "Macy Gray", e)
+
+	_, err = LoadCSV(strings.NewReader("index,title\nfive,Macy Gray\n"))
+	Assert(t, err != nil)
+}
@@ -0,0 +1,49 @@
+// Instrumentation hooks for serving and training recommenders
+package metrics
+
+import (
+	"time"
+
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+// Receives the measurements of a deployed recommender. Implementations must be safe for
+// concurrent use, as requests are recorded from many goroutines. The prometheus subpackage
+// has a ready-made one.
+type Recorder interface {
+	// a served request of the given kind (e.g. "topn"), how long it took, and whether it failed
+	ObserveRequest(kind string, latency time.Duration, err error)
+	// how long ago the named model that served a request was trained
+	SetModelAge(model string, age time.Duration)
+	// the training error after an iteration of a training run
+	ObserveIteration(iteration int, loss float64)
+}
+
+type nop struct{}
+
+func (nop) ObserveRequest(kind string, latency time.Duration, err error) {}
+func (nop) SetModelAge(model string, age time.Duration)                  {}
+func (nop) ObserveIteration(iteration int, loss float64)                 {}
+
+// A recorder that drops all measurements.
+func Nop() Recorder {
+	return nop{}
+}
+
+// Runs fn and records it as a request of the given kind, returning fn's error.
+func Track(r Recorder, kind string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	r.ObserveRequest(kind, time.Since(start), err)
+	return err
+}
+
+// Records the age of the model, from the training time in its metadata.
+func ObserveModel(r Recorder, name string, m *ALS.Model) {
+	r.SetModelAge(name, time.Since(m.Metadata().TrainedAt))
+}
+
+// An ALS option sending the training error of every iteration to r.
+func Training(r Recorder) ALS.Option {
+	return ALS.OnIteration(r.ObserveIteration)
+}
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+type memory struct {
+	mu       sync.Mutex
+	requests map[string]int
+	failed   int
+	ages     map[string]time.Duration
+	losses   []float64
+}
+
+func newMemory() *memory {
+	return &memory{requests: make(map[string]int), ages: make(map[string]time.Duration)}
+}
+
+func (m *memory) ObserveRequest(kind string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests[kind]++
+	if err != nil {
+		m.failed++
+	}
+}
+
+func (m *memory) SetModelAge(model string, age time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ages[model] = age
+}
+
+func (m *memory) ObserveIteration(iteration int, loss float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.losses = append(m.losses, loss)
+}
+
+func TestRecorder(t *testing.T) {
+	r := newMemory()
+	Track(r, "topn", func() error { return nil })
+	err := Track(r, "topn", func() error { return errors.New("boom") })
+	Assert(t, err != nil && r.requests["topn"] == 2 && r.failed == 1, r.requests, r.failed)
+
+	Q := MakeDenseMatrix([]float64{
+		5, 5, 0, 1,
+		0, 1, 4, 1,
+		2, 0, 4, 0}, 3, 4)
+	model, _, err := ALS.TrainModel(Q, 2, 4, 0.01, Training(r))
+	Assert(t, err == nil && len(r.losses) == 4, err, r.losses)
+	ObserveModel(r, "current", model)
+	Assert(t, r.ages["current"] >= 0 && r.ages["current"] < time.Minute, r.ages)
+
+	// the no-op recorder accepts everything
+	Assert(t, Track(Nop(), "topn", func() error { return nil }) == nil)
+	ObserveModel(Nop(), "current", model)
+}
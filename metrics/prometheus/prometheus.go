@@ -0,0 +1,80 @@
+// Prometheus adapter for the metrics package
+package prometheus
+
+import (
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// A metrics.Recorder exporting the measurements as Prometheus metrics:
+//
+//	<namespace>_requests_total{kind, status}          counter of served requests, status "ok" or "error"
+//	<namespace>_request_duration_seconds{kind}        histogram of request latencies
+//	<namespace>_model_age_seconds{model}              gauge of the age of the serving model
+//	<namespace>_training_iterations_total             counter of completed training iterations
+//	<namespace>_training_loss                         gauge of the latest training error
+type Recorder struct {
+	requests   *prom.CounterVec
+	latency    *prom.HistogramVec
+	age        *prom.GaugeVec
+	iterations prom.Counter
+	loss       prom.Gauge
+}
+
+// Returns a recorder with its metrics registered with reg, e.g. prom.DefaultRegisterer, under the
+// namespace, e.g. "recommender". Fails if the metrics are already registered.
+func NewRecorder(namespace string, reg prom.Registerer) (*Recorder, error) {
+	r := &Recorder{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of served recommendation requests.",
+		}, []string{"kind", "status"}),
+		latency: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of recommendation requests.",
+			Buckets:   prom.ExponentialBuckets(0.0001, 2, 16),
+		}, []string{"kind"}),
+		age: prom.NewGaugeVec(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "model_age_seconds",
+			Help:      "Time since the serving model was trained.",
+		}, []string{"model"}),
+		iterations: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "training_iterations_total",
+			Help:      "Number of completed training iterations.",
+		}),
+		loss: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "training_loss",
+			Help:      "Training error after the latest iteration.",
+		}),
+	}
+	for _, c := range []prom.Collector{r.requests, r.latency, r.age, r.iterations, r.loss} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *Recorder) ObserveRequest(kind string, latency time.Duration, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	r.requests.WithLabelValues(kind, status).Inc()
+	r.latency.WithLabelValues(kind).Observe(latency.Seconds())
+}
+
+func (r *Recorder) SetModelAge(model string, age time.Duration) {
+	r.age.WithLabelValues(model).Set(age.Seconds())
+}
+
+func (r *Recorder) ObserveIteration(iteration int, loss float64) {
+	r.iterations.Inc()
+	r.loss.Set(loss)
+}
@@ -0,0 +1,118 @@
+// Two stage recommendations in Go: candidate generation, then ranking
+package pipeline
+
+import (
+	"errors"
+	"math"
+
+	"github.com/timkaye11/goRecommend/ALS"
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// First stage: cheaply proposes up to n products worth ranking for the user, best first,
+// skipping the products the user already has.
+type Generator interface {
+	Candidates(user, n int) ([]int, error)
+}
+
+// Second stage: scores the candidate products for the user, higher is better. NaN scores drop
+// the candidate.
+type Ranker interface {
+	Score(user int, products []int) ([]float64, error)
+}
+
+// Adapts a function to a Generator.
+type GeneratorFunc func(user, n int) ([]int, error)
+
+func (f GeneratorFunc) Candidates(user, n int) ([]int, error) {
+	return f(user, n)
+}
+
+// Adapts a function to a Ranker, e.g. a factorization machine's predictions.
+type RankerFunc func(user int, products []int) ([]float64, error)
+
+func (f RankerFunc) Score(user int, products []int) ([]float64, error) {
+	return f(user, products)
+}
+
+// Recommends in two stages: every generator proposes up to Candidates products, and Ranker
+// orders their union. The generators keep the expensive ranker away from most of the catalog,
+// and each can cover what the others miss (popular products for new users, co-occurring ones for
+// a fresh basket, ...).
+type Pipeline struct {
+	Generators []Generator
+	Candidates int
+	Ranker     Ranker
+}
+
+// Returns a pipeline ranking up to candidates products of every generator with the ranker.
+func New(ranker Ranker, candidates int, generators ...Generator) *Pipeline {
+	return &Pipeline{Generators: generators, Candidates: candidates, Ranker: ranker}
+}
+
+// Returns the n best candidates for the user by the ranker's scores, in descending order along
+// with the scores. Candidates rejected by any of the filters are dropped before ranking. A
+// generator that fails (e.g. a model that doesn't know the user) is skipped; the error is only
+// returned when no generator succeeds.
+func (p *Pipeline) Recommend(user, n int, filters ...ALS.Filter) ([]int, []float64, error) {
+	if len(p.Generators) == 0 || p.Ranker == nil {
+		return nil, nil, errors.New("Pipeline needs at least one generator and a ranker")
+	}
+	candidates, err := p.candidates(user, filters)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil, nil
+	}
+	scores, err := p.Ranker.Score(user, candidates)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(scores) != len(candidates) {
+		return nil, nil, errors.New("Ranker needs to return a score for every candidate")
+	}
+	ranked := make([]int, 0, len(candidates))
+	score := make(map[int]float64, len(candidates))
+	for c, i := range candidates {
+		if !math.IsNaN(scores[c]) {
+			ranked = append(ranked, i)
+			score[i] = scores[c]
+		}
+	}
+	ids, top := topk.Split(topk.Select(ranked, n, func(i int) float64 { return score[i] }))
+	return ids, top, nil
+}
+
+// union of the candidates of all generators that pass the filters, in the order they were proposed
+func (p *Pipeline) candidates(user int, filters []ALS.Filter) ([]int, error) {
+	var candidates []int
+	var failed error
+	succeeded := false
+	seen := make(map[int]bool)
+	for _, g := range p.Generators {
+		ids, err := g.Candidates(user, p.Candidates)
+		if err != nil {
+			failed = err
+			continue
+		}
+		succeeded = true
+	next:
+		for _, i := range ids {
+			if seen[i] {
+				continue
+			}
+			seen[i] = true
+			for _, keep := range filters {
+				if !keep(i) {
+					continue next
+				}
+			}
+			candidates = append(candidates, i)
+		}
+	}
+	if !succeeded {
+		return nil, failed
+	}
+	return candidates, nil
+}
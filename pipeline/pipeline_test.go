@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/ALS"
+	"github.com/timkaye11/goRecommend/baseline"
+	"github.com/timkaye11/goRecommend/cooccurrence"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+var Q = MakeDenseMatrix([]float64{
+	5, 5, 0, 1, 0, 0,
+	0, 1, 4, 1, 0, 3,
+	2, 0, 4, 0, 5, 0,
+	4, 5, 0, 0, 0, 1}, 4, 6)
+
+func TestPipeline(t *testing.T) {
+	model, _, err := ALS.TrainModel(Q, 2, 10, 0.01)
+	Assert(t, err == nil, err)
+	popular, _ := baseline.Train(Q, 1)
+	baskets := cooccurrence.BasketsFromMatrix(Q)
+	rules := cooccurrence.Fit(baskets)
+	history := func(user int) []int { return baskets[user] }
+
+	// the union of all generators ranked by the model is the model's own top N
+	p := New(ModelScores(model), 10, Popularity(popular), CoOccurrence(rules, history), NearestFactors(model))
+	ids, scores, err := p.Recommend(0, 3)
+	want, wantScores, _ := model.TopN(0, 3)
+	Assert(t, err == nil && fmt.Sprint(ids) == fmt.Sprint(want), ids, want, err)
+	Assert(t, math.Abs(scores[0]-wantScores[0]) < 1e-12, scores, wantScores)
+
+	// only the candidates are ranked, and filters drop them before ranking
+	p = New(ModelScores(model), 1, Popularity(popular))
+	ids, _, _ = p.Recommend(0, 3)
+	Assert(t, len(ids) == 1 && ids[0] == popular.MostPopular(0, 1)[0], ids)
+	ids, _, _ = p.Recommend(0, 3, ALS.Blocklist(ids[0]))
+	Assert(t, len(ids) == 0, ids)
+
+	// failing generators are skipped unless all fail
+	failing := GeneratorFunc(func(user, n int) ([]int, error) { return nil, errors.New("down") })
+	p = New(Unranked(), 10, failing, CoOccurrence(rules, history))
+	ids, scores, err = p.Recommend(0, 2)
+	cooc, _ := rules.Recommend(baskets[0], 2)
+	Assert(t, err == nil && fmt.Sprint(ids) == fmt.Sprint(cooc) && scores[0] > scores[1], ids, cooc, err)
+	_, _, err = New(Unranked(), 10, failing).Recommend(0, 2)
+	Assert(t, err != nil)
+	_, _, err = New(nil, 10).Recommend(0, 2)
+	Assert(t, err != nil)
+
+	// unknown users: the model drops out, popularity still generates, the model can't score them
+	p = New(ModelScores(model), 10, NearestFactors(model), Popularity(popular))
+	ids, _, err = p.Recommend(10, 2)
+	Assert(t, err == nil && len(ids) == 0, ids, err)
+
+	hybrid := ALS.NewHybrid(model, 2, 0.5)
+	ids, _, err = New(HybridScores(hybrid), 10, NearestFactors(model)).Recommend(1, 2)
+	want, _, _ = hybrid.TopN(1, 2)
+	Assert(t, err == nil && fmt.Sprint(ids) == fmt.Sprint(want), ids, want)
+}
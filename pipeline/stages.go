@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"github.com/timkaye11/goRecommend/ALS"
+	"github.com/timkaye11/goRecommend/baseline"
+	"github.com/timkaye11/goRecommend/cooccurrence"
+)
+
+// Generates the most popular products the user hasn't rated.
+func Popularity(m *baseline.Model) Generator {
+	return GeneratorFunc(func(user, n int) ([]int, error) {
+		return m.MostPopular(user, n), nil
+	})
+}
+
+// Generates the products co-occurring with the user's history (e.g. their current basket, or
+// their row of cooccurrence.BasketsFromMatrix) under the model's association rules.
+func CoOccurrence(m *cooccurrence.Model, history func(user int) []int) Generator {
+	return GeneratorFunc(func(user, n int) ([]int, error) {
+		ids, _ := m.Recommend(history(user), n)
+		return ids, nil
+	})
+}
+
+// Generates the products whose factors best match the user's, i.e. the model's TopN. Build an
+// approximate index over the factors with the model's BuildIndex to make this fast on large
+// catalogs.
+func NearestFactors(m *ALS.Model) Generator {
+	return GeneratorFunc(func(user, n int) ([]int, error) {
+		ids, _, err := m.TopN(user, n)
+		return ids, err
+	})
+}
+
+// Ranks by the model's predictions. Users the model doesn't know get NaN scores, so nothing.
+func ModelScores(m *ALS.Model) Ranker {
+	return RankerFunc(func(user int, products []int) ([]float64, error) {
+		pairs := make([][2]int, len(products))
+		for c, i := range products {
+			pairs[c] = [2]int{user, i}
+		}
+		return m.PredictPairs(pairs), nil
+	})
+}
+
+// Ranks by the hybrid's blend of ALS and item neighborhood predictions.
+func HybridScores(h *ALS.Hybrid) Ranker {
+	return RankerFunc(func(user int, products []int) ([]float64, error) {
+		scores := make([]float64, len(products))
+		for c, i := range products {
+			s, err := h.Predict(user, i)
+			if err != nil {
+				return nil, err
+			}
+			scores[c] = s
+		}
+		return scores, nil
+	})
+}
+
+// Ranks the candidates of every user in the order the generators proposed them, e.g. to measure
+// what the ranker adds.
+func Unranked() Ranker {
+	return RankerFunc(func(user int, products []int) ([]float64, error) {
+		scores := make([]float64, len(products))
+		for c := range scores {
+			scores[c] = float64(len(products) - c)
+		}
+		return scores, nil
+	})
+}
@@ -0,0 +1,226 @@
+// Negative sampling of unobserved user/product pairs for implicit feedback in Go
+package sampling
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+// How negative products are drawn.
+type Strategy int
+
+const (
+	// every unobserved product is equally likely
+	Uniform Strategy = iota
+	// products are drawn proportionally to (interactions + 1)^0.75, as in word2vec, so popular
+	// products the user skipped are more likely to be real negatives
+	Popularity
+)
+
+// exponent applied to the popularity counts
+const popularityExponent = 0.75
+
+// Sampler draws unobserved (user, product) pairs from an interaction matrix as negatives for
+// pairwise or classification trainers (BPR, WARP, logistic MF).
+// Draws are rejection sampled, which takes O(1) expected time unless a user has interacted with
+// most of the catalog; then it falls back to a single reservoir pass over the user's unobserved products.
+type Sampler struct {
+	strategy Strategy
+	observed []map[int]bool
+	products int
+	weights  []float64
+	alias    *aliasTable
+	rng      *rand.Rand
+}
+
+// Builds a sampler from a user/product matrix, where 0 or NaN means no interaction. Only which
+// entries are nonzero matters, so when 0 is a valid rating pass the observed mask (e.g.
+// data.Dataset's Observed) instead.
+func NewSampler(R *DenseMatrix, strategy Strategy, rng *rand.Rand) *Sampler {
+	s := &Sampler{
+		strategy: strategy,
+		observed: make([]map[int]bool, R.Rows()),
+		products: R.Cols(),
+		rng:      rng,
+	}
+	counts := make([]float64, R.Cols())
+	for u := 0; u < R.Rows(); u++ {
+		s.observed[u] = make(map[int]bool)
+		for i, val := range R.RowCopy(u) {
+			if val != 0 && !math.IsNaN(val) {
+				s.observed[u][i] = true
+				counts[i]++
+			}
+		}
+	}
+	if strategy == Popularity {
+		s.weights = make([]float64, len(counts))
+		for i, c := range counts {
+			s.weights[i] = math.Pow(c+1, popularityExponent)
+		}
+		s.alias = newAliasTable(s.weights)
+	}
+	return s
+}
+
+// Reports whether the user interacted with the product.
+func (s *Sampler) Observed(user, product int) bool {
+	return s.observed[user][product]
+}
+
+// draws a product from the catalog according to the strategy
+func (s *Sampler) draw() int {
+	if s.strategy == Popularity {
+		return s.alias.draw(s.rng)
+	}
+	return s.rng.Intn(s.products)
+}
+
+// Draws a single product the user has not interacted with.
+func (s *Sampler) Negative(user int) (int, error) {
+	negatives, err := s.Negatives(user, 1)
+	if err != nil {
+		return 0, err
+	}
+	return negatives[0], nil
+}
+
+// Draws n distinct products the user has not interacted with. Returns fewer if the user has
+// fewer unobserved products, and an error if there are none, the user is out of range or n is
+// negative.
+func (s *Sampler) Negatives(user, n int) ([]int, error) {
+	if user < 0 || user >= len(s.observed) {
+		return nil, errors.New("User index out of range")
+	}
+	if n < 0 {
+		return nil, errors.New("Number of negatives must not be negative")
+	}
+	available := s.products - len(s.observed[user])
+	if available == 0 {
+		return nil, errors.New("User has interacted with every product")
+	}
+	if n > available {
+		n = available
+	}
+	picked := make(map[int]bool, n)
+	negatives := make([]int, 0, n)
+	// rejection sampling, giving up after a bounded number of draws
+	for attempts := 0; len(negatives) < n && attempts < 4*n+20; attempts++ {
+		i := s.draw()
+		if !s.observed[user][i] && !picked[i] {
+			picked[i] = true
+			negatives = append(negatives, i)
+		}
+	}
+	if len(negatives) < n {
+		negatives = append(negatives, s.reservoir(user, n-len(negatives), picked)...)
+	}
+	return negatives, nil
+}
+
+// Draws n (user, negative product) pairs for uniformly chosen users, skipping users who have
+// interacted with everything. Returns no pairs if there are no users or n is not positive.
+func (s *Sampler) Pairs(n int) [][2]int {
+	if n <= 0 || len(s.observed) == 0 {
+		return [][2]int{}
+	}
+	pairs := make([][2]int, 0, n)
+	for attempts := 0; len(pairs) < n && attempts < 10*n+len(s.observed); attempts++ {
+		u := s.rng.Intn(len(s.observed))
+		if i, err := s.Negative(u); err == nil {
+			pairs = append(pairs, [2]int{u, i})
+		}
+	}
+	return pairs
+}
+
+// Single pass weighted reservoir sampling (Efraimidis & Spirakis) of n unobserved products not
+// already picked: each candidate gets the key u^(1/w) and the n largest keys are kept.
+func (s *Sampler) reservoir(user, n int, picked map[int]bool) []int {
+	type keyed struct {
+		product int
+		key     float64
+	}
+	res := make([]keyed, 0, n+1)
+	for i := 0; i < s.products; i++ {
+		if s.observed[user][i] || picked[i] {
+			continue
+		}
+		w := 1.0
+		if s.weights != nil {
+			w = s.weights[i]
+		}
+		key := math.Pow(s.rng.Float64(), 1/w)
+		if len(res) < n {
+			res = append(res, keyed{i, key})
+			continue
+		}
+		// replace the smallest key if this one is larger
+		min := 0
+		for j := range res {
+			if res[j].key < res[min].key {
+				min = j
+			}
+		}
+		if key > res[min].key {
+			res[min] = keyed{i, key}
+		}
+	}
+	sort.Slice(res, func(a, b int) bool { return res[a].key > res[b].key })
+	products := make([]int, len(res))
+	for j, r := range res {
+		products[j] = r.product
+	}
+	return products
+}
+
+// Walker's alias method for O(1) draws from a discrete distribution.
+type aliasTable struct {
+	prob  []float64
+	alias []int
+}
+
+func newAliasTable(weights []float64) *aliasTable {
+	n := len(weights)
+	total := 0.0
+	for _, w := range weights {
+		total += w
+	}
+	t := &aliasTable{prob: make([]float64, n), alias: make([]int, n)}
+	scaled := make([]float64, n)
+	small, large := make([]int, 0), make([]int, 0)
+	for i, w := range weights {
+		scaled[i] = w * float64(n) / total
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		l, g := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		t.prob[l], t.alias[l] = scaled[l], g
+		scaled[g] -= 1 - scaled[l]
+		if scaled[g] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, g)
+		}
+	}
+	for _, i := range append(small, large...) {
+		t.prob[i], t.alias[i] = 1, i
+	}
+	return t
+}
+
+func (t *aliasTable) draw(rng *rand.Rand) int {
+	i := rng.Intn(len(t.prob))
+	if rng.Float64() < t.prob[i] {
+		return i
+	}
+	return t.alias[i]
+}
@@ -0,0 +1,66 @@
+package sampling
+
+import (
+	"math/rand"
+	"testing"
+
+	. "github.com/skelterjohn/go.matrix"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestNegatives(t *testing.T) {
+	R := MakeDenseMatrix([]float64{
+		1, 1, 1, 1, 0, 1,
+		0, 1, 0, 0, 0, 0,
+		1, 1, 1, 1, 1, 1}, 3, 6)
+
+	for _, strategy := range []Strategy{Uniform, Popularity} {
+		s := NewSampler(R, strategy, rand.New(rand.NewSource(1)))
+		// a dense user only has product 4 left
+		negatives, err := s.Negatives(0, 3)
+		Assert(t, err == nil && len(negatives) == 1 && negatives[0] == 4, negatives)
+
+		negatives, _ = s.Negatives(1, 5)
+		seen := make(map[int]bool)
+		for _, i := range negatives {
+			Assert(t, i != 1 && !seen[i], negatives)
+			seen[i] = true
+		}
+		Assert(t, len(negatives) == 5)
+
+		_, err = s.Negative(2)
+		Assert(t, err != nil)
+		for _, pair := range s.Pairs(20) {
+			Assert(t, pair[0] != 2 && !s.Observed(pair[0], pair[1]), pair)
+		}
+
+		_, err = s.Negatives(1, -1)
+		Assert(t, err != nil)
+		Assert(t, len(s.Pairs(-1)) == 0)
+	}
+	empty := NewSampler(MakeDenseMatrix([]float64{}, 0, 6), Uniform, rand.New(rand.NewSource(1)))
+	Assert(t, len(empty.Pairs(5)) == 0)
+}
+
+func TestPopularitySampling(t *testing.T) {
+	// product 0 is far more popular than product 5
+	R := MakeDenseMatrix([]float64{
+		1, 0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 0,
+		1, 1, 0, 0, 0, 0,
+		1, 0, 1, 0, 0, 0,
+		1, 0, 0, 1, 0, 0,
+		0, 0, 0, 0, 0, 0}, 6, 6)
+	s := NewSampler(R, Popularity, rand.New(rand.NewSource(3)))
+	counts := make([]int, 6)
+	for n := 0; n < 5000; n++ {
+		i, _ := s.Negative(5)
+		counts[i]++
+	}
+	Assert(t, counts[0] > 2*counts[5], counts)
+}
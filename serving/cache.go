@@ -0,0 +1,153 @@
+package serving
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+// Caches TopN results of the model served by a ModelHolder, so repeated requests for the same user
+// skip the scoring. Entries are keyed by user, N and a caller chosen key naming the filters (filters
+// are functions and can't be compared), evicted least recently used once the cache is full, and
+// expire after the TTL. The cache remembers the model its entries were computed with: the first
+// request after the holder serves another model (Swap, Update) drops them all, so entries never
+// keep an old model alive.
+type Cache struct {
+	holder   *ModelHolder
+	capacity int
+	ttl      time.Duration
+	// current time, replaced in tests
+	now func() time.Time
+
+	mu sync.Mutex
+	// the model the entries were computed with
+	model   *ALS.Model
+	lru     *list.List
+	entries map[cacheKey]*list.Element
+	hits    int64
+	misses  int64
+}
+
+type cacheKey struct {
+	user, n int
+	filters string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	expires time.Time
+	ids     []int
+	scores  []float64
+}
+
+// Returns a cache of at most capacity TopN results in front of the holder's model. A ttl of 0
+// keeps entries until they are evicted or invalidated.
+func NewCache(holder *ModelHolder, capacity int, ttl time.Duration) (*Cache, error) {
+	if capacity <= 0 {
+		return nil, errors.New("Cache capacity must be positive")
+	}
+	return &Cache{
+		holder:   holder,
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[cacheKey]*list.Element),
+	}, nil
+}
+
+// Returns the user's top n products like ALS.Model.TopN, from the cache if possible. filterKey must
+// identify the filters, e.g. "in-stock"; requests with different filters need different keys.
+// The returned slices are copies and can be changed by the caller.
+func (c *Cache) TopN(user, n int, filterKey string, filters ...ALS.Filter) ([]int, []float64, error) {
+	model := c.holder.Load()
+	key := cacheKey{user, n, filterKey}
+	c.mu.Lock()
+	if model != c.model {
+		c.clear()
+		c.model = model
+	}
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*cacheEntry)
+		if c.ttl == 0 || c.now().Before(entry.expires) {
+			c.lru.MoveToFront(el)
+			c.hits++
+			c.mu.Unlock()
+			return copyResults(entry.ids, entry.scores)
+		}
+		c.remove(el)
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// score without holding the lock; concurrent misses for the same key just compute it twice
+	ids, scores, err := model.TopN(user, n, filters...)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.mu.Lock()
+	if model != c.model {
+		// the model was swapped while scoring
+		c.mu.Unlock()
+		return copyResults(ids, scores)
+	}
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{
+		key:     key,
+		expires: c.now().Add(c.ttl),
+		ids:     ids,
+		scores:  scores,
+	})
+	for c.lru.Len() > c.capacity {
+		c.remove(c.lru.Back())
+	}
+	c.mu.Unlock()
+	return copyResults(ids, scores)
+}
+
+func copyResults(ids []int, scores []float64) ([]int, []float64, error) {
+	return append([]int(nil), ids...), append([]float64(nil), scores...), nil
+}
+
+// must be called with c.mu held
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// Drops all cached results of the user, e.g. when the user submits new feedback that the served
+// model doesn't reflect yet.
+func (c *Cache) InvalidateUser(user int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		if key.user == user {
+			c.remove(el)
+		}
+	}
+}
+
+// Drops all cached results.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clear()
+}
+
+// must be called with c.mu held
+func (c *Cache) clear() {
+	c.lru.Init()
+	c.entries = make(map[cacheKey]*list.Element)
+}
+
+// Number of cached results, and of TopN calls answered from and missing the cache so far.
+func (c *Cache) Stats() (size int, hits, misses int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len(), c.hits, c.misses
+}
@@ -0,0 +1,54 @@
+// Serving trained recommendation models under concurrent requests
+package serving
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+// Holds the model currently being served. Readers get the current model with a single atomic
+// load and no locks, while a retrained model is swapped in, or updates are applied to a copy
+// which then replaces the current model (copy-on-write). Models handed out by Load must be
+// treated as read only; change them through Update.
+type ModelHolder struct {
+	current atomic.Value
+	// serializes writers, so concurrent Updates don't lose each other's changes
+	mu sync.Mutex
+}
+
+// Returns a holder serving the given model.
+func NewModelHolder(m *ALS.Model) *ModelHolder {
+	h := &ModelHolder{}
+	h.current.Store(m)
+	return h
+}
+
+// Returns the model currently being served.
+func (h *ModelHolder) Load() *ALS.Model {
+	return h.current.Load().(*ALS.Model)
+}
+
+// Replaces the served model, e.g. with a freshly retrained one, and returns the previous model.
+// Requests that already loaded the previous model finish with it.
+func (h *ModelHolder) Swap(m *ALS.Model) *ALS.Model {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	old := h.Load()
+	h.current.Store(m)
+	return old
+}
+
+// Applies fn to a copy of the current model and serves the copy, unless fn returns an error,
+// in which case the current model is kept and the error returned.
+func (h *ModelHolder) Update(fn func(m *ALS.Model) error) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	next := h.Load().Clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	h.current.Store(next)
+	return nil
+}
@@ -0,0 +1,31 @@
+package serving
+
+import (
+	"time"
+
+	"github.com/timkaye11/goRecommend/ALS"
+	"github.com/timkaye11/goRecommend/metrics"
+)
+
+// Serves TopN requests from the model of a holder while recording them: every request's latency
+// and outcome as kind "topn", and the age of the model that served it under Name.
+type Instrumented struct {
+	Name     string
+	Holder   *ModelHolder
+	Recorder metrics.Recorder
+}
+
+// Returns the holder's model instrumented with the recorder, reporting the model as name.
+func NewInstrumented(name string, holder *ModelHolder, r metrics.Recorder) *Instrumented {
+	return &Instrumented{Name: name, Holder: holder, Recorder: r}
+}
+
+// Returns the user's top n products like ALS.Model.TopN, recording the request.
+func (i *Instrumented) TopN(user, n int, filters ...ALS.Filter) ([]int, []float64, error) {
+	start := time.Now()
+	model := i.Holder.Load()
+	ids, scores, err := model.TopN(user, n, filters...)
+	i.Recorder.ObserveRequest("topn", time.Since(start), err)
+	metrics.ObserveModel(i.Recorder, i.Name, model)
+	return ids, scores, err
+}
@@ -0,0 +1,161 @@
+package serving
+
+import (
+	"sync"
+	"time"
+
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+// A new interaction waiting to be absorbed into the served model.
+type Interaction struct {
+	User, Product int
+	Value         float64
+	// when it was added to the scheduler
+	Time time.Time
+}
+
+// Keeps the model of a holder up to date with new interactions in the background. Interactions
+// are applied incrementally (Model.Update on a copy of the served model, published atomically)
+// once UpdateAfter of them have accumulated, and a full retrain replaces the model once
+// RetrainAfter have accumulated since the last one, or the oldest is MaxAge old. Zero thresholds
+// are disabled. Retrain is called with the interactions since the last retrain and must return a
+// model trained on all data including them; the caller keeps the full data, e.g. in a
+// stream.Consumer's Dataset. BeforeRetrain and AfterRetrain, if set, are called around every
+// retrain, e.g. to snapshot the data or evaluate the new model, and OnError with the errors of
+// the background checks.
+type Scheduler struct {
+	Holder  *ModelHolder
+	Retrain func(batch []Interaction) (*ALS.Model, error)
+
+	UpdateAfter  int
+	RetrainAfter int
+	MaxAge       time.Duration
+	// how often Start checks the thresholds
+	Interval time.Duration
+
+	BeforeRetrain func(batch []Interaction)
+	AfterRetrain  func(m *ALS.Model, err error)
+	OnError       func(err error)
+
+	// current time, replaced in tests
+	now func() time.Time
+
+	mu sync.Mutex
+	// interactions not yet applied incrementally, and all since the last retrain
+	pending      []Interaction
+	sinceRetrain []Interaction
+	// serializes Check, so Add doesn't wait for a retrain
+	running sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Returns a scheduler for the holder's model, checking every minute. Set the thresholds before
+// calling Start.
+func NewScheduler(holder *ModelHolder, retrain func(batch []Interaction) (*ALS.Model, error)) *Scheduler {
+	return &Scheduler{
+		Holder:   holder,
+		Retrain:  retrain,
+		Interval: time.Minute,
+		now:      time.Now,
+	}
+}
+
+// Queues a new interaction. Safe to call from many goroutines.
+func (s *Scheduler) Add(user, product int, value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := Interaction{User: user, Product: product, Value: value, Time: s.now()}
+	s.pending = append(s.pending, i)
+	s.sinceRetrain = append(s.sinceRetrain, i)
+}
+
+// Number of interactions not yet applied incrementally, and not yet retrained on.
+func (s *Scheduler) Pending() (updates, retrain int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending), len(s.sinceRetrain)
+}
+
+// Checks the thresholds once, and retrains or applies the pending interactions if one is reached.
+// A failed retrain keeps its interactions for the next one; a failed incremental update drops
+// them from the updates (the served model is left as it was) but keeps them for the next retrain.
+func (s *Scheduler) Check() error {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	s.mu.Lock()
+	retrain := len(s.sinceRetrain) > 0 && s.Retrain != nil &&
+		((s.RetrainAfter > 0 && len(s.sinceRetrain) >= s.RetrainAfter) ||
+			(s.MaxAge > 0 && s.now().Sub(s.sinceRetrain[0].Time) >= s.MaxAge))
+	update := !retrain && s.UpdateAfter > 0 && len(s.pending) >= s.UpdateAfter
+	var batch []Interaction
+	if retrain {
+		batch = append([]Interaction(nil), s.sinceRetrain...)
+	} else if update {
+		batch = s.pending
+		s.pending = nil
+	}
+	s.mu.Unlock()
+
+	if retrain {
+		return s.retrain(batch)
+	}
+	if update {
+		return s.Holder.Update(func(m *ALS.Model) error {
+			for _, i := range batch {
+				if err := m.Update(i.User, i.Product, i.Value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+func (s *Scheduler) retrain(batch []Interaction) error {
+	if s.BeforeRetrain != nil {
+		s.BeforeRetrain(batch)
+	}
+	m, err := s.Retrain(batch)
+	if err == nil {
+		s.Holder.Swap(m)
+		// interactions added while retraining are still pending
+		s.mu.Lock()
+		s.sinceRetrain = s.sinceRetrain[len(batch):]
+		s.pending = append([]Interaction(nil), s.sinceRetrain...)
+		s.mu.Unlock()
+	}
+	if s.AfterRetrain != nil {
+		s.AfterRetrain(m, err)
+	}
+	return err
+}
+
+// Starts checking the thresholds every Interval in the background, until Stop.
+func (s *Scheduler) Start() {
+	s.stop, s.done = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(s.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := s.Check(); err != nil && s.OnError != nil {
+					s.OnError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Stops the background checks, waiting for a running one to finish.
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	<-s.done
+}
@@ -0,0 +1,262 @@
+package serving
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+var Q = MakeDenseMatrix([]float64{
+	5, 5, 0, 1,
+	0, 1, 4, 1,
+	2, 0, 4, 0}, 3, 4)
+
+func TestModelHolder(t *testing.T) {
+	first, _, _ := ALS.TrainModel(Q, 2, 5, 0.01)
+	h := NewModelHolder(first)
+	Assert(t, h.Load() == first)
+
+	// updates go to a copy; the model being served is left alone
+	Assert(t, h.Update(func(m *ALS.Model) error { return m.Update(3, 0, 5) }) == nil)
+	Assert(t, h.Load() != first && h.Load().X.Rows() == 4 && first.X.Rows() == 3)
+
+	// failed updates are discarded
+	current := h.Load()
+	Assert(t, h.Update(func(m *ALS.Model) error {
+		m.Update(0, 2, 1)
+		return errors.New("bad batch")
+	}) != nil)
+	Assert(t, h.Load() == current && current.P.Get(0, 2) == 0)
+
+	second, _, _ := ALS.TrainModel(Q, 2, 10, 0.01)
+	Assert(t, h.Swap(second) == current && h.Load() == second)
+}
+
+func TestConcurrentServing(t *testing.T) {
+	model, _, _ := ALS.TrainModel(Q, 2, 5, 0.01)
+	h := NewModelHolder(model)
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				h.Load().TopN(n%3, 2)
+			}
+		}()
+	}
+	for n := 0; n < 20; n++ {
+		h.Update(func(m *ALS.Model) error { return m.Update(n%3, 3, float64(n%5+1)) })
+	}
+	wg.Wait()
+}
+
+func TestRouter(t *testing.T) {
+	r := NewRouter()
+	_, _, err := r.Route("alice")
+	Assert(t, err != nil)
+
+	model, _, _ := ALS.TrainModel(Q, 2, 5, 0.01)
+	control, treatment := NewModelHolder(model), NewModelHolder(model.Clone())
+	Assert(t, r.Register("control", control, 80) == nil)
+	Assert(t, r.Register("treatment", treatment, 20) == nil)
+	Assert(t, r.Register("control", control, 10) != nil)
+	Assert(t, r.Register("negative", control, -1) != nil)
+
+	routed := make(map[string]int)
+	for u := 0; u < 1000; u++ {
+		user := fmt.Sprint("user", u)
+		name, holder, err := r.Route(user)
+		Assert(t, err == nil, err)
+		Assert(t, (name == "control") == (holder == control), name)
+		// sticky
+		again, _, _ := r.Route(user)
+		Assert(t, again == name, user)
+		routed[name]++
+	}
+	Assert(t, routed["treatment"] > 120 && routed["treatment"] < 280, routed)
+
+	Assert(t, r.Record("treatment", "click") == nil)
+	Assert(t, r.Record("missing", "click") != nil)
+	stats := r.Stats()
+	Assert(t, stats["treatment"].Served == int64(2*routed["treatment"]), stats)
+	Assert(t, stats["treatment"].Outcomes["click"] == 1 && stats["control"].Outcomes["click"] == 0, stats)
+
+	// all traffic to the treatment
+	Assert(t, r.SetWeight("control", 0) == nil)
+	name, _, _ := r.Route("user1")
+	Assert(t, name == "treatment", name)
+	Assert(t, len(r.Models()) == 2 && r.Models()[0] == "control")
+}
+
+func TestCache(t *testing.T) {
+	model, _, _ := ALS.TrainModel(Q, 2, 5, 0.01)
+	h := NewModelHolder(model)
+	_, err := NewCache(h, 0, 0)
+	Assert(t, err != nil)
+	c, _ := NewCache(h, 2, time.Minute)
+	now := time.Unix(0, 0)
+	c.now = func() time.Time { return now }
+
+	ids, scores, err := c.TopN(0, 2, "")
+	want, _, _ := model.TopN(0, 2)
+	Assert(t, err == nil && fmt.Sprint(ids) == fmt.Sprint(want), ids, want)
+	ids[0] = 99
+	cached, cachedScores, _ := c.TopN(0, 2, "")
+	Assert(t, cached[0] == want[0] && cachedScores[0] == scores[0], cached)
+	_, hits, misses := c.Stats()
+	Assert(t, hits == 1 && misses == 1, hits, misses)
+
+	// different filters are different entries, and the least recently used entry is evicted
+	c.TopN(0, 2, "no-2", ALS.Blocklist(2))
+	c.TopN(1, 1, "")
+	size, _, _ := c.Stats()
+	Assert(t, size == 2, size)
+	c.TopN(0, 2, "")
+	_, hits, _ = c.Stats()
+	Assert(t, hits == 1, hits)
+
+	// expiry
+	c.TopN(0, 2, "")
+	now = now.Add(2 * time.Minute)
+	c.TopN(0, 2, "")
+	_, hits, misses = c.Stats()
+	Assert(t, hits == 2 && misses == 5, hits, misses)
+
+	// a swapped or updated model is never served from the cache
+	h.Update(func(m *ALS.Model) error { return m.Update(0, 2, 5) })
+	ids, _, _ = c.TopN(0, 2, "")
+	want, _, _ = h.Load().TopN(0, 2)
+	Assert(t, fmt.Sprint(ids) == fmt.Sprint(want), ids, want)
+	// the entries of the old model were dropped, not just skipped
+	size, hits, _ = c.Stats()
+	Assert(t, hits == 2 && size == 1, hits, size)
+
+	c.TopN(1, 1, "")
+	c.InvalidateUser(0)
+	size, _, _ = c.Stats()
+	Assert(t, size == 1, size)
+	c.Invalidate()
+	size, _, _ = c.Stats()
+	Assert(t, size == 0, size)
+	_, _, err = c.TopN(7, 1, "")
+	Assert(t, err != nil)
+}
+
+type countingRecorder struct {
+	mu       sync.Mutex
+	requests int
+	failed   int
+	models   []string
+}
+
+func (r *countingRecorder) ObserveRequest(kind string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests++
+	if err != nil {
+		r.failed++
+	}
+}
+
+func (r *countingRecorder) SetModelAge(model string, age time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.models = append(r.models, model)
+}
+
+func (r *countingRecorder) ObserveIteration(iteration int, loss float64) {}
+
+func TestInstrumented(t *testing.T) {
+	model, _, _ := ALS.TrainModel(Q, 2, 5, 0.01)
+	r := &countingRecorder{}
+	i := NewInstrumented("current", NewModelHolder(model), r)
+	ids, _, err := i.TopN(0, 2)
+	want, _, _ := model.TopN(0, 2)
+	Assert(t, err == nil && fmt.Sprint(ids) == fmt.Sprint(want), ids, want)
+	_, _, err = i.TopN(10, 2)
+	Assert(t, err != nil)
+	Assert(t, r.requests == 2 && r.failed == 1 && len(r.models) == 2 && r.models[0] == "current", r)
+}
+
+func TestScheduler(t *testing.T) {
+	model, _, _ := ALS.TrainModel(Q, 2, 5, 0.01)
+	h := NewModelHolder(model)
+	var retrained [][]Interaction
+	var events []string
+	s := NewScheduler(h, func(batch []Interaction) (*ALS.Model, error) {
+		retrained = append(retrained, batch)
+		m, _, err := ALS.TrainModel(Q, 2, 5, 0.01)
+		return m, err
+	})
+	now := time.Unix(0, 0)
+	s.now = func() time.Time { return now }
+	s.UpdateAfter, s.RetrainAfter, s.MaxAge = 2, 5, time.Hour
+	s.BeforeRetrain = func(batch []Interaction) { events = append(events, fmt.Sprint("before ", len(batch))) }
+	s.AfterRetrain = func(m *ALS.Model, err error) { events = append(events, fmt.Sprint("after ", err)) }
+
+	// below every threshold nothing happens
+	s.Add(0, 2, 4)
+	Assert(t, s.Check() == nil && h.Load() == model)
+
+	// incremental updates publish a new model
+	s.Add(1, 0, 3)
+	Assert(t, s.Check() == nil && h.Load() != model)
+	Assert(t, h.Load().P.Get(0, 2) == 4 && h.Load().P.Get(1, 0) == 3 && model.P.Get(0, 2) == 0)
+	updates, sinceRetrain := s.Pending()
+	Assert(t, updates == 0 && sinceRetrain == 2, updates, sinceRetrain)
+
+	// the count threshold retrains on everything since the last retrain
+	s.Add(2, 1, 1)
+	s.Add(2, 3, 1)
+	s.Add(0, 2, 5)
+	updated := h.Load()
+	Assert(t, s.Check() == nil && h.Load() != updated)
+	Assert(t, len(retrained) == 1 && len(retrained[0]) == 5, retrained)
+	Assert(t, fmt.Sprint(events) == "[before 5 after <nil>]", events)
+	updates, sinceRetrain = s.Pending()
+	Assert(t, updates == 0 && sinceRetrain == 0, updates, sinceRetrain)
+
+	// and so does age
+	s.Add(1, 1, 2)
+	now = now.Add(2 * time.Hour)
+	Assert(t, s.Check() == nil && len(retrained) == 2 && len(retrained[1]) == 1, retrained)
+
+	// a failed retrain keeps the model and the interactions
+	s.Retrain = func(batch []Interaction) (*ALS.Model, error) { return nil, errors.New("out of memory") }
+	s.Add(1, 1, 2)
+	now = now.Add(2 * time.Hour)
+	served := h.Load()
+	Assert(t, s.Check() != nil && h.Load() == served)
+	_, sinceRetrain = s.Pending()
+	Assert(t, sinceRetrain == 1, sinceRetrain)
+
+	// a failed update keeps the served model
+	s.MaxAge = 0
+	s.Add(10, 0, 1)
+	s.Add(0, 0, 1)
+	Assert(t, s.Check() != nil && h.Load() == served)
+
+	// background checks
+	s.Retrain = nil
+	s.Interval = time.Millisecond
+	s.Add(0, 3, 2)
+	s.Add(1, 3, 2)
+	s.Start()
+	for deadline := time.Now().Add(5 * time.Second); h.Load() == served && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	s.Stop()
+	Assert(t, h.Load() != served && h.Load().P.Get(1, 3) == 2)
+}
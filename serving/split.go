@@ -0,0 +1,149 @@
+package serving
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// Per model counters of a Router: how many requests it served, and how many of each outcome
+// (e.g. "click", "purchase") were recorded for its recommendations.
+type Stats struct {
+	Served   int64
+	Outcomes map[string]int64
+}
+
+// a registered model and its share of the traffic
+type arm struct {
+	name   string
+	weight float64
+	holder *ModelHolder
+	stats  Stats
+}
+
+// Splits traffic between several named models for online A/B tests. Users are hashed into a
+// model by their ID, so a user keeps seeing the same model as long as the weights don't change.
+type Router struct {
+	mu   sync.Mutex
+	arms []*arm
+}
+
+// Returns a router without any models.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Adds a model that gets weight / (sum of all weights) of the users, e.g. 90 and 10 for a 90/10
+// split. Error if the name is taken or the weight is negative.
+func (r *Router) Register(name string, holder *ModelHolder, weight float64) error {
+	if weight < 0 {
+		return errors.New("Traffic weight cannot be negative")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.find(name) != nil {
+		return errors.New("A model named " + name + " is already registered")
+	}
+	r.arms = append(r.arms, &arm{name: name, weight: weight, holder: holder, stats: Stats{Outcomes: make(map[string]int64)}})
+	return nil
+}
+
+// Changes the share of traffic of a registered model, e.g. to ramp up a winning variant.
+// Users near the changed boundaries move to another model.
+func (r *Router) SetWeight(name string, weight float64) error {
+	if weight < 0 {
+		return errors.New("Traffic weight cannot be negative")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a := r.find(name)
+	if a == nil {
+		return errors.New("No model named " + name)
+	}
+	a.weight = weight
+	return nil
+}
+
+func (r *Router) find(name string) *arm {
+	for _, a := range r.arms {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// resolution of the user hash buckets
+const buckets = 10000
+
+// Returns the name and holder of the model serving the user, and counts the request towards its
+// Served stat. Error if no model has a positive weight.
+func (r *Router) Route(user string) (string, *ModelHolder, error) {
+	h := fnv.New32a()
+	h.Write([]byte(user))
+	point := float64(h.Sum32()%buckets) / buckets
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := 0.0
+	for _, a := range r.arms {
+		total += a.weight
+	}
+	if total == 0 {
+		return "", nil, errors.New("No model to route to")
+	}
+	var last *arm
+	cumulative := 0.0
+	for _, a := range r.arms {
+		if a.weight == 0 {
+			continue
+		}
+		last = a
+		cumulative += a.weight / total
+		if point < cumulative {
+			break
+		}
+	}
+	last.stats.Served++
+	return last.name, last.holder, nil
+}
+
+// Counts an outcome of a recommendation made by the named model, e.g. Record("als-v2", "click").
+func (r *Router) Record(name, outcome string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a := r.find(name)
+	if a == nil {
+		return errors.New("No model named " + name)
+	}
+	a.stats.Outcomes[outcome]++
+	return nil
+}
+
+// Returns a copy of the counters of every registered model.
+func (r *Router) Stats() map[string]Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make(map[string]Stats, len(r.arms))
+	for _, a := range r.arms {
+		outcomes := make(map[string]int64, len(a.stats.Outcomes))
+		for k, v := range a.stats.Outcomes {
+			outcomes[k] = v
+		}
+		stats[a.name] = Stats{Served: a.stats.Served, Outcomes: outcomes}
+	}
+	return stats
+}
+
+// Returns the names of the registered models, sorted.
+func (r *Router) Models() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, len(r.arms))
+	for n, a := range r.arms {
+		names[n] = a.name
+	}
+	sort.Strings(names)
+	return names
+}
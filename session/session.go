@@ -0,0 +1,75 @@
+// Session-based next-item recommendations in Go
+package session
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/timkaye11/goRecommend/topk"
+)
+
+// A Markov chain over the items of ordered sessions (e.g. the pages or products an anonymous
+// visitor viewed), predicting the next item from the last Order items. Contexts that were never
+// seen back off to the last Order-1 items, and so on down to the last item alone.
+type Markov struct {
+	Order int
+	// transition counts: context (the preceding items) -> next item -> count
+	transitions map[string]map[int]float64
+}
+
+// Returns an empty chain conditioning on up to order previous items. Order 1 is the classic
+// first-order Markov chain; 2 or 3 help when sessions have strong sequential patterns.
+func NewMarkov(order int) (*Markov, error) {
+	if order < 1 {
+		return nil, errors.New("Order needs to be at least 1")
+	}
+	return &Markov{Order: order, transitions: make(map[string]map[int]float64)}, nil
+}
+
+func key(context []int) string {
+	return fmt.Sprint(context)
+}
+
+// Counts the transitions of a session, for every context length up to Order.
+func (m *Markov) Add(session []int) {
+	for pos := 1; pos < len(session); pos++ {
+		for k := 1; k <= m.Order && k <= pos; k++ {
+			ctx := key(session[pos-k : pos])
+			if m.transitions[ctx] == nil {
+				m.transitions[ctx] = make(map[int]float64)
+			}
+			m.transitions[ctx][session[pos]]++
+		}
+	}
+}
+
+// Adds every session, see Add.
+func (m *Markov) Fit(sessions [][]int) {
+	for _, s := range sessions {
+		m.Add(s)
+	}
+}
+
+// Returns up to n items most likely to come after the recent items (oldest first), in descending
+// order along with their estimated transition probabilities. Uses the longest context of at most
+// Order trailing items that was seen in training; returns nothing if not even the last item was.
+func (m *Markov) Next(recent []int, n int) ([]int, []float64) {
+	for k := m.Order; k >= 1; k-- {
+		if k > len(recent) {
+			continue
+		}
+		next, ok := m.transitions[key(recent[len(recent)-k:])]
+		if !ok {
+			continue
+		}
+		total := 0.0
+		items := make([]int, 0, len(next))
+		for i, c := range next {
+			items = append(items, i)
+			total += c
+		}
+		items, probs := topk.Split(topk.Select(items, n, func(i int) float64 { return next[i] / total }))
+		return items, probs
+	}
+	return nil, nil
+}
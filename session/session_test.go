@@ -0,0 +1,44 @@
+package session
+
+import (
+	"math"
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestMarkov(t *testing.T) {
+	_, err := NewMarkov(0)
+	Assert(t, err != nil)
+
+	m, err := NewMarkov(2)
+	Assert(t, err == nil, err)
+	m.Fit([][]int{
+		{0, 1, 2},
+		{0, 1, 2},
+		{3, 1, 4},
+		{1, 4},
+	})
+
+	// after 1 alone, 4 is seen twice and 2 twice, ties go by index
+	items, probs := m.Next([]int{1}, 5)
+	Assert(t, len(items) == 2 && items[0] == 2 && items[1] == 4, items)
+	Assert(t, math.Abs(probs[0]-0.5) < 1e-12, probs)
+
+	// the second order context disambiguates
+	items, probs = m.Next([]int{3, 1}, 1)
+	Assert(t, len(items) == 1 && items[0] == 4 && probs[0] == 1, items, probs)
+
+	// unseen second order context backs off to the last item
+	items, _ = m.Next([]int{2, 1}, 1)
+	Assert(t, len(items) == 1 && items[0] == 2, items)
+
+	items, _ = m.Next([]int{7}, 3)
+	Assert(t, len(items) == 0, items)
+	items, _ = m.Next(nil, 3)
+	Assert(t, len(items) == 0, items)
+}
@@ -0,0 +1,123 @@
+// Writes precomputed recommendations and factor vectors to Redis
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+// A Redis connection. The Conn of github.com/garyburd/redigo/redis satisfies it, as does a
+// thin wrapper around any other client.
+type Conn interface {
+	Do(commandName string, args ...interface{}) (reply interface{}, err error)
+}
+
+// A recommended item and its predicted score, as stored in Redis.
+type Recommendation struct {
+	Item  string  `json:"item"`
+	Score float64 `json:"score"`
+}
+
+// Writes each user's top-N list and each item's factor vector as JSON strings, so services in
+// any language can serve precomputed recommendations without the Go model.
+// UserKey and ItemKey are fmt formats turning a user/item ID into its key, and keys expire after
+// TTL unless it is 0.
+type Store struct {
+	Conn    Conn
+	UserKey string
+	ItemKey string
+	TTL     time.Duration
+}
+
+// Returns a store writing to "recommend:user:<id>" and "recommend:item:<id>:factors" without expiry.
+func New(conn Conn) *Store {
+	return &Store{
+		Conn:    conn,
+		UserKey: "recommend:user:%s",
+		ItemKey: "recommend:item:%s:factors",
+	}
+}
+
+// stores v as JSON under key
+func (s *Store) set(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if s.TTL > 0 {
+		_, err = s.Conn.Do("SET", key, value, "PX", int64(s.TTL/time.Millisecond))
+	} else {
+		_, err = s.Conn.Do("SET", key, value)
+	}
+	return err
+}
+
+// Writes the recommendations for a user, best first.
+func (s *Store) WriteTopN(user string, recs []Recommendation) error {
+	return s.set(fmt.Sprintf(s.UserKey, user), recs)
+}
+
+// Writes the factor vector of an item.
+func (s *Store) WriteFactors(item string, factors []float64) error {
+	return s.set(fmt.Sprintf(s.ItemKey, item), factors)
+}
+
+// Reads back the recommendations of a user. Returns nil if there are none (or they expired).
+func (s *Store) TopN(user string) ([]Recommendation, error) {
+	reply, err := s.Conn.Do("GET", fmt.Sprintf(s.UserKey, user))
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	var value []byte
+	switch r := reply.(type) {
+	case []byte:
+		value = r
+	case string:
+		value = []byte(r)
+	default:
+		return nil, errors.New("Unexpected reply type from Redis")
+	}
+	var recs []Recommendation
+	err = json.Unmarshal(value, &recs)
+	return recs, err
+}
+
+// Writes the top n recommendations of every user of the model, and the factors of every product.
+// users and products map model indices to IDs; if nil, the indices themselves are used.
+func (s *Store) WriteModel(m *ALS.Model, n int, users, products []string) error {
+	userID := idFunc(users)
+	productID := idFunc(products)
+	for u := 0; u < m.X.Rows(); u++ {
+		ids, scores, err := m.TopN(u, n)
+		if err != nil {
+			return err
+		}
+		recs := make([]Recommendation, len(ids))
+		for r, i := range ids {
+			recs[r] = Recommendation{Item: productID(i), Score: scores[r]}
+		}
+		if err := s.WriteTopN(userID(u), recs); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < m.Y.Cols(); i++ {
+		if err := s.WriteFactors(productID(i), m.Y.ColCopy(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func idFunc(ids []string) func(int) string {
+	if ids == nil {
+		return strconv.Itoa
+	}
+	return func(i int) string {
+		return ids[i]
+	}
+}
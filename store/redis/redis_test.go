@@ -0,0 +1,69 @@
+package redis
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+// an in-memory stand in for Redis, recording the commands sent to it
+type fakeConn struct {
+	values   map[string][]byte
+	commands [][]interface{}
+}
+
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.commands = append(c.commands, append([]interface{}{cmd}, args...))
+	switch cmd {
+	case "SET":
+		c.values[args[0].(string)] = args[1].([]byte)
+		return "OK", nil
+	case "GET":
+		if v, ok := c.values[args[0].(string)]; ok {
+			return v, nil
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown command %v", cmd)
+}
+
+func TestStore(t *testing.T) {
+	conn := &fakeConn{values: make(map[string][]byte)}
+	s := New(conn)
+	s.UserKey = "u:%s"
+	s.TTL = time.Hour
+
+	Assert(t, s.WriteTopN("alice", []Recommendation{{"spoon", 4.5}, {"kanye", 3}}) == nil)
+	last := conn.commands[len(conn.commands)-1]
+	Assert(t, last[1] == "u:alice" && last[3] == "PX" && last[4] == int64(3600000), last)
+
+	recs, err := s.TopN("alice")
+	Assert(t, err == nil && len(recs) == 2 && recs[0].Item == "spoon" && recs[1].Score == 3, recs)
+	recs, err = s.TopN("bob")
+	Assert(t, err == nil && recs == nil)
+}
+
+func TestWriteModel(t *testing.T) {
+	Q := MakeDenseMatrix([]float64{
+		5, 0, 1,
+		4, 2, 0}, 2, 3)
+	model, _, _ := ALS.TrainModel(Q, 2, 5, 0.01)
+
+	conn := &fakeConn{values: make(map[string][]byte)}
+	s := New(conn)
+	Assert(t, s.WriteModel(model, 2, []string{"alice", "bob"}, nil) == nil)
+	Assert(t, len(conn.values) == 5, len(conn.values))
+
+	recs, _ := s.TopN("bob")
+	Assert(t, len(recs) == 1 && recs[0].Item == "2", recs)
+	Assert(t, string(conn.values["recommend:item:0:factors"][0]) == "[")
+}
@@ -0,0 +1,48 @@
+// Example Kafka adapter for the stream package
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/timkaye11/goRecommend/stream"
+)
+
+// A stream.Source reading JSON encoded events, e.g.
+// {"user": "alice", "item": "spoon", "value": 1, "time": "2015-06-01T12:00:00Z"},
+// from a Kafka topic. Offsets are committed as messages are read, per the reader's config.
+type Source struct {
+	Reader *kafkago.Reader
+	// context for the reads; cancelling it ends the stream
+	Context context.Context
+}
+
+// Returns a source reading the topic from the brokers as part of the consumer group.
+func NewSource(brokers []string, topic, group string) *Source {
+	return &Source{
+		Reader: kafkago.NewReader(kafkago.ReaderConfig{
+			Brokers: brokers,
+			Topic:   topic,
+			GroupID: group,
+		}),
+		Context: context.Background(),
+	}
+}
+
+// Reads the next event. Messages that are not valid events are returned as errors, which ends
+// the stream; wrap the source if they should be skipped instead.
+func (s *Source) Next() (stream.Event, error) {
+	var e stream.Event
+	msg, err := s.Reader.ReadMessage(s.Context)
+	if err != nil {
+		return e, err
+	}
+	err = json.Unmarshal(msg.Value, &e)
+	return e, err
+}
+
+// Closes the underlying reader.
+func (s *Source) Close() error {
+	return s.Reader.Close()
+}
@@ -0,0 +1,134 @@
+// Ingests streams of user/item interaction events into datasets and models
+package stream
+
+import (
+	"io"
+	"time"
+
+	"github.com/timkaye11/goRecommend/ALS"
+	"github.com/timkaye11/goRecommend/data"
+)
+
+// A single interaction of a user with an item, e.g. a view, purchase or rating.
+type Event struct {
+	User  string    `json:"user"`
+	Item  string    `json:"item"`
+	Value float64   `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// Adapts a message queue or log to a stream of events. Next blocks until the next event is
+// available, and returns io.EOF once the stream is over.
+type Source interface {
+	Next() (Event, error)
+}
+
+// A Source reading events from a channel until it is closed.
+type ChannelSource <-chan Event
+
+func (c ChannelSource) Next() (Event, error) {
+	e, ok := <-c
+	if !ok {
+		return Event{}, io.EOF
+	}
+	return e, nil
+}
+
+// Aggregates a stream of events into a Dataset, with one entry per user/item pair.
+// If Model is set, every aggregated value is applied to it right away with Model.Update;
+// the model's users and products must line up with the Dataset's, e.g. by training it on
+// Dataset.Matrix(). If Retrain is set, it is called with the Dataset every RetrainEvery,
+// to replace incremental updates with a full retrain now and then.
+// Model and Retrain are only called from the goroutine running Run.
+type Consumer struct {
+	Dataset *data.Dataset
+	// combines the current value of a user/item pair with a new event's. Sums by default,
+	// which turns events into implicit interaction counts; return value to keep the latest rating.
+	Aggregate func(current, value float64) float64
+	Model     *ALS.Model
+
+	RetrainEvery time.Duration
+	Retrain      func(d *data.Dataset)
+
+	// index into Dataset.Entries of each user/item pair
+	entries map[[2]int]int
+}
+
+// Returns a consumer summing events into a new Dataset.
+func NewConsumer() *Consumer {
+	return &Consumer{
+		Dataset: &data.Dataset{},
+		Aggregate: func(current, value float64) float64 {
+			return current + value
+		},
+	}
+}
+
+// Adds a single event to the dataset, and updates the model if there is one.
+// Returns the error of the model update, if any.
+func (c *Consumer) Add(e Event) error {
+	if c.entries == nil {
+		c.entries = make(map[[2]int]int)
+		for n, entry := range c.Dataset.Entries {
+			c.entries[[2]int{entry.User, entry.Item}] = n
+		}
+	}
+	n := len(c.Dataset.Entries)
+	c.Dataset.Add(data.Rating{User: e.User, Item: e.Item, Value: e.Value})
+	entry := c.Dataset.Entries[n]
+	key := [2]int{entry.User, entry.Item}
+	if prev, ok := c.entries[key]; ok {
+		// fold the event into the pair's existing entry
+		c.Dataset.Entries = c.Dataset.Entries[:n]
+		entry = c.Dataset.Entries[prev]
+		entry.Value = c.Aggregate(entry.Value, e.Value)
+		c.Dataset.Entries[prev] = entry
+	} else {
+		c.entries[key] = n
+	}
+	if c.Model != nil {
+		return c.Model.Update(entry.User, entry.Item, entry.Value)
+	}
+	return nil
+}
+
+// Consumes events from the source until it returns an error, which is returned (nil for io.EOF).
+// Failed model updates do not stop the stream; they are passed to onError if it is not nil.
+func (c *Consumer) Run(src Source, onError func(error)) error {
+	events := make(chan Event)
+	done := make(chan error, 1)
+	go func() {
+		for {
+			e, err := src.Next()
+			if err != nil {
+				done <- err
+				close(events)
+				return
+			}
+			events <- e
+		}
+	}()
+
+	var tick <-chan time.Time
+	if c.Retrain != nil && c.RetrainEvery > 0 {
+		ticker := time.NewTicker(c.RetrainEvery)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				if err := <-done; err != io.EOF {
+					return err
+				}
+				return nil
+			}
+			if err := c.Add(e); err != nil && onError != nil {
+				onError(err)
+			}
+		case <-tick:
+			c.Retrain(c.Dataset)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package stream
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/timkaye11/goRecommend/ALS"
+	"github.com/timkaye11/goRecommend/data"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestConsumer(t *testing.T) {
+	c := NewConsumer()
+	events := make(chan Event, 4)
+	events <- Event{User: "alice", Item: "spoon", Value: 1}
+	events <- Event{User: "bob", Item: "spoon", Value: 1}
+	events <- Event{User: "alice", Item: "spoon", Value: 2}
+	events <- Event{User: "alice", Item: "kanye", Value: 1}
+	close(events)
+
+	Assert(t, c.Run(ChannelSource(events), nil) == nil)
+	Assert(t, len(c.Dataset.Entries) == 3 && c.Dataset.Users() == 2)
+	Q := c.Dataset.Matrix()
+	Assert(t, Q.Get(0, 0) == 3 && Q.Get(1, 0) == 1 && Q.Get(0, 1) == 1, Q)
+}
+
+func TestConsumerUpdatesModel(t *testing.T) {
+	c := NewConsumer()
+	c.Add(Event{User: "alice", Item: "spoon", Value: 1})
+	c.Add(Event{User: "bob", Item: "kanye", Value: 1})
+	c.Model, _ = ALS.TrainImplicitModel(c.Dataset.Matrix(), 2, 5, 0.01)
+
+	// a new item for an existing user grows the model
+	Assert(t, c.Add(Event{User: "bob", Item: "macy gray", Value: 3}) == nil)
+	Assert(t, c.Model.P.Cols() == 3 && c.Model.P.Get(1, 2) == 1)
+
+	// an event for a user two past the end of the model cannot be applied
+	c.Model, _ = ALS.TrainImplicitModel(c.Dataset.Matrix(), 2, 5, 0.01)
+	c.Dataset.Add(data.Rating{User: "carol", Item: "spoon", Value: 1})
+	Assert(t, c.Add(Event{User: "dave", Item: "spoon", Value: 1}) != nil)
+}
+
+type failingSource struct{}
+
+func (failingSource) Next() (Event, error) {
+	time.Sleep(20 * time.Millisecond)
+	return Event{}, errors.New("broker went away")
+}
+
+func TestRetrain(t *testing.T) {
+	c := NewConsumer()
+	retrains := 0
+	c.Retrain = func(*data.Dataset) { retrains++ }
+	c.RetrainEvery = time.Millisecond
+	err := c.Run(failingSource{}, nil)
+	Assert(t, err != nil && err.Error() == "broker went away", err)
+	Assert(t, retrains > 0)
+}
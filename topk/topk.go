@@ -0,0 +1,80 @@
+// Top-k selection of scored items in Go
+package topk
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// An index (of a product, user, ...) with its score.
+type ScoredIndex struct {
+	Index int
+	Score float64
+}
+
+// min-heap on score, with the higher index on top on ties so the lower one is kept
+type scoredHeap []ScoredIndex
+
+func (h scoredHeap) Len() int { return len(h) }
+func (h scoredHeap) Less(i, j int) bool {
+	if h[i].Score != h[j].Score {
+		return h[i].Score < h[j].Score
+	}
+	return h[i].Index > h[j].Index
+}
+func (h scoredHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoredHeap) Push(x interface{}) { *h = append(*h, x.(ScoredIndex)) }
+func (h *scoredHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return n
+}
+
+// Returns the k highest scores with their indices, in descending order of score, ties broken by
+// the lower index. Uses a heap of size k, so only O(n log k) work instead of sorting all scores.
+// NaN scores are never selected.
+func TopK(scores []float64, k int) []ScoredIndex {
+	return selectTop(len(scores), k, func(n int) (int, float64) { return n, scores[n] })
+}
+
+// Like TopK, but over the given candidate indices only, scored by score.
+func Select(candidates []int, k int, score func(index int) float64) []ScoredIndex {
+	return selectTop(len(candidates), k, func(n int) (int, float64) { return candidates[n], score(candidates[n]) })
+}
+
+func selectTop(n, k int, at func(n int) (int, float64)) []ScoredIndex {
+	if k <= 0 {
+		return []ScoredIndex{}
+	}
+	h := make(scoredHeap, 0, k+1)
+	for i := 0; i < n; i++ {
+		index, score := at(i)
+		if math.IsNaN(score) {
+			continue
+		}
+		if len(h) == k {
+			// cheap rejection of everything that wouldn't make the cut
+			if worst := h[0]; score < worst.Score || (score == worst.Score && index > worst.Index) {
+				continue
+			}
+		}
+		heap.Push(&h, ScoredIndex{index, score})
+		if len(h) > k {
+			heap.Pop(&h)
+		}
+	}
+	sort.Sort(sort.Reverse(h))
+	return h
+}
+
+// Splits the results into their indices and scores, the form most recommenders return.
+func Split(results []ScoredIndex) ([]int, []float64) {
+	indices := make([]int, len(results))
+	scores := make([]float64, len(results))
+	for n, r := range results {
+		indices[n], scores[n] = r.Index, r.Score
+	}
+	return indices, scores
+}
@@ -0,0 +1,43 @@
+package topk
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestTopK(t *testing.T) {
+	top := TopK([]float64{1, 3, math.NaN(), 3, 2, 0}, 3)
+	indices, scores := Split(top)
+	// ties go to the lower index, NaN is skipped
+	Assert(t, len(indices) == 3 && indices[0] == 1 && indices[1] == 3 && indices[2] == 4, indices)
+	Assert(t, scores[0] == 3 && scores[2] == 2, scores)
+
+	Assert(t, len(TopK([]float64{1, 2}, 5)) == 2)
+	Assert(t, len(TopK([]float64{1, 2}, 0)) == 0)
+
+	// same as a full sort
+	rng := rand.New(rand.NewSource(1))
+	values := make([]float64, 500)
+	for i := range values {
+		values[i] = float64(rng.Intn(50))
+	}
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return values[order[a]] > values[order[b]] })
+	for n, r := range TopK(values, 20) {
+		Assert(t, r.Index == order[n], n, r, order[n])
+	}
+
+	selected := Select([]int{5, 2, 9}, 2, func(i int) float64 { return -float64(i) })
+	Assert(t, selected[0].Index == 2 && selected[1].Index == 5 && selected[0].Score == -2, selected)
+}
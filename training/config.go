@@ -0,0 +1,148 @@
+// Config file driven training of the ALS recommenders
+package training
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/timkaye11/goRecommend/ALS"
+	"github.com/timkaye11/goRecommend/metrics"
+	"gopkg.in/yaml.v3"
+)
+
+// What to train, on which data, and where to write the results. Algorithm is one of the
+// ALS.Algorithm* names: "als", "als-implicit", "sgd", "warp" or "eals". Fields an algorithm
+// doesn't use are ignored, e.g. LearningRate for "als".
+type Config struct {
+	Algorithm  string  `json:"algorithm" yaml:"algorithm"`
+	Factors    int     `json:"factors" yaml:"factors"`
+	Iterations int     `json:"iterations" yaml:"iterations"`
+	Lambda     float64 `json:"lambda" yaml:"lambda"`
+	// step size of "sgd" and "warp"
+	LearningRate float64 `json:"learning_rate" yaml:"learning_rate"`
+	// weight of the missing entries and its popularity exponent for "eals"
+	C0    float64 `json:"c0" yaml:"c0"`
+	Alpha float64 `json:"alpha" yaml:"alpha"`
+	// negatives per interaction for "sgd", maximum draws for "warp"
+	Negatives      int   `json:"negatives" yaml:"negatives"`
+	Seed           int64 `json:"seed" yaml:"seed"`
+	Parallelism    int   `json:"parallelism" yaml:"parallelism"`
+	WeightedLambda bool  `json:"weighted_lambda" yaml:"weighted_lambda"`
+	// train "als" with float32 factors (ALS.TrainModel32) and write an ALS.Model32, see Run32
+	Float32 bool `json:"float32" yaml:"float32"`
+
+	Data   DataConfig   `json:"data" yaml:"data"`
+	Output OutputConfig `json:"output" yaml:"output"`
+
+	// receives the training error of every iteration, if set; not read from config files
+	Recorder metrics.Recorder `json:"-" yaml:"-"`
+}
+
+// The ratings to train on. Format is "triples" (user, product, rating per line, split by
+// Separator, see ALS.Load), "csv" (a dense matrix, see ALS.ReadCSV) or "npy" (see ALS.ReadNpy).
+type DataConfig struct {
+	Path      string `json:"path" yaml:"path"`
+	Format    string `json:"format" yaml:"format"`
+	Separator string `json:"separator" yaml:"separator"`
+}
+
+// Where to write the trained model (see ALS.Model.Save) and its user and product factors, in
+// FactorFormat "csv" or "npy". Empty paths are skipped.
+type OutputConfig struct {
+	Model          string `json:"model" yaml:"model"`
+	UserFactors    string `json:"user_factors" yaml:"user_factors"`
+	ProductFactors string `json:"product_factors" yaml:"product_factors"`
+	FactorFormat   string `json:"factor_format" yaml:"factor_format"`
+}
+
+// settings a config file doesn't need to spell out
+func defaultConfig() *Config {
+	return &Config{
+		Algorithm:    ALS.AlgorithmALS,
+		Factors:      10,
+		Iterations:   10,
+		Lambda:       0.01,
+		LearningRate: 0.05,
+		C0:           1,
+		Alpha:        0.5,
+		Seed:         47,
+		Data:         DataConfig{Format: "triples", Separator: ","},
+		Output:       OutputConfig{FactorFormat: "csv"},
+	}
+}
+
+// Reads a training config from a JSON (.json) or YAML (.yaml, .yml) file. Settings missing from
+// the file keep their defaults: explicit ALS with 10 factors, 10 iterations and lambda 0.01, on
+// comma separated triples.
+func LoadConfig(path string) (*Config, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c := defaultConfig()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(raw, c)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(raw, c)
+	default:
+		return nil, errors.New("Config file needs a .json, .yaml or .yml extension")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, c.Validate()
+}
+
+// Checks that the config describes a model that can be trained.
+func (c *Config) Validate() error {
+	switch c.Algorithm {
+	case ALS.AlgorithmALS, ALS.AlgorithmImplicitALS, ALS.AlgorithmSGD, ALS.AlgorithmWARP, ALS.AlgorithmEALS:
+	default:
+		return errors.New("Unknown algorithm: " + c.Algorithm)
+	}
+	if c.Factors <= 0 || c.Iterations <= 0 {
+		return errors.New("Factors and iterations need to be positive")
+	}
+	if c.Float32 && c.Algorithm != ALS.AlgorithmALS {
+		return errors.New("Float32 training is only available for als")
+	}
+	if c.Lambda < 0 {
+		return errors.New("Lambda can't be negative")
+	}
+	if c.Data.Path == "" {
+		return errors.New("Config needs a data path")
+	}
+	switch c.Data.Format {
+	case "triples", "csv", "npy":
+	default:
+		return errors.New("Unknown data format: " + c.Data.Format)
+	}
+	switch c.Output.FactorFormat {
+	case "csv", "npy":
+	default:
+		return errors.New("Unknown factor format: " + c.Output.FactorFormat)
+	}
+	return nil
+}
+
+// The ALS options the config asks for.
+func (c *Config) Options() []ALS.Option {
+	opts := []ALS.Option{ALS.WithSeed(c.Seed)}
+	if c.Parallelism > 0 {
+		opts = append(opts, ALS.WithParallelism(c.Parallelism))
+	}
+	if c.WeightedLambda {
+		opts = append(opts, ALS.WithWeightedLambda())
+	}
+	if c.Negatives > 0 {
+		opts = append(opts, ALS.WithNegativeSamples(c.Negatives))
+	}
+	if c.Recorder != nil {
+		opts = append(opts, metrics.Training(c.Recorder))
+	}
+	return opts
+}
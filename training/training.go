@@ -0,0 +1,136 @@
+package training
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	. "github.com/skelterjohn/go.matrix"
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+// Reads the ratings the config points at.
+func (c *Config) LoadData() (*DenseMatrix, error) {
+	if c.Data.Format == "triples" {
+		return ALS.Load(c.Data.Path, c.Data.Separator)
+	}
+	f, err := os.Open(c.Data.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if c.Data.Format == "npy" {
+		return ALS.ReadNpy(f)
+	}
+	return ALS.ReadCSV(f)
+}
+
+// Trains the configured algorithm on Q.
+func (c *Config) Train(Q *DenseMatrix) (*ALS.Model, error) {
+	opts := c.Options()
+	switch c.Algorithm {
+	case ALS.AlgorithmALS:
+		m, _, err := ALS.TrainModel(Q, c.Factors, c.Iterations, c.Lambda, opts...)
+		return m, err
+	case ALS.AlgorithmImplicitALS:
+		return ALS.TrainImplicitModel(Q, c.Factors, c.Iterations, c.Lambda, opts...)
+	case ALS.AlgorithmSGD:
+		m, _, err := ALS.TrainSGD(Q, c.Factors, c.Iterations, c.Lambda, c.LearningRate, opts...)
+		return m, err
+	case ALS.AlgorithmWARP:
+		return ALS.TrainWARP(Q, c.Factors, c.Iterations, c.Lambda, c.LearningRate, opts...)
+	case ALS.AlgorithmEALS:
+		return ALS.TrainEALS(Q, c.Factors, c.Iterations, c.Lambda, c.C0, c.Alpha, opts...)
+	}
+	return nil, errors.New("Unknown algorithm: " + c.Algorithm)
+}
+
+// Trains explicit ALS on Q with float32 factors, for configs with Float32 set.
+func (c *Config) Train32(Q *DenseMatrix) (*ALS.Model32, error) {
+	return ALS.TrainModel32(Q, c.Factors, c.Iterations, c.Lambda, c.Options()...)
+}
+
+// Runs the whole pipeline the config describes: loads the data, trains the model and writes it
+// and its factors to the output locations. Returns the trained model. Configs with Float32 set
+// are run with Run32 instead.
+func Run(c *Config) (*ALS.Model, error) {
+	if c.Float32 {
+		return nil, errors.New("Float32 configs are run with Run32")
+	}
+	Q, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.Train(Q)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.write(m.Save, m.UserFactors, m.ProductFactors); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Like Run, for configs with Float32 set: trains with float32 factors and writes the model as
+// an ALS.Model32, which ALS.LoadModel32 reads back.
+func Run32(c *Config) (*ALS.Model32, error) {
+	if !c.Float32 {
+		return nil, errors.New("Only Float32 configs are run with Run32")
+	}
+	Q, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.Train32(Q)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.write(m.Save, m.UserFactors, m.ProductFactors); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// validates the config and loads its data
+func (c *Config) load() (*DenseMatrix, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c.LoadData()
+}
+
+// writes the model with save, and the factors, to the output locations
+func (c *Config) write(save func(w io.Writer) error, users, products func() *DenseMatrix) error {
+	if err := writeFile(c.Output.Model, save); err != nil {
+		return err
+	}
+	write := ALS.WriteCSV
+	if c.Output.FactorFormat == "npy" {
+		write = ALS.WriteNpy
+	}
+	err := writeFile(c.Output.UserFactors, func(w io.Writer) error {
+		return write(w, users())
+	})
+	if err != nil {
+		return err
+	}
+	return writeFile(c.Output.ProductFactors, func(w io.Writer) error {
+		return write(w, products())
+	})
+}
+
+// creates the file at path and writes it with fn; does nothing for an empty path
+func writeFile(path string, fn func(w io.Writer) error) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,103 @@
+package training
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/timkaye11/goRecommend/ALS"
+)
+
+func Assert(t *testing.T, condition bool, args ...interface{}) {
+	if !condition {
+		t.Fatal(args...)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "training")
+	Assert(t, err == nil, err)
+	defer os.RemoveAll(dir)
+
+	yamlPath := filepath.Join(dir, "train.yaml")
+	ioutil.WriteFile(yamlPath, []byte(`
+algorithm: als-implicit
+factors: 3
+lambda: 0.1
+data:
+  path: ../testdata/data.txt
+output:
+  model: `+filepath.Join(dir, "model.gob")+`
+  product_factors: `+filepath.Join(dir, "products.npy")+`
+  factor_format: npy
+`), 0644)
+	cfg, err := LoadConfig(yamlPath)
+	Assert(t, err == nil, err)
+	// unset fields keep their defaults
+	Assert(t, cfg.Algorithm == ALS.AlgorithmImplicitALS && cfg.Factors == 3 && cfg.Iterations == 10, cfg)
+	Assert(t, cfg.Data.Separator == "," && cfg.Output.UserFactors == "", cfg)
+
+	model, err := Run(cfg)
+	Assert(t, err == nil, err)
+	Assert(t, model.Implicit && model.X.Rows() == 4 && model.X.Cols() == 3)
+	f, err := os.Open(cfg.Output.Model)
+	Assert(t, err == nil, err)
+	saved, err := ALS.LoadModel(f)
+	f.Close()
+	Assert(t, err == nil && saved.Metadata().Fingerprint == model.Metadata().Fingerprint, err)
+	f, _ = os.Open(cfg.Output.ProductFactors)
+	products, err := ALS.ReadNpy(f)
+	f.Close()
+	Assert(t, err == nil && products.Rows() == 5 && products.Cols() == 3, err)
+
+	jsonPath := filepath.Join(dir, "train.json")
+	ioutil.WriteFile(jsonPath, []byte(`{"algorithm": "sgd", "iterations": 5, "learning_rate": 0.02,
+		"data": {"path": "../testdata/data.txt"}}`), 0644)
+	cfg, err = LoadConfig(jsonPath)
+	Assert(t, err == nil && cfg.Algorithm == ALS.AlgorithmSGD && cfg.LearningRate == 0.02, err, cfg)
+	model, err = Run(cfg)
+	Assert(t, err == nil && model.Metadata().Algorithm == ALS.AlgorithmSGD, err)
+
+	// float32 training is picked by the config, and only for als
+	float32Path := filepath.Join(dir, "float32.yaml")
+	ioutil.WriteFile(float32Path, []byte(`
+float32: true
+factors: 3
+data:
+  path: ../testdata/data.txt
+output:
+  model: `+filepath.Join(dir, "model32.gob")+`
+  user_factors: `+filepath.Join(dir, "users.csv")+`
+`), 0644)
+	cfg, err = LoadConfig(float32Path)
+	Assert(t, err == nil && cfg.Float32, err)
+	_, err = Run(cfg)
+	Assert(t, err != nil)
+	compact, err := Run32(cfg)
+	Assert(t, err == nil && compact.Users == 4 && compact.Factors == 3, err)
+	f, _ = os.Open(cfg.Output.Model)
+	saved32, err := ALS.LoadModel32(f)
+	f.Close()
+	Assert(t, err == nil && saved32.Products == 5, err)
+	f, _ = os.Open(cfg.Output.UserFactors)
+	users, err := ALS.ReadCSV(f)
+	f.Close()
+	Assert(t, err == nil && users.Rows() == 4 && users.Cols() == 3, err)
+
+	for name, content := range map[string]string{
+		"float32.json":  `{"algorithm": "sgd", "float32": true, "data": {"path": "x"}}`,
+		"unknown.json":  `{"algorithm": "magic", "data": {"path": "x"}}`,
+		"nodata.yaml":   `factors: 2`,
+		"format.yml":    "data:\n  path: x\n  format: parquet",
+		"train.toml":    `factors = 2`,
+		"negative.json": `{"lambda": -1, "data": {"path": "x"}}`,
+	} {
+		path := filepath.Join(dir, name)
+		ioutil.WriteFile(path, []byte(content), 0644)
+		_, err := LoadConfig(path)
+		Assert(t, err != nil, name)
+	}
+	_, err = LoadConfig(filepath.Join(dir, "missing.yaml"))
+	Assert(t, err != nil)
+}